@@ -0,0 +1,18 @@
+package main
+
+import (
+	"os"
+
+	"github.com/hytromo/faulty-crane/internal/containerregistry/driver"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/driver/directory"
+	log "github.com/sirupsen/logrus"
+)
+
+// faulty-crane-driver-directory is the reference registry driver: it serves the repositories of a directory over the driver protocol
+func main() {
+	err := driver.Serve("directory", directory.FromOptions, os.Stdin, os.Stdout)
+
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"github.com/hytromo/faulty-crane/internal/argsparser"
 	"github.com/hytromo/faulty-crane/internal/configuration"
 	"github.com/hytromo/faulty-crane/internal/containerregistry"
-	"github.com/hytromo/faulty-crane/internal/imagefilters"
 	"github.com/hytromo/faulty-crane/internal/orchestrator"
 	"github.com/hytromo/faulty-crane/internal/reporter"
 	color "github.com/logrusorgru/aurora"
@@ -33,7 +32,11 @@ func main() {
 
 	if appOptions.Show.SubcommandEnabled {
 		parsedRepos := configuration.ReadPlan(appOptions.Show.Plan, true)
-		reporter.ReportRepositoriesStatus(parsedRepos, appOptions.Show.Analytical)
+		if appOptions.Show.Tree {
+			reporter.ReportRepositoryTree(parsedRepos)
+		} else {
+			reporter.ReportRepositoriesStatus(parsedRepos, appOptions.Show.Analytical)
+		}
 	}
 
 	if appOptions.Apply.SubcommandEnabled || appOptions.Plan.SubcommandEnabled {
@@ -47,12 +50,7 @@ func main() {
 			log.Infof("Reading from plan file %v\n", options.Plan)
 			parsedRepos = configuration.ReadPlan(options.Plan, true)
 		} else {
-			orchestrator := orchestrator.NewOrchestrator(&appOptions)
-			orchestrator.Init()
-			parsedRepos = imagefilters.Parse(
-				orchestrator.GetAllRepos(),
-				options.Keep,
-			)
+			parsedRepos = orchestrator.PlanAllRegistries(&appOptions)
 		}
 
 		if appOptions.Plan.SubcommandEnabled {
@@ -92,12 +90,28 @@ func main() {
 		}
 
 		if appOptions.Apply.SubcommandEnabled {
-			orchestrator := orchestrator.NewOrchestrator(&appOptions)
-			orchestrator.Init()
-			results := orchestrator.DeleteImagesWithNoKeepReason(parsedRepos)
+			results := orchestrator.DeleteAllRegistries(&appOptions, parsedRepos)
+
+			if results.ShouldDeleteCount > 0 || results.ShouldDeleteRepositoriesCount > 0 {
+				if results.ShouldDeleteCount > 0 {
+					log.Infof("Deleted %.2f%% (%v/%v) of the images", float64(results.ManagedToDeleteCount)/float64(results.ShouldDeleteCount)*100, results.ManagedToDeleteCount, results.ShouldDeleteCount)
+				}
+
+				if results.ScheduledCount > 0 {
+					log.Infof("Scheduled %v image(s) that the registry is going to delete on its own", results.ScheduledCount)
+				}
+
+				if results.SkippedCount > 0 {
+					log.Warnf("Skipped %v image(s) that are protected by the registry", results.SkippedCount)
+				}
+
+				if results.FailedCount > 0 {
+					log.Errorf("Failed to delete %v image(s)", results.FailedCount)
+				}
 
-			if results.ShouldDeleteCount > 0 {
-				log.Infof("Deleted %.2f%% (%v/%v) of the images", float64(results.ManagedToDeleteCount)/float64(results.ShouldDeleteCount)*100, results.ManagedToDeleteCount, results.ShouldDeleteCount)
+				if results.ShouldDeleteRepositoriesCount > 0 {
+					log.Infof("Deleted %v/%v of the repositories", results.ManagedToDeleteRepositoriesCount, results.ShouldDeleteRepositoriesCount)
+				}
 			} else {
 				log.Info("Nothing to do")
 			}
@@ -105,12 +105,13 @@ func addApplyPlanCommonVars(cmd *flag.FlagSet, appOptions *configuration.AppOpti
 	registerStrParameter(cmd, &appOptions.ApplyPlanCommon.GoogleContainerRegistry.Host, "registry", EnvPrefix+"GOOGLE_CONTAINER_REGISTRY_HOST", "", "the registry to clean, e.g. eu.gcr.io")
 
 	password := ""
-	registerStrParameter(cmd, &password, "password", EnvPrefix+"CONTAINER_REGISTRY_PASSWORD", "", "the registry password, access key etc. For GCR it's the output of 'gcloud auth print-access-token', we HIGHLY recommend you use an env variable for this")
+	registerStrParameter(cmd, &password, "password", EnvPrefix+"CONTAINER_REGISTRY_PASSWORD", "", "the registry password, access key etc. For GCR it's the output of 'gcloud auth print-access-token', we HIGHLY recommend you use an env variable for this; when empty, the credentials that 'docker login' or a docker credential helper has stored for the registry are used")
 
 	username := ""
 	registerStrParameter(cmd, &username, "username", EnvPrefix+"CONTAINER_REGISTRY_USERNAME", "", "the registry username, not all registries require this, e.g. GCR does not")
 
 	registerStrParameter(cmd, &appOptions.ApplyPlanCommon.Keep.YoungerThan, "keep-younger-than", EnvPrefix+"KEEP_YOUNGER_THAN", "", "images younger than this value will be kept; provide a duration value, e.g. '10d', '1w3d' or '1d3h'")
+	registerStrParameter(cmd, &appOptions.ApplyPlanCommon.Keep.PulledWithin, "keep-pulled-within", EnvPrefix+"KEEP_PULLED_WITHIN", "", "images pulled within this duration will be kept, e.g. '30d'; only for registries that track pulls")
 
 	atLeastStr := ""
 	registerStrParameter(cmd, &atLeastStr, "keep-at-least", EnvPrefix+"KEEP_AT_LEAST", "", "at least that many images will be kept in this specific repo, prioritising the younger ones")
@@ -122,15 +123,28 @@ func addApplyPlanCommonVars(cmd *flag.FlagSet, appOptions *configuration.AppOpti
 
 	registerStrParameter(cmd, &k8sClustersStr, "keep-used-in-k8s", EnvPrefix+"KEEP_USED_IN_K8S", "", "comma-separated list of k8s contexts; any image that is used by these clusters won't be deleted")
 
-	registerStrParameter(cmd, &imageTags, "keep-image-tags", EnvPrefix+"KEEP_IMAGE_TAGS", "", "comma-separated list of tags; images with any of these tags will be kept")
+	registerStrParameter(cmd, &imageTags, "keep-image-tags", EnvPrefix+"KEEP_IMAGE_TAGS", "", "comma-separated list of tags, globs like 'prod-*' or regular expressions prefixed with re:; images with any of these tags will be kept")
 
-	registerStrParameter(cmd, &imageDigests, "keep-image-digests", EnvPrefix+"KEEP_IMAGE_DIGESTS", "", "comma-separated list of digests; images with these digests will be kept")
+	registerStrParameter(cmd, &imageDigests, "keep-image-digests", EnvPrefix+"KEEP_IMAGE_DIGESTS", "", "comma-separated list of digests, globs or regular expressions prefixed with re:; images with these digests will be kept")
 
-	registerStrParameter(cmd, &imageIDs, "keep-image-repos", EnvPrefix+"KEEP_IMAGE_REPOS", "", "comma-separated list of repos; images with in these repos will be kept")
+	registerStrParameter(cmd, &imageIDs, "keep-image-repos", EnvPrefix+"KEEP_IMAGE_REPOS", "", "comma-separated list of repos, globs or regular expressions prefixed with re:; images with in these repos will be kept")
+
+	includeRepos := ""
+	excludeRepos := ""
+
+	registerStrParameter(cmd, &includeRepos, "include-repos", EnvPrefix+"INCLUDE_REPOS", "", "comma-separated list of repository patterns, globs or regular expressions prefixed with re:; only the matching repositories are looked into")
+
+	registerStrParameter(cmd, &excludeRepos, "exclude-repos", EnvPrefix+"EXCLUDE_REPOS", "", "comma-separated list of repository patterns, globs or regular expressions prefixed with re:; the matching repositories are never looked into")
+
+	registerBoolParameter(cmd, &appOptions.ApplyPlanCommon.DeleteRepositories.Empty, "delete-empty-repos", EnvPrefix+"DELETE_EMPTY_REPOS", false, "delete the repositories that have no images left after the apply; only for registries that can delete repositories")
+
+	registerStrParameter(cmd, &appOptions.ApplyPlanCommon.DeleteRepositories.InactiveFor, "delete-repos-inactive-for", EnvPrefix+"DELETE_REPOS_INACTIVE_FOR", "", "delete the repositories that nothing has been pushed to for longer than this duration, e.g. '1y'; only for registries that can delete repositories")
+
+	registerBoolParameter(cmd, &appOptions.ApplyPlanCommon.Enrich, "enrich", EnvPrefix+"ENRICH", false, "fetch the manifest and the configuration of each image, for its labels, annotations, platform, layers and creation time; only for registries that can fetch them")
 
 	safeParseArguments(cmd, args)
 
-	appOptions.ApplyPlanCommon.Keep.AtLeast = 0
+	appOptions.ApplyPlanCommon.Keep.AtLeast = nil
 	if atLeastStr != "" {
 		atLeast, err := strconv.Atoi(atLeastStr)
 
@@ -138,7 +152,7 @@ func addApplyPlanCommonVars(cmd *flag.FlagSet, appOptions *configuration.AppOpti
 			log.Fatalf("Could not convert keep-at-least value '%s' to integer", atLeastStr)
 		}
 
-		appOptions.ApplyPlanCommon.Keep.AtLeast = atLeast
+		appOptions.ApplyPlanCommon.Keep.AtLeast = &atLeast
 	}
 
 	if len(k8sClustersStr) > 0 {
@@ -169,15 +183,82 @@ func addApplyPlanCommonVars(cmd *flag.FlagSet, appOptions *configuration.AppOpti
 		copy(appOptions.ApplyPlanCommon.Keep.Image.Repositories, imageIDsArr)
 	}
 
+	if len(includeRepos) > 0 {
+		appOptions.ApplyPlanCommon.Repositories.Include = strings.Split(includeRepos, ",")
+	}
+
+	if len(excludeRepos) > 0 {
+		appOptions.ApplyPlanCommon.Repositories.Exclude = strings.Split(excludeRepos, ",")
+	}
+
 	if appOptions.ApplyPlanCommon.Config != "" {
 		replaceMissingAppOptionsFromConfig(appOptions, appOptions.ApplyPlanCommon.Config)
 	}
 
+	// the cli and the environment override the credentials of the configuration file, but only when they are given
 	if configuration.IsGCR(appOptions) {
-		appOptions.ApplyPlanCommon.GoogleContainerRegistry.Token = password
+		if password != "" {
+			appOptions.ApplyPlanCommon.GoogleContainerRegistry.Token = password
+		}
+	} else if configuration.IsArtifactRegistry(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.ArtifactRegistry.Token = password
+		}
+	} else if configuration.IsACR(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.AzureContainerRegistry.Password = password
+		}
+		if username != "" {
+			appOptions.ApplyPlanCommon.AzureContainerRegistry.Username = username
+		}
 	} else if configuration.IsDockerhub(appOptions) {
-		appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Password = password
-		appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Username = username
+		if password != "" {
+			appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Password = password
+		}
+		if username != "" {
+			appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Username = username
+		}
+	} else if configuration.IsGHCR(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.GitHubContainerRegistry.Token = password
+		}
+	} else if configuration.IsGitLab(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.GitLabContainerRegistry.Token = password
+		}
+	} else if configuration.IsQuay(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.QuayContainerRegistry.Token = password
+		}
+	} else if configuration.IsHarbor(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.HarborContainerRegistry.Password = password
+		}
+		if username != "" {
+			appOptions.ApplyPlanCommon.HarborContainerRegistry.Username = username
+		}
+	} else if configuration.IsOCI(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.OCIContainerRegistry.Password = password
+		}
+		if username != "" {
+			appOptions.ApplyPlanCommon.OCIContainerRegistry.Username = username
+		}
+	} else if configuration.IsDriver(appOptions) {
+		if password != "" {
+			appOptions.ApplyPlanCommon.DriverRegistry.Password = password
+		}
+		if username != "" {
+			appOptions.ApplyPlanCommon.DriverRegistry.Username = username
+		}
+	}
+
+	replaceMissingCredentialsFromDockerConfig(appOptions)
+
+	for i, target := range appOptions.ApplyPlanCommon.Targets {
+		targetOptions := appOptions.ForTarget(target)
+		replaceMissingCredentialsFromDockerConfig(&targetOptions)
+		appOptions.ApplyPlanCommon.Targets[i].Registries = configuration.RegistriesOf(targetOptions.ApplyPlanCommon)
 	}
 }
 
@@ -227,6 +308,7 @@ func Parse(args []string) (configuration.AppOptions, error) {
 			showCmd := flag.NewFlagSet(showSubCmd, flag.ExitOnError)
 			registerStrParameter(showCmd, &appOptions.Show.Plan, "plan", EnvPrefix+"PLAN", "plan.out", "the plan file to show")
 			registerBoolParameter(showCmd, &appOptions.Show.Analytical, "analytical", EnvPrefix+"ANALYTICAL", false, "print the whole plan, not an aggregation")
+			registerBoolParameter(showCmd, &appOptions.Show.Tree, "tree", EnvPrefix+"TREE", false, "roll the deletions up to each level of the repository paths")
 			safeParseArguments(showCmd, args)
 		},
 	}
@@ -4,6 +4,8 @@ import (
 	"os"
 	"reflect"
 	"testing"
+
+	"github.com/hytromo/faulty-crane/internal/configuration"
 )
 
 func TestPlan(t *testing.T) {
@@ -52,7 +54,7 @@ func TestPlan(t *testing.T) {
 		t.Error("Dockerhub passname should be pass")
 	}
 
-	if cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace != "namespace" {
+	if !reflect.DeepEqual(cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace, configuration.StringList{"namespace"}) {
 		t.Error("Dockerhub namespace should be namespace")
 	}
 
@@ -68,7 +70,7 @@ func TestPlan(t *testing.T) {
 		t.Error("Wrong keep image tags")
 	}
 
-	if cliOptions.ApplyPlanCommon.Keep.AtLeast != 10 {
+	if cliOptions.ApplyPlanCommon.Keep.AtLeastCount() != 10 {
 		t.Error("Wrong keep at least")
 	}
 
@@ -138,7 +140,7 @@ func TestApply(t *testing.T) {
 		t.Error("Dockerhub passname should be pass")
 	}
 
-	if cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace != "namespace" {
+	if !reflect.DeepEqual(cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace, configuration.StringList{"namespace"}) {
 		t.Error("Dockerhub namespace should be namespace")
 	}
 
@@ -154,7 +156,7 @@ func TestApply(t *testing.T) {
 		t.Error("Wrong keep image tags")
 	}
 
-	if cliOptions.ApplyPlanCommon.Keep.AtLeast != 10 {
+	if cliOptions.ApplyPlanCommon.Keep.AtLeastCount() != 10 {
 		t.Error("Wrong keep at least")
 	}
 
@@ -309,7 +311,7 @@ func TestOptionsOrder(t *testing.T) {
 		t.Error("Dockerhub passname should be pass")
 	}
 
-	if cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace != "namespace" {
+	if !reflect.DeepEqual(cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace, configuration.StringList{"namespace"}) {
 		t.Error("Dockerhub namespace should be namespace")
 	}
 
@@ -325,7 +327,7 @@ func TestOptionsOrder(t *testing.T) {
 		t.Error("Wrong keep image tags")
 	}
 
-	if cliOptions.ApplyPlanCommon.Keep.AtLeast != 10 {
+	if cliOptions.ApplyPlanCommon.Keep.AtLeastCount() != 10 {
 		t.Error("Wrong keep at least")
 	}
 
@@ -362,3 +364,54 @@ func TestWrongCommand(t *testing.T) {
 		t.Error("Invalid subcommand should error out")
 	}
 }
+
+func TestDockerConfigCredentials(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv(EnvPrefix+"CONTAINER_REGISTRY_USERNAME", "")
+
+	// user:dockerPassword
+	err := os.WriteFile(configDir+"/config.json", []byte(`{"auths": {"https://index.docker.io/v1/": {"auth": "dXNlcjpkb2NrZXJQYXNzd29yZA=="}}}`), 0600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cliOptions, err := Parse([]string{"app", "plan",
+		"-config", "../../test/config.json",
+		"-out", "plan.out",
+	})
+
+	if err != nil {
+		t.Error("Err should be nil")
+	}
+
+	if cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Password != "dockerPassword" {
+		t.Error("The missing Dockerhub password should come from the docker config")
+	}
+
+	if cliOptions.ApplyPlanCommon.DockerhubContainerRegistry.Username != "overrideme" {
+		t.Error("The Dockerhub username of the configuration file should not be replaced")
+	}
+}
+
+func TestRepositoryScope(t *testing.T) {
+	cliOptions, err := Parse([]string{"app", "plan",
+		"-config", "../../test/config.json",
+		"-out", "plan.out",
+		"-include-repos", "team/**,re:^tools/.*$",
+		"-exclude-repos", "team/legacy-*",
+	})
+
+	if err != nil {
+		t.Error("Err should be nil")
+	}
+
+	if !reflect.DeepEqual(cliOptions.ApplyPlanCommon.Repositories.Include, []string{"team/**", "re:^tools/.*$"}) {
+		t.Errorf("Wrong included repositories %v", cliOptions.ApplyPlanCommon.Repositories.Include)
+	}
+
+	if !reflect.DeepEqual(cliOptions.ApplyPlanCommon.Repositories.Exclude, []string{"team/legacy-*"}) {
+		t.Errorf("Wrong excluded repositories %v", cliOptions.ApplyPlanCommon.Repositories.Exclude)
+	}
+}
@@ -30,11 +30,19 @@ func replaceMissingAppOptionsFromConfig(appOptions *configuration.AppOptions, co
 		appOptions.ApplyPlanCommon.GoogleContainerRegistry.Host = configOptions.GCR.Host
 	}
 
+	if appOptions.ApplyPlanCommon.GoogleContainerRegistry.Project == "" {
+		appOptions.ApplyPlanCommon.GoogleContainerRegistry.Project = configOptions.GCR.Project
+	}
+
 	if appOptions.ApplyPlanCommon.GoogleContainerRegistry.Token == "" {
 		appOptions.ApplyPlanCommon.GoogleContainerRegistry.Token = configOptions.GCR.Token
 	}
 
-	if appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace == "" {
+	if !appOptions.ApplyPlanCommon.GoogleContainerRegistry.TokenSource.HasTokenSource() {
+		appOptions.ApplyPlanCommon.GoogleContainerRegistry.TokenSource = configOptions.GCR.TokenSource
+	}
+
+	if len(appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace) == 0 {
 		appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Namespace = configOptions.Dockerhub.Namespace
 	}
 
@@ -46,6 +54,169 @@ func replaceMissingAppOptionsFromConfig(appOptions *configuration.AppOptions, co
 		appOptions.ApplyPlanCommon.DockerhubContainerRegistry.Password = configOptions.Dockerhub.Password
 	}
 
+	if !appOptions.ApplyPlanCommon.DockerhubContainerRegistry.DeleteManifests {
+		appOptions.ApplyPlanCommon.DockerhubContainerRegistry.DeleteManifests = configOptions.Dockerhub.DeleteManifests
+	}
+
+	if appOptions.ApplyPlanCommon.OCIContainerRegistry.Host == "" {
+		appOptions.ApplyPlanCommon.OCIContainerRegistry.Host = configOptions.OCI.Host
+	}
+
+	if appOptions.ApplyPlanCommon.OCIContainerRegistry.Username == "" {
+		appOptions.ApplyPlanCommon.OCIContainerRegistry.Username = configOptions.OCI.Username
+	}
+
+	if appOptions.ApplyPlanCommon.OCIContainerRegistry.Password == "" {
+		appOptions.ApplyPlanCommon.OCIContainerRegistry.Password = configOptions.OCI.Password
+	}
+
+	if appOptions.ApplyPlanCommon.ArtifactRegistry.Project == "" {
+		appOptions.ApplyPlanCommon.ArtifactRegistry.Project = configOptions.ArtifactRegistry.Project
+	}
+
+	if appOptions.ApplyPlanCommon.ArtifactRegistry.Location == "" {
+		appOptions.ApplyPlanCommon.ArtifactRegistry.Location = configOptions.ArtifactRegistry.Location
+	}
+
+	if appOptions.ApplyPlanCommon.ArtifactRegistry.Token == "" {
+		appOptions.ApplyPlanCommon.ArtifactRegistry.Token = configOptions.ArtifactRegistry.Token
+	}
+
+	if appOptions.ApplyPlanCommon.ArtifactRegistry.Endpoint == "" {
+		appOptions.ApplyPlanCommon.ArtifactRegistry.Endpoint = configOptions.ArtifactRegistry.Endpoint
+	}
+
+	if appOptions.ApplyPlanCommon.GitHubContainerRegistry.Owner == "" {
+		appOptions.ApplyPlanCommon.GitHubContainerRegistry.Owner = configOptions.GHCR.Owner
+	}
+
+	if appOptions.ApplyPlanCommon.GitHubContainerRegistry.Token == "" {
+		appOptions.ApplyPlanCommon.GitHubContainerRegistry.Token = configOptions.GHCR.Token
+	}
+
+	if appOptions.ApplyPlanCommon.GitHubContainerRegistry.Endpoint == "" {
+		appOptions.ApplyPlanCommon.GitHubContainerRegistry.Endpoint = configOptions.GHCR.Endpoint
+	}
+
+	if appOptions.ApplyPlanCommon.GitLabContainerRegistry.Host == "" {
+		appOptions.ApplyPlanCommon.GitLabContainerRegistry.Host = configOptions.GitLab.Host
+	}
+
+	if appOptions.ApplyPlanCommon.GitLabContainerRegistry.Group == "" {
+		appOptions.ApplyPlanCommon.GitLabContainerRegistry.Group = configOptions.GitLab.Group
+	}
+
+	if appOptions.ApplyPlanCommon.GitLabContainerRegistry.Token == "" {
+		appOptions.ApplyPlanCommon.GitLabContainerRegistry.Token = configOptions.GitLab.Token
+	}
+
+	if !appOptions.ApplyPlanCommon.GitLabContainerRegistry.BulkDelete {
+		appOptions.ApplyPlanCommon.GitLabContainerRegistry.BulkDelete = configOptions.GitLab.BulkDelete
+	}
+
+	if appOptions.ApplyPlanCommon.QuayContainerRegistry.Host == "" {
+		appOptions.ApplyPlanCommon.QuayContainerRegistry.Host = configOptions.Quay.Host
+	}
+
+	if appOptions.ApplyPlanCommon.QuayContainerRegistry.Organization == "" {
+		appOptions.ApplyPlanCommon.QuayContainerRegistry.Organization = configOptions.Quay.Organization
+	}
+
+	if appOptions.ApplyPlanCommon.QuayContainerRegistry.Token == "" {
+		appOptions.ApplyPlanCommon.QuayContainerRegistry.Token = configOptions.Quay.Token
+	}
+
+	if appOptions.ApplyPlanCommon.HarborContainerRegistry.Host == "" {
+		appOptions.ApplyPlanCommon.HarborContainerRegistry.Host = configOptions.Harbor.Host
+	}
+
+	if appOptions.ApplyPlanCommon.HarborContainerRegistry.Project == "" {
+		appOptions.ApplyPlanCommon.HarborContainerRegistry.Project = configOptions.Harbor.Project
+	}
+
+	if appOptions.ApplyPlanCommon.HarborContainerRegistry.Username == "" {
+		appOptions.ApplyPlanCommon.HarborContainerRegistry.Username = configOptions.Harbor.Username
+	}
+
+	if appOptions.ApplyPlanCommon.HarborContainerRegistry.Password == "" {
+		appOptions.ApplyPlanCommon.HarborContainerRegistry.Password = configOptions.Harbor.Password
+	}
+
+	if appOptions.ApplyPlanCommon.ElasticContainerRegistry.Region == "" {
+		appOptions.ApplyPlanCommon.ElasticContainerRegistry.Region = configOptions.ECR.Region
+	}
+
+	if appOptions.ApplyPlanCommon.ElasticContainerRegistry.RegistryID == "" {
+		appOptions.ApplyPlanCommon.ElasticContainerRegistry.RegistryID = configOptions.ECR.RegistryID
+	}
+
+	if appOptions.ApplyPlanCommon.ElasticContainerRegistry.Profile == "" {
+		appOptions.ApplyPlanCommon.ElasticContainerRegistry.Profile = configOptions.ECR.Profile
+	}
+
+	if appOptions.ApplyPlanCommon.ElasticContainerRegistry.Endpoint == "" {
+		appOptions.ApplyPlanCommon.ElasticContainerRegistry.Endpoint = configOptions.ECR.Endpoint
+	}
+
+	if appOptions.ApplyPlanCommon.AzureContainerRegistry.Host == "" {
+		appOptions.ApplyPlanCommon.AzureContainerRegistry.Host = configOptions.ACR.Host
+	}
+
+	if appOptions.ApplyPlanCommon.AzureContainerRegistry.TenantID == "" {
+		appOptions.ApplyPlanCommon.AzureContainerRegistry.TenantID = configOptions.ACR.TenantID
+	}
+
+	if appOptions.ApplyPlanCommon.AzureContainerRegistry.Username == "" {
+		appOptions.ApplyPlanCommon.AzureContainerRegistry.Username = configOptions.ACR.Username
+	}
+
+	if appOptions.ApplyPlanCommon.AzureContainerRegistry.Password == "" {
+		appOptions.ApplyPlanCommon.AzureContainerRegistry.Password = configOptions.ACR.Password
+	}
+
+	if appOptions.ApplyPlanCommon.DriverRegistry.Name == "" {
+		appOptions.ApplyPlanCommon.DriverRegistry.Name = configOptions.Driver.Name
+	}
+
+	if appOptions.ApplyPlanCommon.DriverRegistry.Path == "" {
+		appOptions.ApplyPlanCommon.DriverRegistry.Path = configOptions.Driver.Path
+	}
+
+	if appOptions.ApplyPlanCommon.DriverRegistry.Username == "" {
+		appOptions.ApplyPlanCommon.DriverRegistry.Username = configOptions.Driver.Username
+	}
+
+	if appOptions.ApplyPlanCommon.DriverRegistry.Password == "" {
+		appOptions.ApplyPlanCommon.DriverRegistry.Password = configOptions.Driver.Password
+	}
+
+	if len(appOptions.ApplyPlanCommon.DriverRegistry.Options) == 0 {
+		appOptions.ApplyPlanCommon.DriverRegistry.Options = configOptions.Driver.Options
+	}
+
+	// the targets can only be given through the configuration file
+	appOptions.ApplyPlanCommon.Targets = configOptions.Targets
+
+	if len(appOptions.ApplyPlanCommon.Repositories.Include) == 0 {
+		appOptions.ApplyPlanCommon.Repositories.Include = configOptions.Repositories.Include
+	}
+
+	if len(appOptions.ApplyPlanCommon.Repositories.Exclude) == 0 {
+		appOptions.ApplyPlanCommon.Repositories.Exclude = configOptions.Repositories.Exclude
+	}
+
+	if !appOptions.ApplyPlanCommon.DeleteRepositories.Empty {
+		appOptions.ApplyPlanCommon.DeleteRepositories.Empty = configOptions.DeleteRepositories.Empty
+	}
+
+	if appOptions.ApplyPlanCommon.DeleteRepositories.InactiveFor == "" {
+		appOptions.ApplyPlanCommon.DeleteRepositories.InactiveFor = configOptions.DeleteRepositories.InactiveFor
+	}
+
+	if !appOptions.ApplyPlanCommon.Enrich {
+		appOptions.ApplyPlanCommon.Enrich = configOptions.Enrich
+	}
+
 	if len(appOptions.ApplyPlanCommon.Keep.UsedIn.KubernetesClusters) == 0 {
 		appOptions.ApplyPlanCommon.Keep.UsedIn.KubernetesClusters = configOptions.Keep.UsedIn.KubernetesClusters
 	}
@@ -54,7 +225,11 @@ func replaceMissingAppOptionsFromConfig(appOptions *configuration.AppOptions, co
 		appOptions.ApplyPlanCommon.Keep.YoungerThan = configOptions.Keep.YoungerThan
 	}
 
-	if appOptions.ApplyPlanCommon.Keep.AtLeast == 0 {
+	if appOptions.ApplyPlanCommon.Keep.PulledWithin == "" {
+		appOptions.ApplyPlanCommon.Keep.PulledWithin = configOptions.Keep.PulledWithin
+	}
+
+	if appOptions.ApplyPlanCommon.Keep.AtLeast == nil {
 		appOptions.ApplyPlanCommon.Keep.AtLeast = configOptions.Keep.AtLeast
 	}
 
@@ -69,4 +244,7 @@ func replaceMissingAppOptionsFromConfig(appOptions *configuration.AppOptions, co
 	if len(appOptions.ApplyPlanCommon.Keep.Image.Repositories) == 0 {
 		appOptions.ApplyPlanCommon.Keep.Image.Repositories = configOptions.Keep.Image.Repositories
 	}
+
+	// the path rules can only be given through the configuration file
+	appOptions.ApplyPlanCommon.Keep.Paths = configOptions.Keep.Paths
 }
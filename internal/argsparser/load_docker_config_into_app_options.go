@@ -0,0 +1,106 @@
+package argsparser
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/hytromo/faulty-crane/internal/configuration"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/acr"
+	"github.com/hytromo/faulty-crane/internal/dockerconfig"
+)
+
+// lookupDockerCredentials returns the credentials that docker login has stored for the first of the registries that has any
+func lookupDockerCredentials(registries ...string) (dockerconfig.Credentials, bool) {
+	for _, registry := range registries {
+		if registry == "" {
+			continue
+		}
+
+		credentials, found, err := dockerconfig.Lookup(registry)
+
+		if err != nil {
+			log.Warnf("Could not read the docker credentials of %v: %v", registry, err)
+			continue
+		}
+
+		if found {
+			log.Debugf("Using the docker credentials of %v from the %v", registry, credentials.Source)
+			return credentials, true
+		}
+	}
+
+	return dockerconfig.Credentials{}, false
+}
+
+// replaceMissingCredentialsFromDockerConfig fills in the registry credentials that were not given through the cli, the environment or the configuration file with the ones of the docker config file, e.g. after a docker login or through a credential helper
+func replaceMissingCredentialsFromDockerConfig(appOptions *configuration.AppOptions) {
+	config := &appOptions.ApplyPlanCommon
+
+	if configuration.IsGCR(appOptions) {
+		if config.GoogleContainerRegistry.Token == "" && !config.GoogleContainerRegistry.TokenSource.HasTokenSource() {
+			// all the gcr hosts share the same google credentials
+			if credentials, found := lookupDockerCredentials(config.GoogleContainerRegistry.Host, "gcr.io"); found {
+				config.GoogleContainerRegistry.Token = credentials.Password
+			}
+		}
+	} else if configuration.IsArtifactRegistry(appOptions) {
+		if config.ArtifactRegistry.Token == "" {
+			if credentials, found := lookupDockerCredentials(config.ArtifactRegistry.Location + "-docker.pkg.dev"); found {
+				config.ArtifactRegistry.Token = credentials.Password
+			}
+		}
+	} else if configuration.IsACR(appOptions) {
+		if config.AzureContainerRegistry.Password == "" {
+			if credentials, found := lookupDockerCredentials(config.AzureContainerRegistry.Host); found {
+				config.AzureContainerRegistry.Username = credentials.Username
+				config.AzureContainerRegistry.Password = credentials.Password
+
+				if credentials.IdentityToken {
+					// az acr login stores a refresh token of the registry
+					config.AzureContainerRegistry.Username = acr.RefreshTokenUsername
+				}
+			}
+		}
+	} else if configuration.IsDockerhub(appOptions) {
+		if config.DockerhubContainerRegistry.Password == "" {
+			if credentials, found := lookupDockerCredentials("docker.io"); found && !credentials.IdentityToken {
+				config.DockerhubContainerRegistry.Password = credentials.Password
+
+				if config.DockerhubContainerRegistry.Username == "" {
+					config.DockerhubContainerRegistry.Username = credentials.Username
+				}
+			}
+		}
+	} else if configuration.IsGHCR(appOptions) {
+		if config.GitHubContainerRegistry.Token == "" {
+			if credentials, found := lookupDockerCredentials("ghcr.io"); found {
+				config.GitHubContainerRegistry.Token = credentials.Password
+			}
+		}
+	} else if configuration.IsGitLab(appOptions) {
+		// the registry of a GitLab instance usually lives on its own subdomain
+		if config.GitLabContainerRegistry.Token == "" {
+			host := strings.TrimPrefix(strings.TrimPrefix(config.GitLabContainerRegistry.Host, "https://"), "http://")
+
+			if credentials, found := lookupDockerCredentials("registry."+host, host); found {
+				config.GitLabContainerRegistry.Token = credentials.Password
+			}
+		}
+	} else if configuration.IsHarbor(appOptions) {
+		if config.HarborContainerRegistry.Password == "" {
+			if credentials, found := lookupDockerCredentials(config.HarborContainerRegistry.Host); found && !credentials.IdentityToken {
+				config.HarborContainerRegistry.Username = credentials.Username
+				config.HarborContainerRegistry.Password = credentials.Password
+			}
+		}
+	} else if configuration.IsOCI(appOptions) {
+		if config.OCIContainerRegistry.Password == "" {
+			if credentials, found := lookupDockerCredentials(config.OCIContainerRegistry.Host); found && !credentials.IdentityToken {
+				config.OCIContainerRegistry.Username = credentials.Username
+				config.OCIContainerRegistry.Password = credentials.Password
+			}
+		}
+	}
+	// ECR requests are signed with the aws credentials and the quay api needs an oauth token instead of the docker credentials, so these two do not use the docker config file
+}
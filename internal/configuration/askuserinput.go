@@ -48,6 +48,18 @@ func askContainerRegistryNamespace(readDevice io.Reader) string {
 	}
 }
 
+func askContainerRegistryNamespaces(readDevice io.Reader) []string {
+	for {
+		namespaces := askListOfStrings(readDevice, "Namespace (e.g. organization name or your own username)")
+
+		if len(namespaces) == 0 {
+			fmt.Println("At least one namespace is required")
+		} else {
+			return namespaces
+		}
+	}
+}
+
 func askContainerRegistryUsername(readDevice io.Reader) string {
 	for {
 		username := ask.Str(ask.Question{
@@ -66,7 +78,7 @@ func askContainerRegistryUsername(readDevice io.Reader) string {
 func askContainerType(readDevice io.Reader) string {
 	return ask.Str(ask.Question{
 		Description:     fmt.Sprintf("Container %v", color.Green("registry type")),
-		PossibleAnswers: []string{"gcr", "dockerhub"},
+		PossibleAnswers: []string{"gcr", "artifactregistry", "ecr", "acr", "dockerhub", "ghcr", "gitlab", "quay", "harbor", "oci"},
 		ReadDevice:      readDevice,
 	})
 }
@@ -81,9 +93,136 @@ func askContainerRegistryLink(readDevice io.Reader) string {
 	})
 }
 
+func askContainerRegistryHost(readDevice io.Reader) string {
+	for {
+		host := ask.Str(ask.Question{
+			Description: fmt.Sprintf("Container %v for cleanup (e.g. registry.example.com:5000 or http://localhost:5000)", color.Green("registry host")),
+			ReadDevice:  readDevice,
+		})
+
+		if host == "" {
+			fmt.Println("A host is required")
+		} else {
+			return host
+		}
+	}
+}
+
+func askContainerRegistryProject(readDevice io.Reader) string {
+	for {
+		project := ask.Str(ask.Question{
+			Description: fmt.Sprintf("Google cloud %v", color.Green("project id")),
+			ReadDevice:  readDevice,
+		})
+
+		if project == "" {
+			fmt.Println("A project is required")
+		} else {
+			return project
+		}
+	}
+}
+
+func askContainerRegistryLocation(readDevice io.Reader) string {
+	for {
+		location := ask.Str(ask.Question{
+			Description: fmt.Sprintf("Repositories %v (e.g. europe-west1, us)", color.Green("location")),
+			ReadDevice:  readDevice,
+		})
+
+		if location == "" {
+			fmt.Println("A location is required")
+		} else {
+			return location
+		}
+	}
+}
+
+func askAWSRegion(readDevice io.Reader) string {
+	for {
+		region := ask.Str(ask.Question{
+			Description: fmt.Sprintf("AWS %v (e.g. eu-west-1)", color.Green("region")),
+			ReadDevice:  readDevice,
+		})
+
+		if region == "" {
+			fmt.Println("A region is required")
+		} else {
+			return region
+		}
+	}
+}
+
+func askAzureTenant(readDevice io.Reader) string {
+	return ask.Str(ask.Question{
+		Description: fmt.Sprintf("Azure %v of the service principal (empty=admin credentials)", color.Green("tenant id")),
+		ReadDevice:  readDevice,
+	})
+}
+
+func askGitLabURL(readDevice io.Reader) string {
+	return ask.Str(ask.Question{
+		Description:  fmt.Sprintf("GitLab %v", color.Green("url")),
+		DefaultValue: "https://gitlab.com",
+		ReadDevice:   readDevice,
+	})
+}
+
+func askGitLabGroup(readDevice io.Reader) string {
+	for {
+		group := ask.Str(ask.Question{
+			Description: fmt.Sprintf("GitLab %v (e.g. my-group/sub-group)", color.Green("group")),
+			ReadDevice:  readDevice,
+		})
+
+		if group == "" {
+			fmt.Println("A group is required")
+		} else {
+			return group
+		}
+	}
+}
+
+func askQuayHost(readDevice io.Reader) string {
+	return ask.Str(ask.Question{
+		Description:  fmt.Sprintf("Quay %v", color.Green("host")),
+		DefaultValue: "quay.io",
+		ReadDevice:   readDevice,
+	})
+}
+
+func askQuayOrganization(readDevice io.Reader) string {
+	for {
+		organization := ask.Str(ask.Question{
+			Description: fmt.Sprintf("Quay %v", color.Green("organization")),
+			ReadDevice:  readDevice,
+		})
+
+		if organization == "" {
+			fmt.Println("An organization is required")
+		} else {
+			return organization
+		}
+	}
+}
+
+func askHarborProject(readDevice io.Reader) string {
+	return ask.Str(ask.Question{
+		Description: fmt.Sprintf("Harbor %v (empty=all projects)", color.Green("project")),
+		ReadDevice:  readDevice,
+	})
+}
+
+func askOptionalContainerRegistryUsername(readDevice io.Reader) string {
+	return ask.Str(ask.Question{
+		Description: "Username (empty=anonymous)",
+		ReadDevice:  readDevice,
+	})
+}
+
 func askContainerRegistryPassword(readDevice io.Reader, containerRegistryLink string) string {
 	return ask.Str(ask.Question{
-		Description: fmt.Sprintf("%v (e.g. `gcloud auth print-access-token` for gcr, password for dockerhub)", color.Green("Access token")),
+		Description: fmt.Sprintf("%v (e.g. `gcloud auth print-access-token` for gcr and artifact registry, password or access token for dockerhub, password for harbor and oci, service principal secret or admin password for acr, personal access token for ghcr, access token with api scope for gitlab, oauth application token for quay)", color.Green("Access token")),
 		ReadDevice:  readDevice,
 	})
 }
@@ -129,15 +268,21 @@ func askImageIds(readDevice io.Reader) []string {
 
 // UserInput is a struct holding the user's answers
 type UserInput struct {
+	ContainerRegistryType      string
 	ContainerRegistryLink      string
 	ContainerRegistryUsername  string
 	ContainerRegistryPassword  string
 	ContainerRegistryNamespace string
-	YoungerThan                string
-	KubernetesClusters         []string
-	ImageTags                  []string
-	ImageDigests               []string
-	ImageIDs                   []string
+	// ContainerRegistryNamespaces is used by the registries that can clean many namespaces at once
+	ContainerRegistryNamespaces []string
+	ContainerRegistryProject    string
+	ContainerRegistryLocation   string
+	ContainerRegistryTenant     string
+	YoungerThan                 string
+	KubernetesClusters          []string
+	ImageTags                   []string
+	ImageDigests                []string
+	ImageIDs                    []string
 }
 
 // AskUserInput asks for user input in order to create a new configuration
@@ -146,24 +291,63 @@ func AskUserInput(readDevice io.Reader) UserInput {
 	containerRegistryLink := ""
 	containerRegistryUsername := ""
 	containerRegistryNamespace := ""
+	containerRegistryNamespaces := []string{}
+	containerRegistryProject := ""
+	containerRegistryLocation := ""
+	containerRegistryTenant := ""
 
 	if containerType == "gcr" {
 		containerRegistryLink = askContainerRegistryLink(readDevice)
+	} else if containerType == "artifactregistry" {
+		containerRegistryProject = askContainerRegistryProject(readDevice)
+		containerRegistryLocation = askContainerRegistryLocation(readDevice)
+	} else if containerType == "acr" {
+		containerRegistryLink = askContainerRegistryHost(readDevice)
+		containerRegistryTenant = askAzureTenant(readDevice)
+		containerRegistryUsername = askContainerRegistryUsername(readDevice)
+	} else if containerType == "ecr" {
+		containerRegistryLocation = askAWSRegion(readDevice)
 	} else if containerType == "dockerhub" {
 		containerRegistryUsername = askContainerRegistryUsername(readDevice)
+		containerRegistryNamespaces = askContainerRegistryNamespaces(readDevice)
+	} else if containerType == "ghcr" {
 		containerRegistryNamespace = askContainerRegistryNamespace(readDevice)
+	} else if containerType == "gitlab" {
+		containerRegistryLink = askGitLabURL(readDevice)
+		containerRegistryNamespace = askGitLabGroup(readDevice)
+	} else if containerType == "quay" {
+		containerRegistryLink = askQuayHost(readDevice)
+		containerRegistryNamespace = askQuayOrganization(readDevice)
+	} else if containerType == "harbor" {
+		containerRegistryLink = askContainerRegistryHost(readDevice)
+		containerRegistryNamespace = askHarborProject(readDevice)
+		containerRegistryUsername = askContainerRegistryUsername(readDevice)
+	} else if containerType == "oci" {
+		containerRegistryLink = askContainerRegistryHost(readDevice)
+		containerRegistryUsername = askOptionalContainerRegistryUsername(readDevice)
+	}
+
+	containerRegistryPassword := ""
 
+	// ecr requests are signed with the aws credentials, which are never stored in the configuration
+	if containerType != "ecr" {
+		containerRegistryPassword = askContainerRegistryPassword(readDevice, containerRegistryLink)
 	}
 
 	return UserInput{
-		ContainerRegistryLink:      containerRegistryLink,
-		ContainerRegistryPassword:  askContainerRegistryPassword(readDevice, containerRegistryLink),
-		ContainerRegistryUsername:  containerRegistryUsername,
-		ContainerRegistryNamespace: containerRegistryNamespace,
-		YoungerThan:                askYoungerThan(readDevice),
-		KubernetesClusters:         askKubernetesClusters(readDevice),
-		ImageTags:                  askImageTags(readDevice),
-		ImageDigests:               askImageDigests(readDevice),
-		ImageIDs:                   askImageIds(readDevice),
+		ContainerRegistryType:       containerType,
+		ContainerRegistryLink:       containerRegistryLink,
+		ContainerRegistryPassword:   containerRegistryPassword,
+		ContainerRegistryUsername:   containerRegistryUsername,
+		ContainerRegistryNamespace:  containerRegistryNamespace,
+		ContainerRegistryNamespaces: containerRegistryNamespaces,
+		ContainerRegistryProject:    containerRegistryProject,
+		ContainerRegistryLocation:   containerRegistryLocation,
+		ContainerRegistryTenant:     containerRegistryTenant,
+		YoungerThan:                 askYoungerThan(readDevice),
+		KubernetesClusters:          askKubernetesClusters(readDevice),
+		ImageTags:                   askImageTags(readDevice),
+		ImageDigests:                askImageDigests(readDevice),
+		ImageIDs:                    askImageIds(readDevice),
 	}
 }
@@ -7,18 +7,134 @@ type KubernetesCluster struct {
 	RunningInside bool // RunningInside means that faulty-crane is running inside this cluster and thus the k8s client needs specific options to communicate with this cluster
 }
 
+// GoogleTokenSource describes where new google access tokens come from when the current one is about to expire; only one of its fields should be set
+type GoogleTokenSource struct {
+	// Command prints an access token, e.g. gcloud auth print-access-token; it can also print a json object with access_token and expires_in
+	Command string `json:",omitempty"`
+	// ServiceAccountKey is the path of a service account json key that is exchanged for access tokens
+	ServiceAccountKey string `json:",omitempty"`
+	// TokenEndpoint overrides the token endpoint that the service account key is exchanged at
+	TokenEndpoint string `json:",omitempty"`
+	// MetadataServer gets the access tokens of the service account of the gce instance or gke workload that faulty-crane runs on
+	MetadataServer bool `json:",omitempty"`
+}
+
 // GoogleContainerRegistry keeps the needed data for the google container registry
 type GoogleContainerRegistry struct {
-	Host  string
-	Token string
+	Host string
+	// Project sweeps gcr.io, us.gcr.io, eu.gcr.io and asia.gcr.io for the repositories of a gcp project, instead of using Host; the repository links then start with their host, e.g. eu.gcr.io/my-project/app
+	Project string `json:",omitempty"`
+	// Token is a static access token, e.g. the result of `gcloud auth print-access-token`, which expires after an hour; use TokenSource for longer runs
+	Token       string
+	TokenSource GoogleTokenSource `json:",omitempty"`
 }
 
 // DockerhubContainerRegistry keeps the needed data for the google container registry
 type DockerhubContainerRegistry struct {
+	// Username is the docker hub username, or the organization name when using an organization access token
 	Username string
+	// Password can also be a personal or an organization access token, which is required when two-factor authentication is enabled
 	Password string
-	// Namespace is where do you want us to search for images, could be same as the username, could be an org name etc
-	Namespace string
+	// Namespace is where do you want us to search for images, could be same as the username, could be an org name etc; it can be a list of namespaces that are all cleaned in one run
+	Namespace StringList
+	// DeleteManifests also finds the untagged manifests of each repository and deletes the manifests of the images, instead of their tags only, in batches per namespace through the bulk image deletion endpoint, so that they stop counting against the storage
+	DeleteManifests bool `json:",omitempty"`
+}
+
+// OCIContainerRegistry keeps the needed data for any registry that implements the OCI distribution spec, e.g. Harbor or registry:2
+type OCIContainerRegistry struct {
+	// Host is e.g. registry.example.com:5000; https is assumed unless a scheme is specified, e.g. http://localhost:5000
+	Host     string
+	Username string
+	Password string
+}
+
+// ArtifactRegistry keeps the needed data for the google artifact registry (*-docker.pkg.dev)
+type ArtifactRegistry struct {
+	Project string
+	// Location is the region of the repositories, e.g. europe-west1
+	Location string
+	// Token is e.g. the result of `gcloud auth print-access-token`
+	Token string
+	// Endpoint overrides the Artifact Registry API endpoint, empty means the public google endpoint
+	Endpoint string `json:",omitempty"`
+}
+
+// GitHubContainerRegistry keeps the needed data for the github container registry (ghcr.io)
+type GitHubContainerRegistry struct {
+	// Owner is the organization or the user that owns the container packages
+	Owner string
+	// Token is a personal access token with the read:packages and delete:packages scopes
+	Token string
+	// Endpoint overrides the GitHub API endpoint, e.g. for GitHub Enterprise; empty means the public api
+	Endpoint string `json:",omitempty"`
+}
+
+// GitLabContainerRegistry keeps the needed data for the container registries of a GitLab group
+type GitLabContainerRegistry struct {
+	// Host is the url of the GitLab instance, e.g. https://gitlab.example.com
+	Host string
+	// Group is the full path of the group whose projects are going to be cleaned, e.g. my-group/sub-group
+	Group string
+	// Token is an access token with the api scope
+	Token string
+	// BulkDelete deletes the tags of each repository with a single call to the bulk tag deletion endpoint; GitLab allows this once per hour per repository
+	BulkDelete bool `json:",omitempty"`
+}
+
+// QuayContainerRegistry keeps the needed data for the repositories of a Quay.io or Red Hat Quay organization
+type QuayContainerRegistry struct {
+	// Host is the quay host, e.g. https://quay.example.com; empty means quay.io
+	Host string
+	// Organization is the namespace whose repositories are going to be cleaned
+	Organization string
+	// Token is an oauth access token of an application of the organization, with the repo:read and repo:write scopes
+	Token string
+}
+
+// HarborContainerRegistry keeps the needed data for a Harbor registry
+type HarborContainerRegistry struct {
+	// Host is the harbor host, e.g. harbor.example.com
+	Host string
+	// Project limits the cleanup to a single project; empty means all the projects that the user can see
+	Project  string `json:",omitempty"`
+	Username string
+	Password string
+}
+
+// ElasticContainerRegistry keeps the needed data for amazon ecr; the credentials come from the standard aws sources, e.g. the environment or the instance role
+type ElasticContainerRegistry struct {
+	Region string
+	// RegistryID is the aws account id of the registry; empty means the account of the credentials
+	RegistryID string `json:",omitempty"`
+	// Profile is the profile of the shared credentials file to use
+	Profile string `json:",omitempty"`
+	// Endpoint overrides the ecr api endpoint, e.g. for testing against a local endpoint
+	Endpoint string `json:",omitempty"`
+}
+
+// AzureContainerRegistry keeps the needed data for an azure container registry
+type AzureContainerRegistry struct {
+	// Host is the registry host, e.g. myregistry.azurecr.io
+	Host string
+	// TenantID is the azure tenant of the service principal; empty means that the username and the password are the admin credentials of the registry
+	TenantID string `json:",omitempty"`
+	// Username is the client id of the service principal or the admin username
+	Username string
+	// Password is the client secret of the service principal or the admin password
+	Password string
+}
+
+// DriverRegistry keeps the needed data for a registry that is implemented by an external driver executable, see the driver protocol of the containerregistry/driver package
+type DriverRegistry struct {
+	// Name finds the driver in the PATH, e.g. artifactstore runs faulty-crane-driver-artifactstore
+	Name string
+	// Path is the path of the driver executable, instead of finding it by name
+	Path     string `json:",omitempty"`
+	Username string `json:",omitempty"`
+	Password string `json:",omitempty"`
+	// Options are passed as they are to the driver, e.g. the url of the registry
+	Options map[string]string `json:",omitempty"`
 }
 
 // UsedIn defines a list of resources that could use container images
@@ -26,30 +142,94 @@ type UsedIn struct {
 	KubernetesClusters []KubernetesCluster
 }
 
-// Image defines various image-related fields
+// Image defines various image-related fields; the entries are exact names, globs like prod-* or glob:release-*, or regular expressions prefixed with re:
 type Image struct {
-	Tags         []string
-	Digests      []string
+	Tags    []string
+	Digests []string
+	// Repositories are exact repositories, patterns or path prefixes ending in /**, e.g. project/team-a/**
 	Repositories []string
 }
 
+// RepositoryScope limits the repositories of the catalog that are looked into at all, before their images are fetched; the patterns are globs, or regular expressions prefixed with re:
+type RepositoryScope struct {
+	// Include keeps only the repositories that match any of these patterns; empty means all the repositories
+	Include []string `json:",omitempty"`
+	// Exclude drops the repositories that match any of these patterns, even if they are included
+	Exclude []string `json:",omitempty"`
+}
+
+// RepositoryCleanup describes which repositories are deleted as a whole, after their images; only registries that can delete repositories support it
+type RepositoryCleanup struct {
+	// Empty deletes the repositories that have no images left after the apply
+	Empty bool `json:",omitempty"`
+	// InactiveFor deletes the repositories that nothing has been pushed to for longer than e.g. 1y, unless they have images that are kept for a reason other than keeping at least a number of images
+	InactiveFor string `json:",omitempty"`
+}
+
 // KeepImages specifies what conditions we should use in order to keep images from being deleted
 type KeepImages struct {
 	// Keep images younger than e.g. 5d
 	YoungerThan string
-	// Keep at least N images
-	AtLeast int
+	// Keep images pulled within e.g. 30d; only for registries that track pulls
+	PulledWithin string `json:",omitempty"`
+	// Keep at least N images; when it is not set, e.g. in an override, the general value applies
+	AtLeast *int `json:",omitempty"`
 	// Keep the images used in the below contexts
 	UsedIn UsedIn
 	// Keep images with the below image-related characteristics
 	Image Image
+	// Paths override the keep rules for the repositories under a path prefix, e.g. project/team-a/**; the most specific prefix wins
+	Paths []PathKeepImages `json:",omitempty"`
+}
+
+// PathKeepImages are the keep rules of the repositories under a path prefix
+type PathKeepImages struct {
+	// Path is the path prefix, e.g. project/team-a/**
+	Path string
+	// Keep overrides the keep rules for the repositories under the path; only the rules that are set are overridden
+	Keep KeepImages
+}
+
+// Registries are the registry blocks of the configuration file; only one of them should be filled in
+type Registries struct {
+	GCR              GoogleContainerRegistry    `json:",omitempty"`
+	Dockerhub        DockerhubContainerRegistry `json:",omitempty"`
+	OCI              OCIContainerRegistry       `json:",omitempty"`
+	ArtifactRegistry ArtifactRegistry           `json:",omitempty"`
+	GHCR             GitHubContainerRegistry    `json:",omitempty"`
+	GitLab           GitLabContainerRegistry    `json:",omitempty"`
+	Quay             QuayContainerRegistry      `json:",omitempty"`
+	Harbor           HarborContainerRegistry    `json:",omitempty"`
+	ECR              ElasticContainerRegistry   `json:",omitempty"`
+	ACR              AzureContainerRegistry     `json:",omitempty"`
+	Driver           DriverRegistry             `json:",omitempty"`
+}
+
+// RegistryTarget is one of the registries that a single run cleans, with its own credentials and optionally its own keep rules
+type RegistryTarget struct {
+	// Name identifies the registry in the plan and the report, e.g. production-gcr
+	Name string
+	Registries
+	// Keep overrides the keep rules of the configuration for this registry; only the rules that are set are overridden, e.g. an empty list of kubernetes clusters disables the kubernetes scan for this registry
+	Keep *KeepImages `json:",omitempty"`
+	// Repositories overrides the repository scope of the configuration for this registry
+	Repositories *RepositoryScope `json:",omitempty"`
+	// DeleteRepositories overrides the repository cleanup of the configuration for this registry
+	DeleteRepositories *RepositoryCleanup `json:",omitempty"`
+	// Enrich overrides the enrichment of the configuration for this registry
+	Enrich *bool `json:",omitempty"`
 }
 
 // Configuration struct shows the structure of the configuration file used by this app
 type Configuration struct {
-	GCR       GoogleContainerRegistry    `json:",omitempty"`
-	Dockerhub DockerhubContainerRegistry `json:",omitempty"`
-	Keep      KeepImages
+	Registries
+	// Targets are more registries that are cleaned in the same run, sharing the scan of the kubernetes clusters
+	Targets            []RegistryTarget  `json:",omitempty"`
+	Repositories       RepositoryScope   `json:",omitempty"`
+	DeleteRepositories RepositoryCleanup `json:",omitempty"`
+	// Enrich fetches the manifest and the configuration of each image, for its labels, annotations, platform, layers and creation time
+	Enrich bool `json:",omitempty"`
+	Keep   KeepImages
 }
 
 // ApplySubcommandOptions defines the options of the apply subcommand
@@ -70,7 +250,24 @@ type ApplyPlanCommonSubcommandOptions struct {
 	Config                     string
 	GoogleContainerRegistry    GoogleContainerRegistry
 	DockerhubContainerRegistry DockerhubContainerRegistry
-	Keep                       KeepImages
+	OCIContainerRegistry       OCIContainerRegistry
+	ArtifactRegistry           ArtifactRegistry
+	GitHubContainerRegistry    GitHubContainerRegistry
+	GitLabContainerRegistry    GitLabContainerRegistry
+	QuayContainerRegistry      QuayContainerRegistry
+	HarborContainerRegistry    HarborContainerRegistry
+	ElasticContainerRegistry   ElasticContainerRegistry
+	AzureContainerRegistry     AzureContainerRegistry
+	DriverRegistry             DriverRegistry
+	// Targets are the registries of the configuration file that are cleaned along with the one above, if any
+	Targets []RegistryTarget
+	// Repositories are the repositories that are in scope
+	Repositories RepositoryScope
+	// DeleteRepositories are the repositories that are deleted as a whole
+	DeleteRepositories RepositoryCleanup
+	// Enrich fetches the manifest and the configuration of each image after parsing its repository
+	Enrich bool
+	Keep   KeepImages
 }
 
 // ConfigureSubcommandOptions defines the options of the configure subcommand
@@ -86,6 +283,8 @@ type ShowSubcommandOptions struct {
 	// Plan is the path to the plan file to show
 	Plan       string
 	Analytical bool
+	// Tree rolls the deletion counts and sizes up to each level of the repository paths
+	Tree bool
 }
 
 // AppOptions groups all the possible application options in a single struct
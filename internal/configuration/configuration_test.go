@@ -1,6 +1,7 @@
 package configuration
 
 import (
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"log"
@@ -8,11 +9,13 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
 )
 
 func writeDockerhubAnswers(writer *io.PipeWriter) {
 	defer writer.Close()
-	answers := []string{"dockerhub", "hytromo", "namespace", "1234", "10d", "k1", "k2", "", "t1", "t2", "", "d1", "d2", "", "i1", "i2", ""}
+	answers := []string{"dockerhub", "hytromo", "namespace", "namespace2", "", "1234", "10d", "k1", "k2", "", "t1", "t2", "", "d1", "d2", "", "i1", "i2", ""}
 	for _, answer := range answers {
 		_, err := io.WriteString(writer, answer+"\r\n")
 		if err != nil {
@@ -33,8 +36,8 @@ func TestAskUserInput(t *testing.T) {
 		t.Error("Wrong username")
 	}
 
-	if userInput.ContainerRegistryNamespace != "namespace" {
-		t.Error("Wrong namespace")
+	if !reflect.DeepEqual(userInput.ContainerRegistryNamespaces, []string{"namespace", "namespace2"}) {
+		t.Error("Wrong namespaces")
 	}
 
 	if userInput.ContainerRegistryPassword != "1234" {
@@ -123,11 +126,187 @@ func TestRegistryDetection(t *testing.T) {
 		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
 			DockerhubContainerRegistry: DockerhubContainerRegistry{
 				Username:  "test",
-				Namespace: "test",
+				Namespace: StringList{"test"},
 				Password:  "test",
 			},
 		},
 	}) {
 		t.Error("Should be detected as dockerhub")
 	}
+
+	if !IsOCI(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			OCIContainerRegistry: OCIContainerRegistry{
+				Host: "registry.example.com",
+			},
+		},
+	}) {
+		t.Error("Should be detected as OCI")
+	}
+
+	if !IsArtifactRegistry(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			ArtifactRegistry: ArtifactRegistry{
+				Project:  "project",
+				Location: "europe-west1",
+			},
+		},
+	}) {
+		t.Error("Should be detected as Artifact Registry")
+	}
+
+	if !IsGHCR(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			GitHubContainerRegistry: GitHubContainerRegistry{
+				Owner: "hytromo",
+			},
+		},
+	}) {
+		t.Error("Should be detected as GHCR")
+	}
+
+	if !IsGitLab(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			GitLabContainerRegistry: GitLabContainerRegistry{
+				Host:  "https://gitlab.com",
+				Group: "hytromo",
+			},
+		},
+	}) {
+		t.Error("Should be detected as GitLab")
+	}
+
+	if !IsQuay(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			QuayContainerRegistry: QuayContainerRegistry{
+				Organization: "hytromo",
+			},
+		},
+	}) {
+		t.Error("Should be detected as Quay")
+	}
+
+	if !IsHarbor(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			HarborContainerRegistry: HarborContainerRegistry{
+				Host: "harbor.example.com",
+			},
+		},
+	}) {
+		t.Error("Should be detected as Harbor")
+	}
+
+	if !IsECR(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			ElasticContainerRegistry: ElasticContainerRegistry{
+				Region: "eu-west-1",
+			},
+		},
+	}) {
+		t.Error("Should be detected as ECR")
+	}
+
+	if !IsACR(&AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			AzureContainerRegistry: AzureContainerRegistry{
+				Host: "myregistry.azurecr.io",
+			},
+		},
+	}) {
+		t.Error("Should be detected as ACR")
+	}
+}
+
+func TestStringList(t *testing.T) {
+	registry := DockerhubContainerRegistry{}
+
+	if err := json.Unmarshal([]byte(`{"Namespace": "my-org"}`), &registry); err != nil || !reflect.DeepEqual(registry.Namespace, StringList{"my-org"}) {
+		t.Errorf("A single namespace should be accepted, got %v (%v)", registry.Namespace, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Namespace": ["my-org", "user"]}`), &registry); err != nil || !reflect.DeepEqual(registry.Namespace, StringList{"my-org", "user"}) {
+		t.Errorf("A list of namespaces should be accepted, got %v (%v)", registry.Namespace, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"Namespace": 1}`), &registry); err == nil {
+		t.Error("A number should not be accepted as namespace")
+	}
+}
+
+func TestRegistryTargets(t *testing.T) {
+	config := Configuration{}
+	err := json.Unmarshal([]byte(`{
+		"GCR": {"Host": "eu.gcr.io", "Token": "gcr-token"},
+		"Targets": [
+			{"Name": "hub", "Dockerhub": {"Username": "user", "Password": "pass", "Namespace": ["my-org"]}},
+			{"Name": "harbor", "Harbor": {"Host": "harbor.example.com"}, "Keep": {"YoungerThan": "30d", "UsedIn": {"KubernetesClusters": []}}}
+		],
+		"Keep": {"YoungerThan": "7d", "AtLeast": 3, "UsedIn": {"KubernetesClusters": [{"Context": "production"}]}}
+	}`), &config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.GCR.Host != "eu.gcr.io" || len(config.Targets) != 2 {
+		t.Fatalf("Wrong configuration %+v", config)
+	}
+
+	options := AppOptions{
+		ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{
+			GoogleContainerRegistry: config.GCR,
+			Targets:                 config.Targets,
+			Keep:                    config.Keep,
+		},
+	}
+
+	hubOptions := options.ForTarget(config.Targets[0])
+
+	if IsGCR(&hubOptions) || !IsDockerhub(&hubOptions) || hubOptions.ApplyPlanCommon.DockerhubContainerRegistry.Password != "pass" {
+		t.Error("A target should only point to its own registry")
+	}
+
+	if !reflect.DeepEqual(hubOptions.ApplyPlanCommon.Keep, config.Keep) || hubOptions.ApplyPlanCommon.Targets != nil {
+		t.Error("A target without overrides should use the keep rules of the configuration")
+	}
+
+	harborOptions := options.ForTarget(config.Targets[1])
+	harborKeep := harborOptions.ApplyPlanCommon.Keep
+
+	if !IsHarbor(&harborOptions) || harborKeep.YoungerThan != "30d" || harborKeep.AtLeastCount() != 3 || len(harborKeep.UsedIn.KubernetesClusters) != 0 {
+		t.Errorf("Wrong overridden keep rules %+v", harborKeep)
+	}
+
+	if !reflect.DeepEqual(RegistriesOf(harborOptions.ApplyPlanCommon), config.Targets[1].Registries) {
+		t.Error("The registries of the target options should be the ones of the target")
+	}
+
+	if !HasRegistry(&options) || HasRegistry(&AppOptions{}) {
+		t.Error("Wrong registry detection")
+	}
+}
+
+func TestOverrideAtLeastWithZero(t *testing.T) {
+	config := Configuration{}
+	err := json.Unmarshal([]byte(`{
+		"Targets": [{"Name": "hub", "Dockerhub": {"Username": "user", "Password": "pass", "Namespace": ["my-org"]}, "Keep": {"AtLeast": 0}}],
+		"Keep": {"AtLeast": 3, "Paths": [{"Path": "my-org/scratch/**", "Keep": {"AtLeast": 0}}]}
+	}`), &config)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := AppOptions{ApplyPlanCommon: ApplyPlanCommonSubcommandOptions{Targets: config.Targets, Keep: config.Keep}}
+
+	if options.ForTarget(config.Targets[0]).ApplyPlanCommon.Keep.AtLeastCount() != 0 {
+		t.Error("A target should be able to stop keeping a number of images")
+	}
+
+	scratchKeep := config.Keep.ForPath(containerregistry.Repository{Link: "docker.io/my-org/scratch/tmp", Host: "docker.io"})
+	serviceKeep := config.Keep.ForPath(containerregistry.Repository{Link: "docker.io/my-org/service", Host: "docker.io"})
+
+	if scratchKeep.AtLeastCount() != 0 || serviceKeep.AtLeastCount() != 3 {
+		t.Errorf("Wrong path keep counts %v and %v", scratchKeep.AtLeastCount(), serviceKeep.AtLeastCount())
+	}
 }
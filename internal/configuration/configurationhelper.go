@@ -12,15 +12,67 @@ import (
 
 func constructConfigurationFromAnswers(answers UserInput) Configuration {
 	config := Configuration{}
-	config.GCR = GoogleContainerRegistry{
-		Token: answers.ContainerRegistryPassword,
-		Host:  answers.ContainerRegistryLink,
-	}
 
-	config.Dockerhub = DockerhubContainerRegistry{
-		Username:  answers.ContainerRegistryUsername,
-		Password:  answers.ContainerRegistryPassword,
-		Namespace: answers.ContainerRegistryNamespace,
+	// only the chosen registry is filled in, otherwise more than one registry would be detected when reading the configuration
+	switch answers.ContainerRegistryType {
+	case "gcr":
+		config.GCR = GoogleContainerRegistry{
+			Token: answers.ContainerRegistryPassword,
+			Host:  answers.ContainerRegistryLink,
+		}
+	case "dockerhub":
+		config.Dockerhub = DockerhubContainerRegistry{
+			Username:  answers.ContainerRegistryUsername,
+			Password:  answers.ContainerRegistryPassword,
+			Namespace: answers.ContainerRegistryNamespaces,
+		}
+	case "oci":
+		config.OCI = OCIContainerRegistry{
+			Host:     answers.ContainerRegistryLink,
+			Username: answers.ContainerRegistryUsername,
+			Password: answers.ContainerRegistryPassword,
+		}
+	case "artifactregistry":
+		config.ArtifactRegistry = ArtifactRegistry{
+			Project:  answers.ContainerRegistryProject,
+			Location: answers.ContainerRegistryLocation,
+			Token:    answers.ContainerRegistryPassword,
+		}
+	case "ghcr":
+		config.GHCR = GitHubContainerRegistry{
+			Owner: answers.ContainerRegistryNamespace,
+			Token: answers.ContainerRegistryPassword,
+		}
+	case "gitlab":
+		config.GitLab = GitLabContainerRegistry{
+			Host:  answers.ContainerRegistryLink,
+			Group: answers.ContainerRegistryNamespace,
+			Token: answers.ContainerRegistryPassword,
+		}
+	case "quay":
+		config.Quay = QuayContainerRegistry{
+			Host:         answers.ContainerRegistryLink,
+			Organization: answers.ContainerRegistryNamespace,
+			Token:        answers.ContainerRegistryPassword,
+		}
+	case "harbor":
+		config.Harbor = HarborContainerRegistry{
+			Host:     answers.ContainerRegistryLink,
+			Project:  answers.ContainerRegistryNamespace,
+			Username: answers.ContainerRegistryUsername,
+			Password: answers.ContainerRegistryPassword,
+		}
+	case "acr":
+		config.ACR = AzureContainerRegistry{
+			Host:     answers.ContainerRegistryLink,
+			TenantID: answers.ContainerRegistryTenant,
+			Username: answers.ContainerRegistryUsername,
+			Password: answers.ContainerRegistryPassword,
+		}
+	case "ecr":
+		config.ECR = ElasticContainerRegistry{
+			Region: answers.ContainerRegistryLocation,
+		}
 	}
 
 	config.Keep.YoungerThan = answers.YoungerThan
@@ -79,6 +131,11 @@ func ReadPlan(planPath string, uncompressData bool) []containerregistry.Reposito
 	return parsedRepos
 }
 
+// HasTokenSource returns if any of the token sources is configured
+func (source GoogleTokenSource) HasTokenSource() bool {
+	return source != (GoogleTokenSource{})
+}
+
 // IsGCR returns if the configuration options point to GCR
 func IsGCR(options *AppOptions) bool {
 	config := options.ApplyPlanCommon
@@ -90,5 +147,198 @@ func IsGCR(options *AppOptions) bool {
 func IsDockerhub(options *AppOptions) bool {
 	config := options.ApplyPlanCommon
 
-	return config.DockerhubContainerRegistry != (DockerhubContainerRegistry{})
+	// the namespace list makes the struct not comparable
+	return config.DockerhubContainerRegistry.Username != "" || config.DockerhubContainerRegistry.Password != "" || len(config.DockerhubContainerRegistry.Namespace) > 0
+}
+
+// IsOCI returns if the configuration options point to a generic OCI distribution registry
+func IsOCI(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.OCIContainerRegistry != (OCIContainerRegistry{})
+}
+
+// IsArtifactRegistry returns if the configuration options point to google artifact registry
+func IsArtifactRegistry(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.ArtifactRegistry != (ArtifactRegistry{})
+}
+
+// IsGHCR returns if the configuration options point to the github container registry
+func IsGHCR(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.GitHubContainerRegistry != (GitHubContainerRegistry{})
+}
+
+// IsGitLab returns if the configuration options point to the container registries of a GitLab group
+func IsGitLab(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.GitLabContainerRegistry != (GitLabContainerRegistry{})
+}
+
+// IsQuay returns if the configuration options point to the repositories of a quay organization
+func IsQuay(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.QuayContainerRegistry != (QuayContainerRegistry{})
+}
+
+// IsHarbor returns if the configuration options point to a harbor registry
+func IsHarbor(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.HarborContainerRegistry != (HarborContainerRegistry{})
+}
+
+// IsECR returns if the configuration options point to amazon ecr
+func IsECR(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.ElasticContainerRegistry != (ElasticContainerRegistry{})
+}
+
+// IsACR returns if the configuration options point to an azure container registry
+func IsACR(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	return config.AzureContainerRegistry != (AzureContainerRegistry{})
+}
+
+// IsDriver returns if the configuration options point to a registry that is implemented by an external driver
+func IsDriver(options *AppOptions) bool {
+	config := options.ApplyPlanCommon
+
+	// the options map makes the struct not comparable
+	return config.DriverRegistry.Name != "" || config.DriverRegistry.Path != ""
+}
+
+// HasRegistry returns if the configuration options point to any registry
+func HasRegistry(options *AppOptions) bool {
+	return IsGCR(options) || IsArtifactRegistry(options) || IsECR(options) || IsACR(options) || IsDockerhub(options) || IsGHCR(options) || IsGitLab(options) || IsQuay(options) || IsHarbor(options) || IsOCI(options) || IsDriver(options)
+}
+
+// RegistriesOf returns the registry blocks of the options in the form of the configuration file
+func RegistriesOf(options ApplyPlanCommonSubcommandOptions) Registries {
+	return Registries{
+		GCR:              options.GoogleContainerRegistry,
+		Dockerhub:        options.DockerhubContainerRegistry,
+		OCI:              options.OCIContainerRegistry,
+		ArtifactRegistry: options.ArtifactRegistry,
+		GHCR:             options.GitHubContainerRegistry,
+		GitLab:           options.GitLabContainerRegistry,
+		Quay:             options.QuayContainerRegistry,
+		Harbor:           options.HarborContainerRegistry,
+		ECR:              options.ElasticContainerRegistry,
+		ACR:              options.AzureContainerRegistry,
+		Driver:           options.DriverRegistry,
+	}
+}
+
+// OverriddenBy returns the keep rules with the rules that are set in the override replacing the original ones
+func (keep KeepImages) OverriddenBy(override *KeepImages) KeepImages {
+	if override == nil {
+		return keep
+	}
+
+	if override.YoungerThan != "" {
+		keep.YoungerThan = override.YoungerThan
+	}
+
+	if override.PulledWithin != "" {
+		keep.PulledWithin = override.PulledWithin
+	}
+
+	if override.AtLeast != nil {
+		keep.AtLeast = override.AtLeast
+	}
+
+	// lists are overridden when they are set at all, so that an empty list can switch a rule off
+	if override.UsedIn.KubernetesClusters != nil {
+		keep.UsedIn.KubernetesClusters = override.UsedIn.KubernetesClusters
+	}
+
+	if override.Image.Tags != nil {
+		keep.Image.Tags = override.Image.Tags
+	}
+
+	if override.Image.Digests != nil {
+		keep.Image.Digests = override.Image.Digests
+	}
+
+	if override.Image.Repositories != nil {
+		keep.Image.Repositories = override.Image.Repositories
+	}
+
+	if override.Paths != nil {
+		keep.Paths = override.Paths
+	}
+
+	return keep
+}
+
+// AtLeastCount returns the number of images to keep at least, which is 0 when it is not set
+func (keep KeepImages) AtLeastCount() int {
+	if keep.AtLeast == nil {
+		return 0
+	}
+
+	return *keep.AtLeast
+}
+
+// ForPath returns the keep rules of a repository: the rules of the most specific path prefix that the repository lies under override the general ones
+func (keep KeepImages) ForPath(repository containerregistry.Repository) KeepImages {
+	mostSpecific := -1
+
+	for i, path := range keep.Paths {
+		if !repository.HasPathPrefix(path.Path) {
+			continue
+		}
+
+		if mostSpecific == -1 || len(containerregistry.CleanPathPrefix(path.Path)) > len(containerregistry.CleanPathPrefix(keep.Paths[mostSpecific].Path)) {
+			mostSpecific = i
+		}
+	}
+
+	if mostSpecific == -1 {
+		return keep
+	}
+
+	pathKeep := keep.Paths[mostSpecific].Keep
+	pathKeep.Paths = nil
+
+	return keep.OverriddenBy(&pathKeep)
+}
+
+// ForTarget returns the options of a single registry target: its own registry block and credentials, and the keep rules, the repository scope, the repository cleanup and the enrichment of the options with the overrides of the target
+func (options AppOptions) ForTarget(target RegistryTarget) AppOptions {
+	options.ApplyPlanCommon.GoogleContainerRegistry = target.GCR
+	options.ApplyPlanCommon.DockerhubContainerRegistry = target.Dockerhub
+	options.ApplyPlanCommon.OCIContainerRegistry = target.OCI
+	options.ApplyPlanCommon.ArtifactRegistry = target.ArtifactRegistry
+	options.ApplyPlanCommon.GitHubContainerRegistry = target.GHCR
+	options.ApplyPlanCommon.GitLabContainerRegistry = target.GitLab
+	options.ApplyPlanCommon.QuayContainerRegistry = target.Quay
+	options.ApplyPlanCommon.HarborContainerRegistry = target.Harbor
+	options.ApplyPlanCommon.ElasticContainerRegistry = target.ECR
+	options.ApplyPlanCommon.AzureContainerRegistry = target.ACR
+	options.ApplyPlanCommon.DriverRegistry = target.Driver
+	options.ApplyPlanCommon.Targets = nil
+	options.ApplyPlanCommon.Keep = options.ApplyPlanCommon.Keep.OverriddenBy(target.Keep)
+
+	if target.Repositories != nil {
+		options.ApplyPlanCommon.Repositories = *target.Repositories
+	}
+
+	if target.DeleteRepositories != nil {
+		options.ApplyPlanCommon.DeleteRepositories = *target.DeleteRepositories
+	}
+
+	if target.Enrich != nil {
+		options.ApplyPlanCommon.Enrich = *target.Enrich
+	}
+
+	return options
 }
@@ -0,0 +1,32 @@
+package configuration
+
+import "encoding/json"
+
+// StringList is a list of strings that can also be given as a single string in the configuration file, e.g. "Namespace": "my-org" or "Namespace": ["my-org", "my-user"]
+type StringList []string
+
+// UnmarshalJSON accepts both a single string and a list of strings
+func (list *StringList) UnmarshalJSON(data []byte) error {
+	single := ""
+
+	if err := json.Unmarshal(data, &single); err == nil {
+		if single == "" {
+			*list = StringList{}
+		} else {
+			*list = StringList{single}
+		}
+
+		return nil
+	}
+
+	multiple := []string{}
+	err := json.Unmarshal(data, &multiple)
+
+	if err != nil {
+		return err
+	}
+
+	*list = multiple
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package acr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+	log "github.com/sirupsen/logrus"
+)
+
+// lockedMetadata is the keep reason metadata of the manifests that cannot be deleted
+var lockedMetadata = "locked (deleteEnabled is false)"
+
+// RefreshTokenUsername is the username that comes along with a refresh token of the registry, e.g. the one that az acr login stores
+const RefreshTokenUsername = "00000000-0000-0000-0000-000000000000"
+
+// Login keeps the credentials, which are the client id and the secret of a service principal when a tenant is specified and the admin credentials otherwise; service principals get a refresh token right away, while a refresh token of the registry can also be given directly as the password of RefreshTokenUsername
+func (client *RegistryClient) Login(username string, password string) error {
+	client.username = username
+	client.password = password
+
+	if username == RefreshTokenUsername {
+		client.auth.mutex.Lock()
+		client.auth.refreshToken = password
+		client.auth.mutex.Unlock()
+
+		return nil
+	}
+
+	if client.tenantID == "" {
+		return nil
+	}
+
+	refreshToken, err := client.exchangeRefreshToken()
+
+	if err != nil {
+		return err
+	}
+
+	client.auth.mutex.Lock()
+	client.auth.refreshToken = refreshToken
+	client.auth.mutex.Unlock()
+
+	return nil
+}
+
+// getManifestAttributes returns the attributes of a manifest; the refusals and a manifest that does not exist are answered at once as a *myhttp.StatusError, as retrying them does not change the answer
+func (client *RegistryClient) getManifestAttributes(imageRepo string, digest string, silentErrors bool) (ManifestDTO, error) {
+	bodyBytes, err := client.httpClient.GetRequestAnsweringTo("/acr/v1/"+imageRepo+"/_manifests/"+digest, []int{http.StatusNotFound, http.StatusForbidden}, silentErrors)
+
+	if err != nil {
+		return ManifestDTO{}, err
+	}
+
+	attributesResp := ManifestAttributesDTO{}
+	err = json.Unmarshal(bodyBytes, &attributesResp)
+
+	if err != nil {
+		return ManifestDTO{}, fmt.Errorf("invalid api call response (%v): %w", string(bodyBytes), err)
+	}
+
+	return attributesResp.Manifest, nil
+}
+
+// isNotFound returns if the error is the answer of the registry for a manifest that does not exist
+func isNotFound(err error) bool {
+	statusErr := &myhttp.StatusError{}
+
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// Capabilities returns what the client can do; ACR deletes manifests by digest
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest: true,
+	}
+}
+
+// DeleteImage deletes a manifest by its digest along with all its tags; manifests that were locked since the plan was made are skipped and manifests that are already gone count as deleted
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	manifest, err := client.getManifestAttributes(imageRepo, image.Digest[0], silentErrors)
+
+	if isNotFound(err) {
+		// already deleted, e.g. by another run or by a retention policy of the registry
+		return nil
+	}
+
+	if err != nil {
+		if !silentErrors {
+			log.Errorf("Could not get the attributes of %v@%v: %v", imageRepo, image.Digest[0], err)
+		}
+
+		return fmt.Errorf("getting the attributes of %v@%v: %w", imageRepo, image.Digest[0], err)
+	}
+
+	if !manifest.ChangeableAttributes.DeleteEnabled {
+		if !silentErrors {
+			log.Warnf("Skipping %v@%v, it is %v", imageRepo, image.Digest[0], lockedMetadata)
+		}
+
+		return cr.ErrImageProtected
+	}
+
+	err = client.httpClient.DeleteRequestAnsweringTo("/v2/"+imageRepo+"/manifests/"+image.Digest[0], []int{http.StatusNotFound}, true, silentErrors)
+
+	if isNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// GetAllRepos returns all the repositories of the registry
+func (client *RegistryClient) GetAllRepos() []string {
+	repositories := []string{}
+	next := "/acr/v1/_catalog?n=100" // initial request
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		catalogResp := CatalogDTO{}
+		err = json.Unmarshal(bodyBytes, &catalogResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		repositories = append(repositories, catalogResp.Repositories...)
+		next = myhttp.NextLink(headers)
+	}
+
+	return repositories
+}
+
+// ParseRepo parses all the manifests of a repository; locked manifests are kept
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	next := "/acr/v1/" + repositoryLink + "/_manifests?n=100" // initial request
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		manifestsResp := ManifestsDTO{}
+		err = json.Unmarshal(bodyBytes, &manifestsResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, manifest := range manifestsResp.Manifests {
+			repoImage := cr.ContainerImage{
+				Tag:            manifest.Tags,
+				Digest:         []string{manifest.Digest},
+				MediaType:      manifest.MediaType,
+				ImageSizeBytes: strconv.FormatInt(manifest.ImageSize, 10),
+				Repo:           client.hostname + "/" + repositoryLink,
+			}
+
+			if repoImage.Tag == nil {
+				repoImage.Tag = []string{}
+			}
+
+			createdTime, err := time.Parse(time.RFC3339Nano, manifest.CreatedTime)
+
+			if err == nil {
+				repoImage.TimeCreatedMs = strconv.FormatInt(createdTime.UTC().UnixMilli(), 10)
+			} else {
+				log.Errorf("Manifest %v of %v contains invalid creation time: %v", manifest.Digest, repositoryLink, manifest.CreatedTime)
+			}
+
+			// the last update time changes whenever the manifest is pushed or (un)tagged
+			lastUpdateTime, err := time.Parse(time.RFC3339Nano, manifest.LastUpdateTime)
+
+			if err == nil {
+				repoImage.TimeUploadedMs = strconv.FormatInt(lastUpdateTime.UTC().UnixMilli(), 10)
+			} else {
+				repoImage.TimeUploadedMs = repoImage.TimeCreatedMs
+			}
+
+			if !manifest.ChangeableAttributes.DeleteEnabled {
+				repoImage.KeptData = keepreasons.KeptData{
+					Reason:   keepreasons.Protected,
+					Metadata: lockedMetadata,
+				}
+			}
+
+			repository.Images = append(repository.Images, repoImage)
+		}
+
+		next = myhttp.NextLink(headers)
+	}
+
+	return repository
+}
+
+// NewACRClientParams are the required parameters to build an ACR client
+type NewACRClientParams struct {
+	// Host is the registry host, e.g. myregistry.azurecr.io; https is assumed when no scheme is specified
+	Host string
+	// TenantID is the azure tenant of the service principal; empty means that the admin credentials are used
+	TenantID string
+}
+
+// NewACRClient builds a new ACR client
+func NewACRClient(params NewACRClientParams) cr.Client {
+	baseURL := strings.TrimSuffix(params.Host, "/")
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "https://" + baseURL
+	}
+
+	client := &RegistryClient{
+		tokenClient: myhttp.NewClient(myhttp.NewClientParams{
+			BaseURL: baseURL,
+		}),
+		hostname: strings.TrimPrefix(strings.TrimPrefix(baseURL, "https://"), "http://"),
+		tenantID: params.TenantID,
+		auth: &authState{
+			tokens: map[string]string{},
+		},
+	}
+
+	client.httpClient = myhttp.NewClient(myhttp.NewClientParams{
+		BaseURL:             baseURL,
+		InjectAuthInRequest: client.injectAuth,
+		RefreshAuth:         client.refreshAuth,
+	})
+
+	return client
+}
@@ -0,0 +1,217 @@
+package acr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+var manifests = []ManifestDTO{
+	{
+		Digest:               "sha256:abc",
+		ImageSize:            1000,
+		CreatedTime:          "2022-01-02T15:04:05.1234567Z",
+		LastUpdateTime:       "2022-02-02T15:04:05.123Z",
+		Tags:                 []string{"latest", "v1"},
+		ChangeableAttributes: ChangeableAttributesDTO{DeleteEnabled: true, WriteEnabled: true},
+	},
+	{
+		Digest:               "sha256:def",
+		ImageSize:            500,
+		CreatedTime:          "2022-01-01T15:04:05Z",
+		LastUpdateTime:       "2022-01-01T15:04:05Z",
+		ChangeableAttributes: ChangeableAttributesDTO{DeleteEnabled: false},
+	},
+}
+
+// fakeRegistry is a local stand-in of both azure active directory and an acr registry that requires a token per scope
+type fakeRegistry struct {
+	*registrytest.Registry
+	mutex sync.Mutex
+	// scopes are the scopes that access tokens were requested for
+	scopes []string
+}
+
+// authorize lets the token requests through and challenges the other requests for a token of the scope that they need
+func (registry *fakeRegistry) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Path == "/my-tenant/oauth2/v2.0/token" || strings.HasPrefix(r.URL.Path, "/oauth2/") {
+		return true
+	}
+
+	scope := scopeOf(r)
+
+	if r.Header.Get("Authorization") != "Bearer token for "+scope {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+registry.URL+`/oauth2/token",service="registry",scope="`+scope+`"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+func newFakeRegistry(t *testing.T) *fakeRegistry {
+	registry := &fakeRegistry{}
+	registry.Registry = registrytest.New(t, registry.authorize)
+
+	registry.Handle("POST", "/my-tenant/oauth2/v2.0/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+
+		if r.PostForm.Get("client_id") != "app-id" || r.PostForm.Get("client_secret") != "secret" || r.PostForm.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(AADTokenDTO{AccessToken: "aad-token"})
+	})
+	registry.Handle("POST", "/oauth2/exchange", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+
+		if r.PostForm.Get("access_token") != "aad-token" || r.PostForm.Get("tenant") != "my-tenant" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(RefreshTokenDTO{RefreshToken: "refresh-token"})
+	})
+	registry.Handle("", "/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		username, password, _ := r.BasicAuth()
+
+		if r.Form.Get("refresh_token") != "refresh-token" && (username != "admin" || password != "admin-password") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		registry.mutex.Lock()
+		registry.scopes = append(registry.scopes, r.Form.Get("scope"))
+		registry.mutex.Unlock()
+
+		_ = json.NewEncoder(w).Encode(AccessTokenDTO{AccessToken: "token for " + r.Form.Get("scope")})
+	})
+	registry.Handle("GET", "/acr/v1/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("last") != "" {
+			_ = json.NewEncoder(w).Encode(CatalogDTO{Repositories: []string{"worker"}})
+			return
+		}
+
+		w.Header().Set("Link", `</acr/v1/_catalog?last=team/app&n=100>; rel="next"`)
+		_ = json.NewEncoder(w).Encode(CatalogDTO{Repositories: []string{"team/app"}})
+	})
+	registry.Handle("GET", "/acr/v1/team/app/_manifests", registrytest.JSON(ManifestsDTO{Manifests: manifests}))
+
+	for _, manifest := range manifests {
+		registry.Handle("GET", "/acr/v1/team/app/_manifests/"+manifest.Digest, registrytest.JSON(ManifestAttributesDTO{Manifest: manifest}))
+	}
+
+	return registry
+}
+
+func newTestClient(t *testing.T, servicePrincipal bool) (cr.Client, *fakeRegistry) {
+	registry := newFakeRegistry(t)
+
+	originalAuthorityHost := defaultAuthorityHost
+	defaultAuthorityHost = registry.URL
+	t.Cleanup(func() { defaultAuthorityHost = originalAuthorityHost })
+
+	if !servicePrincipal {
+		client := NewACRClient(NewACRClientParams{Host: registry.URL})
+
+		if client.Login("admin", "admin-password") != nil {
+			t.Error("Login should not fail")
+		}
+
+		return client, registry
+	}
+
+	client := NewACRClient(NewACRClientParams{Host: registry.URL, TenantID: "my-tenant"})
+
+	if client.Login("app-id", "secret") != nil {
+		t.Error("Login should not fail")
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	for _, servicePrincipal := range []bool{true, false} {
+		client, registry := newTestClient(t, servicePrincipal)
+
+		if !reflect.DeepEqual(client.GetAllRepos(), []string{"team/app", "worker"}) {
+			t.Errorf("Wrong repos %v", client.GetAllRepos())
+		}
+
+		if !reflect.DeepEqual(registry.scopes, []string{"registry:catalog:*"}) {
+			t.Errorf("The catalog token should be requested once and reused, requested %v", registry.scopes)
+		}
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t, true)
+
+	repo := client.ParseRepo("team/app")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("Exactly 2 images should be parsed, not %v", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"latest", "v1"}) || image.ImageSizeBytes != "1000" || image.TimeCreatedMs != "1641135845123" || image.TimeUploadedMs != "1643814245123" {
+		t.Errorf("Wrong image data %+v", image)
+	}
+
+	if image.KeptData.Reason != keepreasons.None {
+		t.Error("Unlocked manifests should not be kept")
+	}
+
+	if repo.Images[1].KeptData.Reason != keepreasons.Protected || repo.Images[1].KeptData.Metadata != lockedMetadata || repo.Images[1].Tag == nil {
+		t.Errorf("Locked manifests should be kept %+v", repo.Images[1])
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	client, registry := newTestClient(t, true)
+	registry.Handle("GET", "/acr/v1/team/app/_manifests/sha256:forbidden", registrytest.Status(http.StatusForbidden, ""))
+
+	if client.DeleteImage("team/app", cr.ContainerImage{Digest: []string{"sha256:def"}}, true) != cr.ErrImageProtected {
+		t.Error("Locked manifests should be skipped")
+	}
+
+	if client.DeleteImage("team/app", cr.ContainerImage{Digest: []string{"sha256:abc"}}, false) != nil {
+		t.Error("Delete should not fail")
+	}
+
+	if client.DeleteImage("team/app", cr.ContainerImage{Digest: []string{"sha256:gone"}}, true) != nil {
+		t.Error("Manifests that are already gone should count as deleted")
+	}
+
+	if err := client.DeleteImage("team/app", cr.ContainerImage{Digest: []string{"sha256:forbidden"}}, true); err == nil || errors.Is(err, cr.ErrImageProtected) {
+		t.Errorf("Manifests whose attributes cannot be read should fail, got %v", err)
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{"/v2/team/app/manifests/sha256:abc"}) {
+		t.Errorf("Wrong deleted manifests %v", registry.Deleted())
+	}
+}
+
+func TestRefreshTokenLogin(t *testing.T) {
+	client := NewACRClient(NewACRClientParams{Host: newFakeRegistry(t).URL})
+
+	// e.g. the refresh token that az acr login has stored in the docker credentials
+	if client.Login(RefreshTokenUsername, "refresh-token") != nil {
+		t.Fatal("Login should not fail")
+	}
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"team/app", "worker"}) {
+		t.Error("The refresh token should be used to get access tokens")
+	}
+}
@@ -0,0 +1,75 @@
+package acr
+
+import (
+	"sync"
+
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+)
+
+// RegistryClient is an azure container registry client, it uses the /acr/v1 api which exposes the tags, the update times and the locks of the manifests
+type RegistryClient struct {
+	httpClient myhttp.Client
+	// tokenClient is used for the token endpoints, which must not carry the registry access tokens
+	tokenClient myhttp.Client
+	// hostname is the registry host, e.g. myregistry.azurecr.io
+	hostname string
+	// tenantID is set when logging in with a service principal; it is empty when logging in with the admin credentials
+	tenantID string
+	username string
+	password string
+	auth     *authState
+}
+
+// authState keeps the acr refresh token and the access tokens per scope
+type authState struct {
+	mutex        sync.RWMutex
+	refreshToken string
+	tokens       map[string]string
+}
+
+// AADTokenDTO is the response of the azure active directory token endpoint
+type AADTokenDTO struct {
+	AccessToken string `json:"access_token"`
+}
+
+// RefreshTokenDTO is the response of the /oauth2/exchange endpoint
+type RefreshTokenDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AccessTokenDTO is the response of the /oauth2/token endpoint
+type AccessTokenDTO struct {
+	AccessToken string `json:"access_token"`
+}
+
+// CatalogDTO is the Data Transfer Object for the /acr/v1/_catalog api call
+type CatalogDTO struct {
+	Repositories []string
+}
+
+// ChangeableAttributesDTO are the attributes of a manifest that can be changed, e.g. to lock it
+type ChangeableAttributesDTO struct {
+	DeleteEnabled bool
+	WriteEnabled  bool
+}
+
+// ManifestDTO is the DTO of an acr manifest
+type ManifestDTO struct {
+	Digest               string
+	ImageSize            int64
+	MediaType            string
+	CreatedTime          string
+	LastUpdateTime       string
+	Tags                 []string
+	ChangeableAttributes ChangeableAttributesDTO
+}
+
+// ManifestsDTO is the Data Transfer Object for the /acr/v1/{repository}/_manifests api call
+type ManifestsDTO struct {
+	Manifests []ManifestDTO
+}
+
+// ManifestAttributesDTO is the Data Transfer Object for the /acr/v1/{repository}/_manifests/{digest} api call
+type ManifestAttributesDTO struct {
+	Manifest ManifestDTO
+}
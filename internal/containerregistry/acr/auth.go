@@ -0,0 +1,172 @@
+package acr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+)
+
+var defaultAuthorityHost = "https://login.microsoftonline.com"
+
+// aadScope is the audience of the azure active directory token that acr exchanges for a refresh token
+var aadScope = "https://management.azure.com/.default"
+
+// scopeOf returns the token scope that an api request needs, so that the access tokens can be cached per scope
+func scopeOf(req *http.Request) string {
+	path := req.URL.Path
+
+	if strings.HasPrefix(path, "/acr/v1/_catalog") {
+		return "registry:catalog:*"
+	}
+
+	if strings.HasPrefix(path, "/acr/v1/") {
+		repository := strings.TrimPrefix(path, "/acr/v1/")
+		if index := strings.LastIndex(repository, "/_manifests"); index != -1 {
+			repository = repository[:index]
+		}
+
+		return "repository:" + repository + ":metadata_read"
+	}
+
+	repository := strings.TrimPrefix(path, "/v2/")
+	if index := strings.LastIndex(repository, "/manifests/"); index != -1 {
+		repository = repository[:index]
+	}
+
+	if req.Method == "DELETE" {
+		return "repository:" + repository + ":delete"
+	}
+
+	return "repository:" + repository + ":pull"
+}
+
+func formHeaders() http.Header {
+	return http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+}
+
+// exchangeRefreshToken gets an azure active directory token for the service principal and exchanges it for an acr refresh token
+func (client *RegistryClient) exchangeRefreshToken() (string, error) {
+	aadForm := url.Values{}
+	aadForm.Set("grant_type", "client_credentials")
+	aadForm.Set("client_id", client.username)
+	aadForm.Set("client_secret", client.password)
+	aadForm.Set("scope", aadScope)
+
+	bodyBytes, err := client.tokenClient.PostRequestWithHeadersTo(defaultAuthorityHost+"/"+url.PathEscape(client.tenantID)+"/oauth2/v2.0/token", []byte(aadForm.Encode()), formHeaders(), false, false)
+
+	if err != nil {
+		return "", err
+	}
+
+	aadToken := AADTokenDTO{}
+	err = json.Unmarshal(bodyBytes, &aadToken)
+
+	if err != nil || aadToken.AccessToken == "" {
+		return "", fmt.Errorf("invalid azure active directory token response: %v", string(bodyBytes))
+	}
+
+	exchangeForm := url.Values{}
+	exchangeForm.Set("grant_type", "access_token")
+	exchangeForm.Set("service", client.hostname)
+	exchangeForm.Set("tenant", client.tenantID)
+	exchangeForm.Set("access_token", aadToken.AccessToken)
+
+	bodyBytes, err = client.tokenClient.PostRequestWithHeadersTo("/oauth2/exchange", []byte(exchangeForm.Encode()), formHeaders(), false, false)
+
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := RefreshTokenDTO{}
+	err = json.Unmarshal(bodyBytes, &refreshToken)
+
+	if err != nil || refreshToken.RefreshToken == "" {
+		return "", fmt.Errorf("invalid refresh token response: %v", string(bodyBytes))
+	}
+
+	return refreshToken.RefreshToken, nil
+}
+
+// fetchAccessToken gets an access token for a scope, either from the refresh token of the service principal or with the admin credentials
+func (client *RegistryClient) fetchAccessToken(scope string) (string, error) {
+	var bodyBytes []byte
+	var err error
+
+	client.auth.mutex.RLock()
+	refreshToken := client.auth.refreshToken
+	client.auth.mutex.RUnlock()
+
+	if refreshToken != "" {
+		form := url.Values{}
+		form.Set("grant_type", "refresh_token")
+		form.Set("service", client.hostname)
+		form.Set("scope", scope)
+		form.Set("refresh_token", refreshToken)
+
+		bodyBytes, err = client.tokenClient.PostRequestWithHeadersTo("/oauth2/token", []byte(form.Encode()), formHeaders(), false, false)
+	} else {
+		query := url.Values{}
+		query.Set("service", client.hostname)
+		query.Set("scope", scope)
+
+		adminClient := client.tokenClient
+		adminClient.InjectAuthInRequest = func(req *http.Request) {
+			req.SetBasicAuth(client.username, client.password)
+		}
+
+		bodyBytes, err = adminClient.GetRequestTo("/oauth2/token?" + query.Encode())
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	accessToken := AccessTokenDTO{}
+	err = json.Unmarshal(bodyBytes, &accessToken)
+
+	if err != nil || accessToken.AccessToken == "" {
+		return "", fmt.Errorf("invalid access token response: %v", string(bodyBytes))
+	}
+
+	return accessToken.AccessToken, nil
+}
+
+func (client *RegistryClient) injectAuth(req *http.Request) {
+	client.auth.mutex.RLock()
+	defer client.auth.mutex.RUnlock()
+
+	if token, exists := client.auth.tokens[scopeOf(req)]; exists {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+	}
+}
+
+// refreshAuth gets an access token for the scope of the challenge of a 401 response, so that the next try of the request carries it
+func (client *RegistryClient) refreshAuth(resp *http.Response) error {
+	parsedChallenge := myhttp.ParseChallenge(resp.Header.Get("WWW-Authenticate"))
+
+	if parsedChallenge.Scheme != "bearer" {
+		return fmt.Errorf("unsupported authentication challenge '%v'", resp.Header.Get("WWW-Authenticate"))
+	}
+
+	scope := parsedChallenge.Params["scope"]
+	if scope == "" {
+		return errors.New("bearer challenge without a scope")
+	}
+
+	token, err := client.fetchAccessToken(scope)
+
+	if err != nil {
+		return err
+	}
+
+	client.auth.mutex.Lock()
+	client.auth.tokens[scopeOf(resp.Request)] = token
+	client.auth.mutex.Unlock()
+
+	return nil
+}
@@ -0,0 +1,238 @@
+package artifactregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	log "github.com/sirupsen/logrus"
+)
+
+var defaultEndpoint = "https://artifactregistry.googleapis.com"
+
+// lastSegmentOf returns the unescaped last part of a resource name, e.g. the tag name of projects/p/.../tags/latest
+func lastSegmentOf(resourceName string) string {
+	lastSegment := resourceName[strings.LastIndex(resourceName, "/")+1:]
+
+	unescaped, err := url.PathUnescape(lastSegment)
+	if err != nil {
+		return lastSegment
+	}
+
+	return unescaped
+}
+
+func parseTimeMs(timestamp string) (string, error) {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(t.UTC().UnixMilli(), 10), nil
+}
+
+func (client *RegistryClient) locationResourceName() string {
+	return fmt.Sprintf("projects/%s/locations/%s", client.project, client.location)
+}
+
+// packageResourceName converts a repository link e.g. docker-repo/team/app to the resource name of the corresponding package
+func (client *RegistryClient) packageResourceName(repositoryLink string) string {
+	repositoryID, packageName, _ := strings.Cut(repositoryLink, "/")
+
+	// slashes of the image name are escaped inside the package resource name
+	return fmt.Sprintf("%s/repositories/%s/packages/%s", client.locationResourceName(), repositoryID, strings.ReplaceAll(packageName, "/", "%2F"))
+}
+
+// Login sets the access token that is used on every request
+func (client *RegistryClient) Login(username string, password string) error {
+	client.httpClient.InjectAuthInRequest = func(req *http.Request) {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", password))
+	}
+
+	return nil
+}
+
+// Capabilities returns what the client can do; Artifact Registry deletes versions by digest
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest: true,
+	}
+}
+
+// DeleteImage deletes a version of a package, along with all its tags
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	var err error
+
+	for _, digest := range image.Digest {
+		err = client.httpClient.DeleteRequestTo("/"+client.packageResourceName(imageRepo)+"/versions/"+digest+"?force=true", true, silentErrors)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (client *RegistryClient) getDockerRepositories() []RepositoryDTO {
+	repositories := []RepositoryDTO{}
+	pageToken := ""
+
+	for {
+		bodyBytes, err := client.httpClient.GetRequestTo(fmt.Sprintf("/%s/repositories?pageSize=1000&pageToken=%s", client.locationResourceName(), url.QueryEscape(pageToken)))
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		repositoriesResp := RepositoriesDTO{}
+		err = json.Unmarshal(bodyBytes, &repositoriesResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, repository := range repositoriesResp.Repositories {
+			if repository.Format == "DOCKER" {
+				repositories = append(repositories, repository)
+			}
+		}
+
+		pageToken = repositoriesResp.NextPageToken
+		if pageToken == "" { // no more pages to GET
+			break
+		}
+	}
+
+	return repositories
+}
+
+// GetAllRepos returns all the packages of all the docker repositories of the location, in the form <repository>/<image name>
+func (client *RegistryClient) GetAllRepos() []string {
+	links := []string{}
+
+	for _, repository := range client.getDockerRepositories() {
+		repositoryID := lastSegmentOf(repository.Name)
+		pageToken := ""
+
+		for {
+			bodyBytes, err := client.httpClient.GetRequestTo(fmt.Sprintf("/%s/packages?pageSize=1000&pageToken=%s", repository.Name, url.QueryEscape(pageToken)))
+
+			if err != nil {
+				log.Fatalf("Error on api call: %v", err.Error())
+			}
+
+			packagesResp := PackagesDTO{}
+			err = json.Unmarshal(bodyBytes, &packagesResp)
+
+			if err != nil {
+				log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+			}
+
+			for _, pkg := range packagesResp.Packages {
+				links = append(links, repositoryID+"/"+lastSegmentOf(pkg.Name))
+			}
+
+			pageToken = packagesResp.NextPageToken
+			if pageToken == "" { // no more pages to GET
+				break
+			}
+		}
+	}
+
+	return links
+}
+
+// ParseRepo parses all the versions of a package
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	pageToken := ""
+
+	for {
+		bodyBytes, err := client.httpClient.GetRequestTo(fmt.Sprintf("/%s/versions?view=FULL&pageSize=1000&pageToken=%s", client.packageResourceName(repositoryLink), url.QueryEscape(pageToken)))
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		versionsResp := VersionsDTO{}
+		err = json.Unmarshal(bodyBytes, &versionsResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, version := range versionsResp.Versions {
+			repoImage := cr.ContainerImage{
+				Digest:         []string{lastSegmentOf(version.Name)},
+				Tag:            []string{},
+				MediaType:      version.Metadata.MediaType,
+				ImageSizeBytes: version.Metadata.ImageSizeBytes,
+				Repo:           fmt.Sprintf("%s-docker.pkg.dev/%s/%s", client.location, client.project, repositoryLink),
+			}
+
+			for _, tag := range version.RelatedTags {
+				repoImage.Tag = append(repoImage.Tag, lastSegmentOf(tag.Name))
+			}
+
+			uploadedMs, err := parseTimeMs(version.CreateTime)
+			if err != nil {
+				// without an upload time the age and the number rules cannot judge the version, so it is left alone
+				log.Errorf("Version %v contains invalid create time: %v, skipping it", version.Name, version.CreateTime)
+				continue
+			}
+
+			repoImage.TimeUploadedMs = uploadedMs
+			repoImage.TimeCreatedMs = uploadedMs
+
+			if builtMs, err := parseTimeMs(version.Metadata.BuildTime); err == nil {
+				repoImage.TimeCreatedMs = builtMs
+			}
+
+			repository.Images = append(repository.Images, repoImage)
+		}
+
+		pageToken = versionsResp.NextPageToken
+		if pageToken == "" { // no more pages to GET
+			break
+		}
+	}
+
+	return repository
+}
+
+// NewArtifactRegistryClientParams are the required parameters to build an Artifact Registry client
+type NewArtifactRegistryClientParams struct {
+	Project string
+	// Location is the region of the repositories, e.g. europe-west1
+	Location string
+	// Endpoint overrides the Artifact Registry API endpoint, empty means the public google endpoint
+	Endpoint string
+}
+
+// NewArtifactRegistryClient builds a new Artifact Registry client
+func NewArtifactRegistryClient(params NewArtifactRegistryClientParams) cr.Client {
+	endpoint := params.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	return &RegistryClient{
+		httpClient: myhttp.NewClient(myhttp.NewClientParams{
+			BaseURL:             strings.TrimSuffix(endpoint, "/") + "/v1",
+			InjectAuthInRequest: nil, // we set this once we have logged in
+		}),
+		project:  params.Project,
+		location: params.Location,
+	}
+}
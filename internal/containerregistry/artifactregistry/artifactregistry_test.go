@@ -0,0 +1,125 @@
+package artifactregistry
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+)
+
+const locationPath = "/v1/projects/faulty/locations/europe-west1"
+
+// newFakeAPI starts a local stand-in of the Artifact Registry REST API
+func newFakeAPI(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, registrytest.BearerToken("token"))
+
+	registry.Handle("GET", locationPath+"/repositories", registrytest.JSON(RepositoriesDTO{Repositories: []RepositoryDTO{
+		{Name: "projects/faulty/locations/europe-west1/repositories/docker-repo", Format: "DOCKER"},
+		{Name: "projects/faulty/locations/europe-west1/repositories/npm-repo", Format: "NPM"},
+	}}))
+	registry.Handle("GET", locationPath+"/repositories/docker-repo/packages", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("pageToken") == "page2" {
+			_ = json.NewEncoder(w).Encode(PackagesDTO{
+				Packages: []PackageDTO{{Name: "projects/faulty/locations/europe-west1/repositories/docker-repo/packages/worker"}},
+			})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(PackagesDTO{
+			Packages:      []PackageDTO{{Name: "projects/faulty/locations/europe-west1/repositories/docker-repo/packages/team%2Fapp"}},
+			NextPageToken: "page2",
+		})
+	})
+	registry.Handle("GET", locationPath+"/repositories/docker-repo/packages/team%2Fapp/versions", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("view") != "FULL" {
+			t.Error("The versions should be listed with their tags")
+		}
+
+		_ = json.NewEncoder(w).Encode(VersionsDTO{Versions: []VersionDTO{
+			{
+				Name:       "projects/faulty/locations/europe-west1/repositories/docker-repo/packages/team%2Fapp/versions/sha256:abc",
+				CreateTime: "2022-02-02T15:04:05.123456Z",
+				RelatedTags: []TagDTO{
+					{Name: "projects/faulty/locations/europe-west1/repositories/docker-repo/packages/team%2Fapp/tags/latest"},
+					{Name: "projects/faulty/locations/europe-west1/repositories/docker-repo/packages/team%2Fapp/tags/v1"},
+				},
+				Metadata: VersionMetadataDTO{
+					ImageSizeBytes: "1234",
+					MediaType:      "application/vnd.docker.distribution.manifest.v2+json",
+				},
+			},
+			{
+				Name:       "projects/faulty/locations/europe-west1/repositories/docker-repo/packages/team%2Fapp/versions/sha256:def",
+				CreateTime: "yesterday",
+			},
+		}})
+	})
+
+	return registry
+}
+
+func newTestClient(t *testing.T) (cr.Client, *registrytest.Registry) {
+	registry := newFakeAPI(t)
+	client := NewArtifactRegistryClient(NewArtifactRegistryClientParams{
+		Project:  "faulty",
+		Location: "europe-west1",
+		Endpoint: registry.URL,
+	})
+
+	err := client.Login("", "token")
+
+	if err != nil {
+		t.Error("Login should not fail")
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"docker-repo/team/app", "docker-repo/worker"}) {
+		t.Errorf("Wrong repos %v", client.GetAllRepos())
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	repo := client.ParseRepo("docker-repo/team/app")
+
+	if len(repo.Images) != 1 {
+		t.Fatalf("Exactly 1 image should be parsed and the version without a valid create time should be skipped, not %v", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"latest", "v1"}) || !reflect.DeepEqual(image.Digest, []string{"sha256:abc"}) {
+		t.Errorf("Wrong tags or digest: %v %v", image.Tag, image.Digest)
+	}
+
+	if image.ImageSizeBytes != "1234" || image.TimeUploadedMs != "1643814245123" {
+		t.Errorf("Wrong size or upload time: %v %v", image.ImageSizeBytes, image.TimeUploadedMs)
+	}
+
+	if image.Repo != "europe-west1-docker.pkg.dev/faulty/docker-repo/team/app" {
+		t.Errorf("Wrong repo %v", image.Repo)
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	client, registry := newTestClient(t)
+
+	err := client.DeleteImage("docker-repo/team/app", cr.ContainerImage{Digest: []string{"sha256:abc"}}, false)
+
+	if err != nil {
+		t.Error("Delete should not fail")
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{locationPath + "/repositories/docker-repo/packages/team%2Fapp/versions/sha256:abc?force=true"}) {
+		t.Errorf("Wrong deleted versions %v", registry.Deleted())
+	}
+}
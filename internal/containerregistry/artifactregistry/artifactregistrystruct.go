@@ -0,0 +1,68 @@
+package artifactregistry
+
+import myhttp "github.com/hytromo/faulty-crane/internal/http"
+
+// RegistryClient is a Google Artifact Registry client, it talks to the Artifact Registry REST API instead of the docker registry api
+type RegistryClient struct {
+	httpClient myhttp.Client
+	project    string
+	location   string
+}
+
+// RepositoryDTO is the DTO of an Artifact Registry repository
+type RepositoryDTO struct {
+	// Name is the resource name, e.g. projects/p/locations/europe-west1/repositories/docker-repo
+	Name   string
+	Format string
+}
+
+// RepositoriesDTO is the Data Transfer Object for the repositories.list api call
+type RepositoriesDTO struct {
+	Repositories  []RepositoryDTO
+	NextPageToken string
+}
+
+// PackageDTO is the DTO of an Artifact Registry package, which is a docker image name
+type PackageDTO struct {
+	// Name is the resource name, e.g. projects/p/locations/europe-west1/repositories/docker-repo/packages/team%2Fapp
+	Name       string
+	CreateTime string
+	UpdateTime string
+}
+
+// PackagesDTO is the Data Transfer Object for the packages.list api call
+type PackagesDTO struct {
+	Packages      []PackageDTO
+	NextPageToken string
+}
+
+// TagDTO is the DTO of a tag that points to a version
+type TagDTO struct {
+	// Name is the resource name, e.g. projects/p/locations/europe-west1/repositories/docker-repo/packages/app/tags/latest
+	Name    string
+	Version string
+}
+
+// VersionMetadataDTO is the docker specific metadata of a version
+type VersionMetadataDTO struct {
+	ImageSizeBytes string
+	MediaType      string
+	BuildTime      string
+	Name           string
+}
+
+// VersionDTO is the DTO of a package version, which is a docker image manifest
+type VersionDTO struct {
+	// Name is the resource name, e.g. projects/p/locations/europe-west1/repositories/docker-repo/packages/app/versions/sha256:abc
+	Name        string
+	CreateTime  string
+	UpdateTime  string
+	RelatedTags []TagDTO
+	Metadata    VersionMetadataDTO
+}
+
+// VersionsDTO is the Data Transfer Object for the versions.list api call
+type VersionsDTO struct {
+	Versions      []VersionDTO
+	NextPageToken string
+}
@@ -1,31 +1,80 @@
 package containerregistry
 
-import "github.com/hytromo/faulty-crane/internal/keepreasons"
+import (
+	"errors"
+
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+// ErrImageProtected is returned by DeleteImage when the registry refuses to delete the image on purpose, e.g. because of an immutability rule; such images are skipped instead of being counted as failures
+var ErrImageProtected = errors.New("image is protected by the registry")
 
 // Repository is a struct that holds information about a container registry's repository
 type Repository struct {
 	// Link is the relative link, also referred to as "image name" on the documentation, each repository can contain a lot of images with different tags and manifests
-	Link   string
-	Images []ContainerImage
+	Link string
+	// Registry is the name of the registry target that the repository belongs to, empty for the registry of a single-registry run
+	Registry string `json:",omitempty"`
+	// Host is the host of the repository, set by the registries that sweep many hosts, e.g. the regional hosts of GCR
+	Host string `json:",omitempty"`
+	// TimeLastUpdatedMs is set by the registries that track when a repository was last updated
+	TimeLastUpdatedMs string `json:",omitempty"`
+	// DeletionReason is set when the whole repository is planned to be deleted after its images
+	DeletionReason RepositoryDeletionReason `json:",omitempty"`
+	Images         []ContainerImage
 }
 
 // ContainerImage contains all the data that are relevant to an image on the registry
 type ContainerImage struct {
-	ImageSizeBytes string
-	LayerID        string `json:"layerId"`
-	MediaType      string
-	Tag            []string
-	TimeCreatedMs  string
-	TimeUploadedMs string
-	Digest         []string
-	Repo           string               // Repo is the name of the image's repository without the tag in the form e.g. eu.gcr.io/faulty-crane-project/faulty-crane-test
-	KeptData       keepreasons.KeptData `json:",omitempty"`
+	ImageSizeBytes   string // ImageSizeBytes is empty when the registry does not report the size of the image, e.g. on GHCR
+	LayerID          string `json:"layerId"`
+	VersionID        string `json:",omitempty"` // VersionID is the id of the package version that the image is stored as, set by the registries that delete by version id, e.g. GHCR
+	MediaType        string
+	Tag              []string
+	TimeCreatedMs    string
+	TimeUploadedMs   string
+	TimeLastPulledMs string `json:",omitempty"` // TimeLastPulledMs is set by registries that track pulls and is empty when the image has never been pulled
+	TimeExpiresMs    string `json:",omitempty"` // TimeExpiresMs is set when the registry itself is going to delete the image at that time, e.g. on quay tag expiration
+	Digest           []string
+	Repo             string               // Repo is the name of the image's repository without the tag in the form e.g. eu.gcr.io/faulty-crane-project/faulty-crane-test
+	Children         []string             `json:",omitempty"` // Children are the digests of the manifests that an image index / manifest list references, e.g. one per platform
+	Parents          []string             `json:",omitempty"` // Parents are the digests of the image indexes of the repository that reference this manifest
+	Subject          string               `json:",omitempty"` // Subject is the digest of the image that this artifact refers to, e.g. the image that a signature or an sbom is about
+	Labels           map[string]string    `json:",omitempty"` // Labels are the labels of the image configuration, e.g. org.opencontainers.image.revision; set by the enrichment
+	Annotations      map[string]string    `json:",omitempty"` // Annotations are the annotations of the manifest; set by the enrichment
+	Platform         *Platform            `json:",omitempty"` // Platform is the platform of the image configuration; set by the enrichment
+	ConfigCreatedMs  string               `json:",omitempty"` // ConfigCreatedMs is the creation time that the image configuration records, which survives re-pushes; set by the enrichment
+	Layers           []Layer              `json:",omitempty"` // Layers are the layer blobs of the image manifest, which images share; set by the registries that read the manifests anyway and by the enrichment
+	KeptData         keepreasons.KeptData `json:",omitempty"`
+}
+
+// Layer is a layer blob of an image
+type Layer struct {
+	Digest string
+	Size   int64
+}
+
+// Platform is the platform that an image is built for
+type Platform struct {
+	OS           string
+	Architecture string
+	Variant      string `json:",omitempty"`
 }
 
 // RepoDeletionResult is the repository deletion result
 type RepoDeletionResult struct {
 	ShouldDeleteCount    int
 	ManagedToDeleteCount int
+	// SkippedCount is the number of images that the registry refused to delete because they are protected
+	SkippedCount int
+	// ScheduledCount is the number of images that the registry accepted to delete later, e.g. through an asynchronous bulk deletion
+	ScheduledCount int
+	// FailedCount is the number of images that could not be deleted because of an error
+	FailedCount int
+	// ShouldDeleteRepositoriesCount is the number of repositories that are planned to be deleted
+	ShouldDeleteRepositoriesCount int
+	// ManagedToDeleteRepositoriesCount is the number of repositories that were deleted; a repository is deleted only after all its images are, with no failed, skipped or scheduled ones
+	ManagedToDeleteRepositoriesCount int
 }
 
 // CatalogDTO is the Data Transfer Object for the catalog api call
@@ -44,10 +93,63 @@ type ListTagsDTO struct {
 	Next     string
 }
 
+// ManifestListDTO is the Data Transfer Object of an image index / manifest list, as returned by the manifests api call
+type ManifestListDTO struct {
+	MediaType string
+	Manifests []struct {
+		MediaType string
+		Digest    string
+	}
+}
+
+// Capabilities describe what a registry client can do, so that the policies that a registry cannot honour are refused when planning instead of doing nothing when applying
+type Capabilities struct {
+	// DeleteByDigest means that images are deleted by their digest, including the untagged ones
+	DeleteByDigest bool `json:",omitempty"`
+	// DeleteTagsOnly means that deleting an image deletes its tags and the registry garbage collects the rest, e.g. Docker Hub and Quay
+	DeleteTagsOnly bool `json:",omitempty"`
+	// BulkDelete means that the client deletes many images at once, i.e. it implements BulkDeleter or RepositoriesBulkDeleter
+	BulkDelete bool `json:",omitempty"`
+	// LastPulled means that the images carry the time they were last pulled, which the rules about pulls need
+	LastPulled bool `json:",omitempty"`
+	// DeleteRepository means that the client deletes whole repositories, i.e. it implements RepositoryDeleter
+	DeleteRepository bool `json:",omitempty"`
+	// ManifestLists means that the untagged platform manifests of image indexes are linked to their indexes, so that they follow the decision of their indexes; without it, the untagged manifests of a repository with a kept image index cannot be told apart from its platform manifests
+	ManifestLists bool `json:",omitempty"`
+	// FetchManifests means that the client fetches the manifests and the configurations of the images, i.e. it implements ManifestFetcher
+	FetchManifests bool `json:",omitempty"`
+}
+
 // Client is used for implementing container registry clients
 type Client interface {
 	Login(username string, password string) error
 	DeleteImage(imageRepo string, image ContainerImage, silentErrors bool) error
 	GetAllRepos() []string
 	ParseRepo(repositoryLink string) Repository
+	Capabilities() Capabilities
+}
+
+// BulkDeleter is implemented by clients that can delete many images of a repository in a few api calls, instead of one call per image
+type BulkDeleter interface {
+	// DeleteImages deletes the given images of the repository and returns how many of them were deleted and how many the registry accepted to delete later on its own, e.g. asynchronously
+	DeleteImages(imageRepo string, images []ContainerImage, silentErrors bool) (deletedCount int, scheduledCount int, err error)
+}
+
+// RepositoriesBulkDeleter is implemented by clients whose bulk deletion spans many repositories, e.g. all the repositories of a Docker Hub namespace; such clients get all the repositories at once instead of one by one
+type RepositoriesBulkDeleter interface {
+	// DeleteRepositoriesImages deletes the images of the repositories that do not have a keep reason and returns how many of them were deleted per repository link
+	DeleteRepositoriesImages(repos []Repository, silentErrors bool) (map[string]int, error)
+}
+
+// ManifestFetcher is implemented by clients that can fetch the manifests and the blobs of the images, which the enrichment of the images needs
+type ManifestFetcher interface {
+	// GetManifest returns the manifest of an image by its digest
+	GetManifest(repositoryLink string, digest string) ([]byte, error)
+	// GetBlob returns a blob of a repository by its digest, e.g. the configuration of an image
+	GetBlob(repositoryLink string, digest string) ([]byte, error)
+}
+
+// RepositoryDeleter is implemented by clients that can delete whole repositories
+type RepositoryDeleter interface {
+	DeleteRepository(repositoryLink string, silentErrors bool) error
 }
@@ -2,9 +2,11 @@ package dockerhub
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/Rican7/conjson"
@@ -16,32 +18,99 @@ import (
 
 var baseURL = "https://hub.docker.com/v2"
 
-// Login logs in into dockerhub
-func (client *RegistryClient) Login(username string, password string) error {
+// deleteImagesBatchSize is how many manifests are sent in each call of the bulk image deletion endpoint
+var deleteImagesBatchSize = 100
+
+// loginRejectionStatuses are the statuses with which the login endpoints reject the credentials, which is pointless to retry
+var loginRejectionStatuses = []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden}
+
+// authTokenLogin exchanges the username (or organization name) and an access token for a bearer token
+func (client *RegistryClient) authTokenLogin(identifier string, secret string) (string, error) {
+	jsonPayload, _ := json.Marshal(map[string]interface{}{
+		"identifier": identifier,
+		"secret":     secret,
+	})
+
+	bodyBytes, err := client.httpClient.PostRequestAnsweringTo("/auth/token", jsonPayload, loginRejectionStatuses, true)
+
+	if err != nil {
+		return "", err
+	}
+
+	authTokenResp := AuthTokenDTO{}
+	err = json.Unmarshal(bodyBytes, &authTokenResp)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	if authTokenResp.AccessToken == "" {
+		return "", errors.New("the response does not contain an access token")
+	}
+
+	return authTokenResp.AccessToken, nil
+}
+
+// passwordLogin exchanges a username and a plain password for a bearer token through the legacy login endpoint
+func (client *RegistryClient) passwordLogin(username string, password string) (string, error) {
 	jsonPayload, _ := json.Marshal(map[string]interface{}{
 		"username": username,
 		"password": password,
 	})
 
-	bodyBytes, err := client.httpClient.PostRequestTo("/users/login", jsonPayload, false, false)
+	bodyBytes, err := client.httpClient.PostRequestAnsweringTo("/users/login", jsonPayload, loginRejectionStatuses, true)
 
 	if err != nil {
-		log.Fatalf("Failed to login to dockerhub with error: %v", err.Error())
+		return "", err
 	}
 
 	loginResp := UsersLoginDTO{}
 	err = json.Unmarshal(bodyBytes, &loginResp)
 
 	if err != nil {
-		log.Fatalf("Failed to parse dockerhub login response with error: %v", err.Error())
+		return "", fmt.Errorf("invalid response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	if loginResp.Token == "" {
+		return "", errors.New("the response does not contain a token")
+	}
+
+	return loginResp.Token, nil
+}
+
+// Login logs in into dockerhub; the password can also be a personal or an organization access token
+func (client *RegistryClient) Login(username string, password string) error {
+	token, tokenErr := client.authTokenLogin(username, password)
+
+	if tokenErr != nil {
+		// plain passwords are only accepted by the legacy login endpoint
+		var passwordErr error
+		token, passwordErr = client.passwordLogin(username, password)
+
+		if passwordErr != nil {
+			return fmt.Errorf("dockerhub rejected the credentials both as an access token (%v) and as a password (%v)", tokenErr, passwordErr)
+		}
 	}
 
-	client.token = loginResp.Token
+	client.token = token
+	client.setAuthHeader()
+
+	return nil
+}
+
+func (client *RegistryClient) setAuthHeader() {
 	client.httpClient.InjectAuthInRequest = func(req *http.Request) {
 		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", client.token))
 	}
+}
 
-	return err
+// Capabilities returns what the client can do; Docker Hub deletes tags only, but it knows when they were last pulled and it can delete whole repositories
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteTagsOnly:   true,
+		LastPulled:       true,
+		DeleteRepository: true,
+	}
 }
 
 // DeleteImage delets an image from dockerhub
@@ -59,12 +128,230 @@ func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerIm
 	return nil
 }
 
-// GetAllRepos parses all dockerhub repositories
+// DeleteRepository deletes a dockerhub repository along with everything that is left in it
+func (client *RegistryClient) DeleteRepository(repositoryLink string, silentErrors bool) error {
+	return client.httpClient.DeleteRequestTo("/repositories/"+repositoryLink+"/", true, silentErrors)
+}
+
+// Capabilities returns what the client can do; it deletes the manifests of the images by digest in bulk, instead of their tags only, and links the untagged platform manifests to the multi-arch tags that they belong to
+func (client *ManifestRegistryClient) Capabilities() cr.Capabilities {
+	capabilities := client.RegistryClient.Capabilities()
+	capabilities.DeleteTagsOnly = false
+	capabilities.DeleteByDigest = true
+	capabilities.BulkDelete = true
+	capabilities.ManifestLists = true
+
+	return capabilities
+}
+
+// manifestsOfImage are the manifests of an image that are deleted through the bulk image deletion endpoint
+type manifestsOfImage struct {
+	repositoryLink string
+	manifests      []DeleteImagesManifestDTO
+	// platformImagesCount is the number of untagged platform manifests that go along with a multi-arch tag, as they are among its manifests
+	platformImagesCount int
+}
+
+// sharesManifests returns if any of the manifests of an image belongs to a kept image as well, e.g. a deleted and a kept tag of the same manifest
+func sharesManifests(image cr.ContainerImage, keptDigests map[string]bool) bool {
+	for _, digest := range image.Digest {
+		if keptDigests[digest] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// batchesOf splits the images into batches of at most deleteImagesBatchSize manifests; the manifests of an image are never split
+func batchesOf(images []manifestsOfImage) [][]manifestsOfImage {
+	batches := [][]manifestsOfImage{}
+	batch := []manifestsOfImage{}
+	batchManifestsCount := 0
+
+	for _, image := range images {
+		if len(batch) > 0 && batchManifestsCount+len(image.manifests) > deleteImagesBatchSize {
+			batches = append(batches, batch)
+			batch = []manifestsOfImage{}
+			batchManifestsCount = 0
+		}
+
+		batch = append(batch, image)
+		batchManifestsCount += len(image.manifests)
+	}
+
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+
+	return batches
+}
+
+// deleteManifests deletes a batch of manifests of a namespace along with their tags through the bulk image deletion endpoint
+func (client *ManifestRegistryClient) deleteManifests(namespace string, batch []manifestsOfImage, silentErrors bool) error {
+	request := DeleteImagesRequestDTO{
+		Manifests:      []DeleteImagesManifestDTO{},
+		IgnoreWarnings: []DeleteImagesWarningDTO{},
+	}
+	requestedManifests := map[DeleteImagesManifestDTO]bool{}
+
+	for _, image := range batch {
+		for _, manifest := range image.manifests {
+			if requestedManifests[manifest] {
+				// the tags of the same manifest are separate images
+				continue
+			}
+
+			requestedManifests[manifest] = true
+			request.Manifests = append(request.Manifests, manifest)
+			// the plan has already decided that these manifests should go, even if they were pulled recently or are still tagged
+			request.IgnoreWarnings = append(request.IgnoreWarnings,
+				DeleteImagesWarningDTO{Repository: manifest.Repository, Digest: manifest.Digest, Warning: "is_active"},
+				DeleteImagesWarningDTO{Repository: manifest.Repository, Digest: manifest.Digest, Warning: "current_tag"},
+			)
+		}
+	}
+
+	jsonPayload, _ := json.Marshal(request)
+
+	bodyBytes, err := client.httpClient.PostRequestTo("/namespaces/"+namespace+"/delete-images", jsonPayload, true, silentErrors)
+
+	if err != nil {
+		return err
+	}
+
+	deleteResp := DeleteImagesDTO{}
+	err = json.Unmarshal(bodyBytes, &deleteResp)
+
+	if err != nil {
+		return fmt.Errorf("invalid delete-images response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	if deleteResp.Metrics.ManifestErrors > 0 {
+		return fmt.Errorf("could not delete %v of the %v manifest(s) of %v", deleteResp.Metrics.ManifestErrors, len(request.Manifests), namespace)
+	}
+
+	return nil
+}
+
+// DeleteRepositoriesImages deletes the manifests of the images that do not have a keep reason along with their tags, in batches of the bulk image deletion endpoint that span all the repositories of each namespace; when a kept image shares the manifests of an image, only the tags of the image are deleted. An image counts as deleted only when its whole batch is
+func (client *ManifestRegistryClient) DeleteRepositoriesImages(repos []cr.Repository, silentErrors bool) (map[string]int, error) {
+	deletedCounts := map[string]int{}
+	namespaces := []string{}
+	imagesOfNamespace := map[string][]manifestsOfImage{}
+	var firstErr error
+
+	for _, repo := range repos {
+		namespace, repositoryName, _ := strings.Cut(repo.Link, "/")
+		keptDigests := map[string]bool{}
+		// the positions of the deleted multi-arch tags in the images of the namespace, by their own digest
+		deletedTagOfDigest := map[string]int{}
+		platformImages := []cr.ContainerImage{}
+
+		for _, image := range repo.Images {
+			if image.KeptData.Reason.IsKept() {
+				for _, digest := range image.Digest {
+					keptDigests[digest] = true
+				}
+			}
+		}
+
+		for _, image := range repo.Images {
+			if image.KeptData.Reason.IsKept() {
+				continue
+			}
+
+			if sharesManifests(image, keptDigests) {
+				// deleting the manifests would delete the kept image as well
+				err := client.DeleteImage(repo.Link, image, silentErrors)
+
+				if err == nil {
+					deletedCounts[repo.Link]++
+				} else if firstErr == nil {
+					firstErr = err
+				}
+
+				continue
+			}
+
+			if len(image.Tag) == 0 && len(image.Parents) > 0 {
+				platformImages = append(platformImages, image)
+				continue
+			}
+
+			imageManifests := manifestsOfImage{repositoryLink: repo.Link}
+
+			for _, digest := range image.Digest {
+				imageManifests.manifests = append(imageManifests.manifests, DeleteImagesManifestDTO{Repository: repositoryName, Digest: digest})
+			}
+
+			if _, exists := imagesOfNamespace[namespace]; !exists {
+				namespaces = append(namespaces, namespace)
+			}
+
+			deletedTagOfDigest[image.Digest[0]] = len(imagesOfNamespace[namespace])
+			imagesOfNamespace[namespace] = append(imagesOfNamespace[namespace], imageManifests)
+		}
+
+		// a platform manifest without a keep reason belongs to deleted tags only, so it goes along with the first one
+		for _, image := range platformImages {
+			if tagPosition, isDeleted := deletedTagOfDigest[image.Parents[0]]; isDeleted {
+				imagesOfNamespace[namespace][tagPosition].platformImagesCount++
+				continue
+			}
+
+			if _, exists := imagesOfNamespace[namespace]; !exists {
+				namespaces = append(namespaces, namespace)
+			}
+
+			imagesOfNamespace[namespace] = append(imagesOfNamespace[namespace], manifestsOfImage{
+				repositoryLink: repo.Link,
+				manifests:      []DeleteImagesManifestDTO{{Repository: repositoryName, Digest: image.Digest[0]}},
+			})
+		}
+	}
+
+	for _, namespace := range namespaces {
+		for _, batch := range batchesOf(imagesOfNamespace[namespace]) {
+			err := client.deleteManifests(namespace, batch, silentErrors)
+
+			if err != nil {
+				if !silentErrors {
+					log.Errorf("Could not delete a batch of %v image(s) of %v: %v", len(batch), namespace, err.Error())
+				}
+
+				if firstErr == nil {
+					firstErr = err
+				}
+
+				continue
+			}
+
+			for _, image := range batch {
+				deletedCounts[image.repositoryLink] += 1 + image.platformImagesCount
+			}
+		}
+	}
+
+	return deletedCounts, firstErr
+}
+
+// GetAllRepos parses the dockerhub repositories of all the namespaces
 func (client *RegistryClient) GetAllRepos() []string {
 	repositories := []string{}
 
+	for _, namespace := range client.namespaces {
+		repositories = append(repositories, client.getNamespaceRepos(namespace)...)
+	}
+
+	return repositories
+}
+
+func (client *RegistryClient) getNamespaceRepos(namespace string) []string {
+	repositories := []string{}
+
 	repositoryResp := RepositoryDTO{
-		Next: fmt.Sprintf("/repositories/%s?page_size=100", client.namespace), // initial request
+		Next: fmt.Sprintf("/repositories/%s?page_size=100", namespace), // initial request
 	}
 
 	for {
@@ -92,7 +379,12 @@ func (client *RegistryClient) GetAllRepos() []string {
 		}
 
 		for _, result := range repositoryResp.Results {
-			repositories = append(repositories, fmt.Sprintf("%s/%s", client.namespace, result.Name))
+			repositoryLink := fmt.Sprintf("%s/%s", namespace, result.Name)
+			repositories = append(repositories, repositoryLink)
+
+			if updatedTime, err := time.Parse(time.RFC3339Nano, result.LastUpdated); err == nil {
+				client.lastUpdated[repositoryLink] = strconv.FormatInt(updatedTime.UTC().UnixMilli(), 10)
+			}
 		}
 
 		if repositoryResp.Next == "" { // no more pages to GET
@@ -103,11 +395,41 @@ func (client *RegistryClient) GetAllRepos() []string {
 	return repositories
 }
 
+// lastPulledMsOf returns when a tag or any of its platform images was last pulled, or an empty string if it has never been pulled
+func lastPulledMsOf(result TagResultDTO) string {
+	var lastPulledMs int64 = 0
+
+	for _, lastPulled := range append([]string{result.TagLastPulled}, imagesLastPulledOf(result)...) {
+		pulledTime, err := time.Parse(time.RFC3339Nano, lastPulled)
+
+		if err == nil && pulledTime.UTC().UnixMilli() > lastPulledMs {
+			lastPulledMs = pulledTime.UTC().UnixMilli()
+		}
+	}
+
+	if lastPulledMs == 0 {
+		return ""
+	}
+
+	return strconv.FormatInt(lastPulledMs, 10)
+}
+
+func imagesLastPulledOf(result TagResultDTO) []string {
+	imagesLastPulled := []string{}
+
+	for _, image := range result.Images {
+		imagesLastPulled = append(imagesLastPulled, image.LastPulled)
+	}
+
+	return imagesLastPulled
+}
+
 // ParseRepo parses a specific repository
 func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
 	repository := cr.Repository{
-		Link:   repositoryLink,
-		Images: []cr.ContainerImage{},
+		Link:              repositoryLink,
+		TimeLastUpdatedMs: client.lastUpdated[repositoryLink],
+		Images:            []cr.ContainerImage{},
 	}
 
 	listTagsResp := TagsDTO{
@@ -146,13 +468,26 @@ func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
 				repoImage.TimeUploadedMs = updatedMs
 			}
 
+			repoImage.TimeLastPulledMs = lastPulledMsOf(result)
 			repoImage.LayerID = strconv.FormatInt(result.ID, 10)
 			repoImage.MediaType = "application/vnd.docker.distribution.manifest.v2+json"
 			repoImage.Repo = repositoryLink
 
+			if result.MediaType != "" {
+				repoImage.MediaType = result.MediaType
+			}
+
+			if result.Digest != "" {
+				// the digest of the tag goes first, as it is the one that the signatures and the other referrers of multi-arch images point to
+				repoImage.Digest = append(repoImage.Digest, result.Digest)
+			}
+
 			for _, image := range result.Images {
 				totalImageSize += int64(image.Size)
-				repoImage.Digest = append(repoImage.Digest, image.Digest)
+
+				if image.Digest != result.Digest {
+					repoImage.Digest = append(repoImage.Digest, image.Digest)
+				}
 			}
 
 			repoImage.ImageSizeBytes = strconv.FormatInt(totalImageSize, 10)
@@ -172,18 +507,106 @@ func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
 	return repository
 }
 
+// ParseRepo parses the tags of a specific repository along with its untagged manifests
+func (client *ManifestRegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := client.RegistryClient.ParseRepo(repositoryLink)
+	namespace, repositoryName, _ := strings.Cut(repositoryLink, "/")
+	next := "/namespaces/" + namespace + "/repositories/" + repositoryName + "/images?currently_tagged=false&page_size=100" // initial request
+
+	for next != "" {
+		bodyBytes, err := client.httpClient.GetRequestTo(next)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		imagesResp := ImagesDTO{}
+		err = json.Unmarshal(bodyBytes, &imagesResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, image := range imagesResp.Results {
+			// the size of untagged manifests is not reported, so it stays unknown
+			repoImage := cr.ContainerImage{
+				Tag:    []string{},
+				Digest: []string{image.Digest},
+				Repo:   repositoryLink,
+			}
+
+			pushedTime, err := time.Parse(time.RFC3339Nano, image.LastPushed)
+
+			if err != nil {
+				// without an upload time the age and the number rules cannot judge the manifest, so it is left alone
+				log.Errorf("Manifest %v of %v contains invalid push time: %v, skipping it", image.Digest, repositoryLink, image.LastPushed)
+				continue
+			}
+
+			pushedMs := strconv.FormatInt(pushedTime.UTC().UnixMilli(), 10)
+			repoImage.TimeCreatedMs = pushedMs
+			repoImage.TimeUploadedMs = pushedMs
+
+			pulledTime, err := time.Parse(time.RFC3339Nano, image.LastPulled)
+
+			if err == nil {
+				repoImage.TimeLastPulledMs = strconv.FormatInt(pulledTime.UTC().UnixMilli(), 10)
+			}
+
+			repository.Images = append(repository.Images, repoImage)
+		}
+
+		next = imagesResp.Next
+	}
+
+	linkPlatformManifests(repository.Images)
+
+	return repository
+}
+
+// linkPlatformManifests sets the multi-arch tags that reference an untagged manifest as its parents, as the tags carry the digests of their platform manifests after their own
+func linkPlatformManifests(images []cr.ContainerImage) {
+	parentsOfDigest := map[string][]string{}
+
+	for _, image := range images {
+		if len(image.Tag) == 0 || len(image.Digest) < 2 {
+			continue
+		}
+
+		for _, digest := range image.Digest[1:] {
+			parentsOfDigest[digest] = append(parentsOfDigest[digest], image.Digest[0])
+		}
+	}
+
+	for i, image := range images {
+		if len(image.Tag) == 0 && len(image.Digest) > 0 {
+			images[i].Parents = parentsOfDigest[image.Digest[0]]
+		}
+	}
+}
+
 // NewHubClientParams is the required parameters to build a new client
 type NewHubClientParams struct {
-	Namespace string
+	// Namespaces are the users or organizations whose repositories are cleaned
+	Namespaces []string
+	// DeleteManifests makes the client also delete the untagged manifests of the repositories
+	DeleteManifests bool
 }
 
 // NewHubClient builds a new client
 func NewHubClient(params NewHubClientParams) cr.Client {
-	return &RegistryClient{
+	client := &RegistryClient{
 		httpClient: myhttp.NewClient(myhttp.NewClientParams{
 			BaseURL:             baseURL,
 			InjectAuthInRequest: nil, // we set this once we have logged in
 		}),
-		namespace: params.Namespace,
+		namespaces:  params.Namespaces,
+		lastUpdated: map[string]string{},
 	}
+
+	if params.DeleteManifests {
+		return &ManifestRegistryClient{client}
+	}
+
+	return client
 }
@@ -0,0 +1,341 @@
+package dockerhub
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hytromo/faulty-crane/internal/configuration"
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+	"github.com/hytromo/faulty-crane/internal/imagefilters"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+// indexDigest is the digest of the image index of a multi-arch tag
+var indexDigest = "sha256:" + strings.Repeat("1", 64)
+
+// authorize lets the logins through, as they hand out the access tokens that all the other requests need
+func authorize(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Path == "/auth/token" || r.URL.Path == "/users/login" {
+		return true
+	}
+
+	return registrytest.BearerToken("access")(w, r)
+}
+
+// newFakeAPI starts a local stand-in of the docker hub api; access tokens are accepted by /auth/token while plain passwords only by /users/login
+func newFakeAPI(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, authorize)
+
+	registry.Handle("POST", "/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		credentials := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&credentials)
+
+		if credentials["identifier"] != "my-org" || credentials["secret"] != "dckr_oat_token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"message": "incorrect authentication credentials"}`))
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(AuthTokenDTO{AccessToken: "access"})
+	})
+	registry.Handle("POST", "/users/login", func(w http.ResponseWriter, r *http.Request) {
+		credentials := map[string]string{}
+		_ = json.NewDecoder(r.Body).Decode(&credentials)
+
+		if credentials["username"] != "user" || credentials["password"] != "password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(UsersLoginDTO{Token: "access"})
+	})
+	registry.Handle("GET", "/repositories/my-org", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "" {
+			_, _ = w.Write([]byte(`{"count": 2, "next": null, "results": [{"name": "worker", "last_updated": "2021-03-04T05:06:07.123456Z"}]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"count": 2, "next": "` + "http://" + r.Host + `/repositories/my-org?page=2&page_size=100", "results": [{"name": "app"}]}`))
+	})
+	registry.Handle("GET", "/repositories/my-org/worker/tags", registrytest.Status(http.StatusOK, `{"count": 0, "next": null, "results": []}`))
+	registry.Handle("GET", "/repositories/my-org/signed/tags", registrytest.Status(http.StatusOK, `{"count": 2, "next": null, "results": [`+
+		`{"id": 8, "name": "v1", "digest": "`+indexDigest+`", "media_type": "application/vnd.oci.image.index.v1+json", "tag_last_pushed": "2022-02-02T15:04:05.123456Z", "images": [{"digest": "sha256:amd64", "size": 10}, {"digest": "sha256:arm64", "size": 12}]},`+
+		`{"id": 9, "name": "sha256-`+strings.TrimPrefix(indexDigest, "sha256:")+`.sig", "digest": "sha256:signature", "media_type": "application/vnd.oci.image.manifest.v1+json", "tag_last_pushed": "2022-02-02T15:04:06.123456Z", "images": [{"digest": "sha256:signature", "size": 1}]}]}`))
+	registry.Handle("GET", "/namespaces/my-org/repositories/signed/images", registrytest.Status(http.StatusOK, `{"count": 2, "next": null, "results": [`+
+		`{"digest": "sha256:arm64", "tags": [], "last_pushed": "2022-02-02T15:04:05Z", "last_pulled": null, "status": "active"},`+
+		`{"digest": "sha256:dangling", "tags": [], "last_pushed": "2022-01-01T10:00:00Z", "last_pulled": null, "status": "inactive"}]}`))
+	registry.Handle("GET", "/repositories/user", registrytest.Status(http.StatusOK, `{"count": 1, "next": null, "results": [{"name": "tools"}]}`))
+	registry.Handle("GET", "/repositories/my-org/app/tags", registrytest.Status(http.StatusOK, `{"count": 1, "next": null, "results": [{"id": 7, "name": "latest", "tag_last_pushed": "2022-02-02T15:04:05.123456Z", "tag_last_pulled": "2022-02-03T15:04:05.123456Z", "images": [{"digest": "sha256:aaa", "size": 10, "last_pulled": "2022-02-04T15:04:05.123456Z"}]}]}`))
+	registry.Handle("GET", "/namespaces/my-org/repositories/app/images", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "" {
+			_, _ = w.Write([]byte(`{"count": 2, "next": null, "results": [{"digest": "sha256:ccc", "tags": [], "last_pushed": "2021-01-01T10:00:00Z", "last_pulled": null, "status": "inactive"}]}`))
+			return
+		}
+
+		if r.URL.Query().Get("currently_tagged") != "false" {
+			t.Error("Only untagged manifests should be listed")
+		}
+
+		_, _ = w.Write([]byte(`{"count": 2, "next": "` + "http://" + r.Host + `/namespaces/my-org/repositories/app/images?currently_tagged=false&page=2", "results": [{"digest": "sha256:bbb", "tags": [], "last_pushed": "2022-01-01T10:00:00Z", "last_pulled": "2022-01-05T10:00:00Z", "status": "active"}]}`))
+	})
+	registry.Handle("POST", "/namespaces/my-org/delete-images", func(w http.ResponseWriter, r *http.Request) {
+		request := DeleteImagesRequestDTO{}
+		_ = json.NewDecoder(r.Body).Decode(&request)
+
+		if len(request.IgnoreWarnings) != 2*len(request.Manifests) {
+			t.Error("The activity and the current tag warnings of all the manifests should be ignored")
+		}
+
+		registry.Record(registrytest.Deletion{Path: "batch"})
+
+		for _, manifest := range request.Manifests {
+			registry.Record(registrytest.Deletion{Path: manifest.Repository + "@" + manifest.Digest})
+		}
+
+		response := DeleteImagesDTO{}
+		response.Metrics.ManifestDeletes = len(request.Manifests)
+		_ = json.NewEncoder(w).Encode(response)
+	})
+
+	return registry
+}
+
+func newTestClient(t *testing.T, namespaces []string, deleteManifests bool) (cr.Client, *registrytest.Registry) {
+	registry := newFakeAPI(t)
+
+	originalBaseURL := baseURL
+	baseURL = registry.URL
+	t.Cleanup(func() { baseURL = originalBaseURL })
+
+	return NewHubClient(NewHubClientParams{
+		Namespaces:      namespaces,
+		DeleteManifests: deleteManifests,
+	}), registry
+}
+
+func TestAccessTokenLogin(t *testing.T) {
+	client, _ := newTestClient(t, []string{"my-org"}, false)
+
+	if err := client.Login("my-org", "dckr_oat_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if repos := client.GetAllRepos(); !reflect.DeepEqual(repos, []string{"my-org/app", "my-org/worker"}) {
+		t.Errorf("Wrong repositories %v", repos)
+	}
+}
+
+func TestLoginFailure(t *testing.T) {
+	client, _ := newTestClient(t, []string{"my-org"}, false)
+
+	err := client.Login("my-org", "wrong")
+
+	if err == nil || !strings.Contains(err.Error(), "incorrect authentication credentials") {
+		t.Errorf("The error of the access token login should be reported, got %v", err)
+	}
+}
+
+func TestMultipleNamespaces(t *testing.T) {
+	client, _ := newTestClient(t, []string{"my-org", "user"}, false)
+
+	// a plain password falls back to the legacy login
+	if err := client.Login("user", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	if repos := client.GetAllRepos(); !reflect.DeepEqual(repos, []string{"my-org/app", "my-org/worker", "user/tools"}) {
+		t.Errorf("Wrong repositories %v", repos)
+	}
+}
+
+func TestUntaggedManifests(t *testing.T) {
+	client, registry := newTestClient(t, []string{"my-org"}, true)
+
+	if err := client.Login("my-org", "dckr_oat_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	if capabilities := client.Capabilities(); !capabilities.DeleteByDigest || capabilities.DeleteTagsOnly || !capabilities.BulkDelete || !capabilities.LastPulled || !capabilities.ManifestLists {
+		t.Errorf("Wrong capabilities %+v", capabilities)
+	}
+
+	repository := client.ParseRepo("my-org/app")
+
+	if len(repository.Images) != 3 {
+		t.Fatalf("The tag and both untagged manifests should be parsed, got %v", repository.Images)
+	}
+
+	// the most recent pull of the tag and its platform images
+	if repository.Images[0].TimeLastPulledMs != "1643987045123" {
+		t.Errorf("Wrong pull time of the tag %v", repository.Images[0].TimeLastPulledMs)
+	}
+
+	untagged := repository.Images[1]
+
+	if len(untagged.Tag) != 0 || !reflect.DeepEqual(untagged.Digest, []string{"sha256:bbb"}) || untagged.TimeUploadedMs != "1641031200000" || untagged.TimeLastPulledMs != "1641376800000" {
+		t.Errorf("Wrong untagged manifest %+v", untagged)
+	}
+
+	if repository.Images[2].TimeLastPulledMs != "" {
+		t.Error("A manifest that was never pulled should have no pull time")
+	}
+
+	worker := cr.Repository{
+		Link: "my-org/worker",
+		Images: []cr.ContainerImage{
+			{Digest: []string{"sha256:ddd"}},
+			{Tag: []string{"stable"}, Digest: []string{"sha256:eee"}, KeptData: keepreasons.KeptData{Reason: keepreasons.WhitelistedTag}},
+			{Tag: []string{"old"}, Digest: []string{"sha256:eee"}},
+		},
+	}
+
+	deletedCounts, err := client.(cr.RepositoriesBulkDeleter).DeleteRepositoriesImages([]cr.Repository{repository, worker}, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(deletedCounts, map[string]int{"my-org/app": 3, "my-org/worker": 2}) {
+		t.Errorf("All the images without a keep reason should be deleted, got %v", deletedCounts)
+	}
+
+	// the manifest of the old tag is kept by the stable tag, so only the old tag goes
+	if !reflect.DeepEqual(registry.Deleted(), []string{"/repositories/my-org/worker/tags/old/", "batch", "app@sha256:aaa", "app@sha256:bbb", "app@sha256:ccc", "worker@sha256:ddd"}) {
+		t.Errorf("The manifests of the namespace should be deleted in a single batch, got %v", registry.Deleted())
+	}
+}
+
+func TestBatchesOf(t *testing.T) {
+	defer func(previousSize int) { deleteImagesBatchSize = previousSize }(deleteImagesBatchSize)
+	deleteImagesBatchSize = 3
+
+	manifests := func(count int) manifestsOfImage {
+		return manifestsOfImage{manifests: make([]DeleteImagesManifestDTO, count)}
+	}
+
+	batches := batchesOf([]manifestsOfImage{manifests(2), manifests(1), manifests(2), manifests(4), manifests(1)})
+	batchSizes := []int{}
+
+	for _, batch := range batches {
+		batchSizes = append(batchSizes, len(batch))
+	}
+
+	if !reflect.DeepEqual(batchSizes, []int{2, 1, 1, 1}) {
+		t.Errorf("The manifests of an image should never be split, got batches of %v images", batchSizes)
+	}
+}
+
+func TestTagsOnlyByDefault(t *testing.T) {
+	client, _ := newTestClient(t, []string{"my-org"}, false)
+
+	if _, isBulkDeleter := client.(cr.RepositoriesBulkDeleter); isBulkDeleter {
+		t.Error("Manifests should only be deleted when asked to")
+	}
+
+	if capabilities := client.Capabilities(); capabilities.DeleteByDigest || !capabilities.DeleteTagsOnly {
+		t.Errorf("Wrong capabilities %+v", capabilities)
+	}
+}
+
+func TestDeleteRepository(t *testing.T) {
+	client, registry := newTestClient(t, []string{"my-org"}, false)
+
+	if err := client.Login("my-org", "dckr_oat_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	client.GetAllRepos()
+
+	if repo := client.ParseRepo("my-org/worker"); repo.TimeLastUpdatedMs != "1614834367123" {
+		t.Errorf("Wrong last update time %v", repo.TimeLastUpdatedMs)
+	}
+
+	repositoryDeleter, isRepositoryDeleter := client.(cr.RepositoryDeleter)
+
+	if !isRepositoryDeleter {
+		t.Fatal("Dockerhub should be able to delete repositories")
+	}
+
+	if err := repositoryDeleter.DeleteRepository("my-org/worker", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{"/repositories/my-org/worker/"}) {
+		t.Errorf("Wrong deletions %v", registry.Deleted())
+	}
+}
+
+func TestSignedMultiArchTag(t *testing.T) {
+	client, _ := newTestClient(t, []string{"my-org"}, false)
+
+	if err := client.Login("my-org", "dckr_oat_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	repository := client.ParseRepo("my-org/signed")
+
+	if !reflect.DeepEqual(repository.Images[0].Digest, []string{indexDigest, "sha256:amd64", "sha256:arm64"}) || !repository.Images[0].IsIndex() {
+		t.Errorf("The digest of the tag should go first, got %+v", repository.Images[0])
+	}
+
+	if !reflect.DeepEqual(repository.Images[1].Digest, []string{"sha256:signature"}) {
+		t.Errorf("The digest of a single-platform tag should not be repeated, got %v", repository.Images[1].Digest)
+	}
+
+	parsedRepos := imagefilters.Parse([]cr.Repository{repository}, configuration.KeepImages{Image: configuration.Image{Tags: []string{"v1"}}})
+
+	if signature := parsedRepos[0].Images[1]; signature.KeptData.Reason != keepreasons.ReferrerOfKept {
+		t.Errorf("The signature of a kept multi-arch tag should be kept, got %+v", signature.KeptData)
+	}
+}
+
+func TestMultiArchTagDeletion(t *testing.T) {
+	client, registry := newTestClient(t, []string{"my-org"}, true)
+
+	if err := client.Login("my-org", "dckr_oat_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	repository := client.ParseRepo("my-org/signed")
+
+	if len(repository.Images) != 4 || !reflect.DeepEqual(repository.Images[2].Parents, []string{indexDigest}) || len(repository.Images[3].Parents) != 0 {
+		t.Fatalf("The untagged platform manifest should be linked to its multi-arch tag, got %+v", repository.Images)
+	}
+
+	deletedCounts, err := client.(cr.RepositoriesBulkDeleter).DeleteRepositoriesImages([]cr.Repository{repository}, true)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the image index goes along with its platform manifests, so the untagged one is not requested twice
+	if !reflect.DeepEqual(registry.Deleted(), []string{"batch", "signed@" + indexDigest, "signed@sha256:amd64", "signed@sha256:arm64", "signed@sha256:signature", "signed@sha256:dangling"}) {
+		t.Errorf("Wrong deleted manifests %v", registry.Deleted())
+	}
+
+	if deletedCounts["my-org/signed"] != 4 {
+		t.Errorf("All the images should be counted as deleted, got %v", deletedCounts)
+	}
+}
+
+func TestKeptMultiArchTagManifests(t *testing.T) {
+	client, _ := newTestClient(t, []string{"my-org"}, true)
+
+	if err := client.Login("my-org", "dckr_oat_token"); err != nil {
+		t.Fatal(err)
+	}
+
+	parsedRepos := imagefilters.Parse([]cr.Repository{client.ParseRepo("my-org/signed")}, configuration.KeepImages{Image: configuration.Image{Tags: []string{"v1"}}})
+
+	if platform := parsedRepos[0].Images[2]; platform.KeptData.Reason != keepreasons.PartOfKeptIndex {
+		t.Errorf("The untagged platform manifest of a kept multi-arch tag should be kept, got %+v", platform.KeptData)
+	}
+
+	if dangling := parsedRepos[0].Images[3]; dangling.KeptData.Reason.IsKept() {
+		t.Errorf("An untagged manifest of no tag should be deleted, got %+v", dangling.KeptData)
+	}
+}
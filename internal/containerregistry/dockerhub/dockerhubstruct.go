@@ -6,7 +6,14 @@ import myhttp "github.com/hytromo/faulty-crane/internal/http"
 type RegistryClient struct {
 	httpClient myhttp.Client
 	token      string
-	namespace  string
+	namespaces []string
+	// lastUpdated keeps when each repository was last updated, as GetAllRepos finds it out before ParseRepo needs it
+	lastUpdated map[string]string
+}
+
+// ManifestRegistryClient is a dockerhub client that also lists the untagged manifests of each repository and deletes the manifests of the images, the image indexes of the tags included, through the bulk image deletion endpoint of each namespace
+type ManifestRegistryClient struct {
+	*RegistryClient
 }
 
 // UsersLoginDTO is the Data Transfer Object for the /users/login api call
@@ -14,6 +21,11 @@ type UsersLoginDTO struct {
 	Token string
 }
 
+// AuthTokenDTO is the Data Transfer Object for the /auth/token api call
+type AuthTokenDTO struct {
+	AccessToken string `json:"access_token"`
+}
+
 // RepositoryResultDTO is the DTO of the corresponding API call
 type RepositoryResultDTO struct {
 	User              string
@@ -73,6 +85,9 @@ type TagResultDTO struct {
 	TagStatus           string
 	TagLastPulled       string
 	TagLastPushed       string
+	// Digest is the digest of the manifest that the tag points to, which is the image index of multi-arch images
+	Digest    string
+	MediaType string
 }
 
 // TagsDTO is the Data Transfer Object for the /repositories/{namespace}/{repo}/tags api call
@@ -82,3 +97,59 @@ type TagsDTO struct {
 	Previous string
 	Results  []TagResultDTO
 }
+
+// ImageTagDTO is a tag of a manifest as returned by the images api call
+type ImageTagDTO struct {
+	Tag       string
+	IsCurrent bool `json:"is_current"`
+}
+
+// ImageDTO is a manifest of a repository
+type ImageDTO struct {
+	Namespace  string
+	Repository string
+	Digest     string
+	Tags       []ImageTagDTO
+	LastPushed string `json:"last_pushed"`
+	LastPulled string `json:"last_pulled"`
+	// Status is either active or inactive; manifests that have not been pushed or pulled for a month are inactive
+	Status string
+}
+
+// ImagesDTO is the Data Transfer Object for the /namespaces/{namespace}/repositories/{repo}/images api call
+type ImagesDTO struct {
+	Count   int
+	Next    string
+	Results []ImageDTO
+}
+
+// DeleteImagesManifestDTO identifies a manifest to be deleted
+type DeleteImagesManifestDTO struct {
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+}
+
+// DeleteImagesWarningDTO acknowledges a warning that would otherwise stop the deletion of a manifest
+type DeleteImagesWarningDTO struct {
+	Repository string `json:"repository"`
+	Digest     string `json:"digest"`
+	Warning    string `json:"warning"`
+}
+
+// DeleteImagesRequestDTO is the payload of the /namespaces/{namespace}/delete-images api call
+type DeleteImagesRequestDTO struct {
+	DryRun         bool                      `json:"dry_run"`
+	Manifests      []DeleteImagesManifestDTO `json:"manifests"`
+	IgnoreWarnings []DeleteImagesWarningDTO  `json:"ignore_warnings"`
+}
+
+// DeleteImagesDTO is the Data Transfer Object for the /namespaces/{namespace}/delete-images api call
+type DeleteImagesDTO struct {
+	DryRun  bool `json:"dry_run"`
+	Metrics struct {
+		ManifestDeletes int `json:"manifest_deletes"`
+		ManifestErrors  int `json:"manifest_errors"`
+		TagDeletes      int `json:"tag_deletes"`
+		TagErrors       int `json:"tag_errors"`
+	}
+}
@@ -0,0 +1,163 @@
+package directory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+	log "github.com/sirupsen/logrus"
+)
+
+// repositoryFile marks a directory as a repository and lists its images in the format of the plan file
+const repositoryFile = "repository.json"
+
+// RegistryClient is the reference driver: a registry whose repositories are directories with a repository.json file, e.g. root/team/app/repository.json for the repository team/app
+type RegistryClient struct {
+	root string
+	// mutex protects the repository files from concurrent deletions
+	mutex sync.Mutex
+}
+
+func (client *RegistryClient) repositoryFileOf(repositoryLink string) string {
+	return filepath.Join(client.root, filepath.FromSlash(repositoryLink), repositoryFile)
+}
+
+func (client *RegistryClient) readImages(repositoryLink string) ([]cr.ContainerImage, error) {
+	imagesBytes, err := os.ReadFile(client.repositoryFileOf(repositoryLink))
+
+	if err != nil {
+		return nil, err
+	}
+
+	images := []cr.ContainerImage{}
+	err = json.Unmarshal(imagesBytes, &images)
+
+	return images, err
+}
+
+// Login does nothing, as the files are protected by the file system permissions
+func (client *RegistryClient) Login(username string, password string) error {
+	return nil
+}
+
+// Capabilities returns what the client can do; the directory driver deletes images by digest, and the images carry the pull times and the links to their indexes that are stored with them
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest: true,
+		LastPulled:     true,
+		ManifestLists:  true,
+	}
+}
+
+// DeleteImage removes an image from the file of its repository; the images that are marked as protected in the file cannot be deleted
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	images, err := client.readImages(imageRepo)
+
+	if err != nil {
+		return err
+	}
+
+	for i, storedImage := range images {
+		if len(storedImage.Digest) == 0 || len(image.Digest) == 0 || storedImage.Digest[0] != image.Digest[0] {
+			continue
+		}
+
+		if storedImage.KeptData.Reason == keepreasons.Protected {
+			return cr.ErrImageProtected
+		}
+
+		imagesBytes, err := json.MarshalIndent(append(images[:i], images[i+1:]...), "", "  ")
+
+		if err != nil {
+			return err
+		}
+
+		return os.WriteFile(client.repositoryFileOf(imageRepo), imagesBytes, 0644)
+	}
+
+	return fmt.Errorf("image %v not found in %v", image.Digest, imageRepo)
+}
+
+// GetAllRepos finds all the repository files under the root
+func (client *RegistryClient) GetAllRepos() []string {
+	repositories := []string{}
+
+	err := filepath.WalkDir(client.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDir() || entry.Name() != repositoryFile {
+			return nil
+		}
+
+		repositoryLink, err := filepath.Rel(client.root, filepath.Dir(path))
+
+		if err != nil {
+			return err
+		}
+
+		repositories = append(repositories, filepath.ToSlash(repositoryLink))
+
+		return nil
+	})
+
+	if err != nil {
+		log.Fatalf("Could not read the repositories of %v: %v", client.root, err)
+	}
+
+	return repositories
+}
+
+// ParseRepo reads the images of a repository file
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	images, err := client.readImages(repositoryLink)
+
+	if err != nil {
+		log.Fatalf("Could not read the repository %v: %v", repositoryLink, err)
+	}
+
+	for i := range images {
+		if images[i].Repo == "" {
+			images[i].Repo = repositoryLink
+		}
+	}
+
+	cr.LinkIndexChildren(images)
+
+	return cr.Repository{
+		Link:   repositoryLink,
+		Images: images,
+	}
+}
+
+// NewDirectoryClientParams are the required parameters to build a directory client
+type NewDirectoryClientParams struct {
+	// Root is the directory that contains the repositories
+	Root string
+}
+
+// NewDirectoryClient builds a new directory client
+func NewDirectoryClient(params NewDirectoryClientParams) cr.Client {
+	return &RegistryClient{
+		root: params.Root,
+	}
+}
+
+// FromOptions builds a directory client out of the driver options of the configuration file; the root option is required
+func FromOptions(options map[string]string) (cr.Client, error) {
+	if options["root"] == "" {
+		return nil, errors.New("please specify the root option of the directory driver")
+	}
+
+	return NewDirectoryClient(NewDirectoryClientParams{Root: options["root"]}), nil
+}
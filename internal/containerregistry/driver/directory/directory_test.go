@@ -0,0 +1,107 @@
+package directory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/driver"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/driver/drivertest"
+)
+
+// serveEnv makes the test binary act as the directory driver, so that the driver runs as a real child process
+const serveEnv = "FAULTY_CRANE_TEST_SERVE_DIRECTORY_DRIVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(serveEnv) != "" {
+		if err := driver.Serve("directory", FromOptions, os.Stdin, os.Stdout); err != nil {
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	}
+
+	os.Exit(m.Run())
+}
+
+// newFakeRoot creates a registry directory with an app repository and a nested team/worker repository
+func newFakeRoot(t *testing.T) string {
+	root := t.TempDir()
+
+	repositories := map[string]string{
+		"app": `[
+			{"ImageSizeBytes": "100", "Tag": ["v1"], "TimeUploadedMs": "1000", "Digest": ["sha256:a"]},
+			{"ImageSizeBytes": "100", "Tag": ["v0"], "TimeUploadedMs": "500", "Digest": ["sha256:b"], "KeptData": {"Reason": 7}}
+		]`,
+		"team/worker": `[{"ImageSizeBytes": "10", "Tag": [], "TimeUploadedMs": "1000", "Digest": ["sha256:c"]}]`,
+	}
+
+	for repository, images := range repositories {
+		dir := filepath.Join(root, filepath.FromSlash(repository))
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, repositoryFile), []byte(images), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return root
+}
+
+func driverParamsOf(root string) driver.NewDriverClientParams {
+	return driver.NewDriverClientParams{
+		Path:    os.Args[0],
+		Env:     []string{serveEnv + "=1"},
+		Options: map[string]string{"root": root},
+	}
+}
+
+func TestConformance(t *testing.T) {
+	drivertest.TestDriver(t, drivertest.Params{
+		NewDriverClientParams: driverParamsOf(newFakeRoot(t)),
+		AllowDeletion:         true,
+	})
+}
+
+func TestDriverClient(t *testing.T) {
+	client, err := driver.Start(driverParamsOf(newFakeRoot(t)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer client.Close()
+
+	if client.Name() != "directory" {
+		t.Errorf("Wrong driver name %v", client.Name())
+	}
+
+	if client.Capabilities() != (cr.Capabilities{DeleteByDigest: true, LastPulled: true, ManifestLists: true}) {
+		t.Errorf("Wrong driver capabilities %+v", client.Capabilities())
+	}
+
+	repositories := client.GetAllRepos()
+
+	if len(repositories) != 2 || repositories[0] != "app" || repositories[1] != "team/worker" {
+		t.Errorf("Wrong repositories %v", repositories)
+	}
+
+	repo := client.ParseRepo("app")
+
+	if len(repo.Images) != 2 || repo.Images[0].Repo != "app" {
+		t.Fatalf("Wrong repository %+v", repo)
+	}
+
+	if err = client.DeleteImage("app", repo.Images[1], true); !errors.Is(err, cr.ErrImageProtected) {
+		t.Errorf("Protected images should be reported as such, got %v", err)
+	}
+
+	if _, err = driver.Start(driver.NewDriverClientParams{Path: os.Args[0], Env: []string{serveEnv + "=1"}}); err == nil {
+		t.Error("The driver should refuse to start without a root")
+	}
+}
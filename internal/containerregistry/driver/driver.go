@@ -0,0 +1,267 @@
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	log "github.com/sirupsen/logrus"
+)
+
+// RegistryClient is a client for a registry that is implemented by an external driver executable, which it talks to over the stdin and the stdout of the driver
+type RegistryClient struct {
+	// mutex serializes the requests, as the driver answers them one by one
+	mutex  sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	nextID int64
+	// name is the name that the driver reported for itself
+	name string
+	// capabilities are what the driver reported that it can do
+	capabilities cr.Capabilities
+}
+
+// errorOf turns the error of a driver response into a go error
+func errorOf(errorDTO *ErrorDTO) error {
+	if errorDTO.Code == ErrorCodeProtected {
+		return fmt.Errorf("%w: %v", cr.ErrImageProtected, errorDTO.Message)
+	}
+
+	return errors.New(errorDTO.Message)
+}
+
+// Call sends a request to the driver and decodes its result into result, unless result is nil
+func (client *RegistryClient) Call(method string, params interface{}, result interface{}) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.nextID++
+
+	request := RequestDTO{
+		ID:     client.nextID,
+		Method: method,
+	}
+
+	if params != nil {
+		paramsBytes, err := json.Marshal(params)
+
+		if err != nil {
+			return err
+		}
+
+		request.Params = paramsBytes
+	}
+
+	requestBytes, err := json.Marshal(request)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = client.stdin.Write(append(requestBytes, '\n'))
+
+	if err != nil {
+		return fmt.Errorf("could not send %v to the driver: %v", method, err)
+	}
+
+	responseBytes, err := client.stdout.ReadBytes('\n')
+
+	if err != nil {
+		return fmt.Errorf("the driver did not answer %v: %v", method, err)
+	}
+
+	response := ResponseDTO{}
+	err = json.Unmarshal(responseBytes, &response)
+
+	if err != nil {
+		return fmt.Errorf("invalid driver response (%v): %v", string(responseBytes), err)
+	}
+
+	if response.ID != request.ID {
+		return fmt.Errorf("the driver answered request %v instead of %v", response.ID, request.ID)
+	}
+
+	if response.Error != nil {
+		return errorOf(response.Error)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	err = json.Unmarshal(response.Result, result)
+
+	if err != nil {
+		return fmt.Errorf("invalid driver result (%v): %v", string(response.Result), err)
+	}
+
+	return nil
+}
+
+// Name returns the name that the driver reported for itself
+func (client *RegistryClient) Name() string {
+	return client.name
+}
+
+// Close stops the driver; closing its stdin is the signal for the driver to exit
+func (client *RegistryClient) Close() error {
+	client.stdin.Close()
+
+	return client.cmd.Wait()
+}
+
+// Capabilities returns what the driver reported that it can do; the driver protocol has no bulk or repository deletion, so these are never reported
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	capabilities := client.capabilities
+	capabilities.BulkDelete = false
+	capabilities.DeleteRepository = false
+
+	return capabilities
+}
+
+// Login passes the credentials to the driver
+func (client *RegistryClient) Login(username string, password string) error {
+	return client.Call(MethodLogin, LoginParamsDTO{Username: username, Password: password}, nil)
+}
+
+// DeleteImage asks the driver to delete an image
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	err := client.Call(MethodDeleteImage, DeleteImageParamsDTO{
+		Repository:   imageRepo,
+		Image:        image,
+		SilentErrors: silentErrors,
+	}, nil)
+
+	if err != nil && !silentErrors && !errors.Is(err, cr.ErrImageProtected) {
+		log.Errorf("Could not delete image %v of %v: %v", image.Digest, imageRepo, err)
+	}
+
+	return err
+}
+
+// GetAllRepos asks the driver for all the repositories of the registry
+func (client *RegistryClient) GetAllRepos() []string {
+	result := GetAllReposResultDTO{}
+	err := client.Call(MethodGetAllRepos, nil, &result)
+
+	if err != nil {
+		log.Fatalf("Error on driver call: %v", err.Error())
+	}
+
+	return result.Repositories
+}
+
+// ParseRepo asks the driver for the images of a repository
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{}
+	err := client.Call(MethodParseRepo, ParseRepoParamsDTO{Repository: repositoryLink}, &repository)
+
+	if err != nil {
+		log.Fatalf("Error on driver call: %v", err.Error())
+	}
+
+	// the link is what faulty-crane knows the repository by
+	repository.Link = repositoryLink
+
+	if repository.Images == nil {
+		repository.Images = []cr.ContainerImage{}
+	}
+
+	return repository
+}
+
+// NewDriverClientParams are the required parameters to start a driver
+type NewDriverClientParams struct {
+	// Name finds the driver executable in the PATH, e.g. artifactstore runs faulty-crane-driver-artifactstore
+	Name string
+	// Path is the path of the driver executable, instead of finding it by name
+	Path string
+	// Args are extra arguments of the driver executable
+	Args []string
+	// Env are extra environment variables of the driver executable, in the form KEY=value
+	Env []string
+	// Options are passed to the driver in the Init request
+	Options map[string]string
+}
+
+// Start starts a driver and initialises it
+func Start(params NewDriverClientParams) (*RegistryClient, error) {
+	path := params.Path
+
+	if path == "" {
+		var err error
+		path, err = exec.LookPath(ExecutablePrefix + params.Name)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := exec.Command(path, params.Args...)
+	cmd.Env = append(os.Environ(), params.Env...)
+	// the stderr of the driver is its log
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	stdout, err := cmd.StdoutPipe()
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = cmd.Start()
+
+	if err != nil {
+		return nil, err
+	}
+
+	client := &RegistryClient{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+	}
+
+	result := InitResultDTO{}
+	err = client.Call(MethodInit, InitParamsDTO{ProtocolVersion: ProtocolVersion, Options: params.Options}, &result)
+
+	if err == nil && result.ProtocolVersion != ProtocolVersion {
+		err = fmt.Errorf("the driver speaks protocol version %v instead of %v", result.ProtocolVersion, ProtocolVersion)
+	}
+
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	client.name = result.Name
+	client.capabilities = cr.Capabilities{DeleteByDigest: true}
+
+	if result.Capabilities != nil {
+		client.capabilities = *result.Capabilities
+	}
+
+	return client, nil
+}
+
+// NewDriverClient starts a driver and builds a client for it
+func NewDriverClient(params NewDriverClientParams) cr.Client {
+	client, err := Start(params)
+
+	if err != nil {
+		log.Fatalf("Could not start the registry driver: %v", err)
+	}
+
+	return client
+}
@@ -0,0 +1,128 @@
+package drivertest
+
+import (
+	"strconv"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/driver"
+)
+
+// Params describe the driver under test
+type Params struct {
+	driver.NewDriverClientParams
+	Username string
+	Password string
+	// MaxRepos limits the repositories that are parsed, for drivers of big registries; 0 parses them all
+	MaxRepos int
+	// AllowDeletion deletes an image that is not kept, so the driver should point to a registry that can lose it
+	AllowDeletion bool
+}
+
+// startDriver starts and initialises the driver, stopping it at the end of the test
+func startDriver(t *testing.T, params Params) *driver.RegistryClient {
+	client, err := driver.Start(params.NewDriverClientParams)
+
+	if err != nil {
+		t.Fatalf("The driver should start and answer Init: %v", err)
+	}
+
+	t.Cleanup(func() { client.Close() })
+
+	if err = client.Login(params.Username, params.Password); err != nil {
+		t.Fatalf("Login should not fail: %v", err)
+	}
+
+	return client
+}
+
+// checkImage checks that an image carries the fields that the filters of faulty-crane need
+func checkImage(t *testing.T, repo cr.Repository, image cr.ContainerImage) {
+	if len(image.Digest) == 0 || image.Digest[0] == "" {
+		t.Errorf("Image %+v of %v should have a digest", image, repo.Link)
+	}
+
+	if _, err := strconv.ParseInt(image.TimeUploadedMs, 10, 64); err != nil {
+		t.Errorf("Image %v of %v should have an upload time in milliseconds, not %q", image.Digest, repo.Link, image.TimeUploadedMs)
+	}
+
+	if _, err := strconv.ParseInt(image.ImageSizeBytes, 10, 64); image.ImageSizeBytes != "" && err != nil {
+		t.Errorf("Image %v of %v should have a size in bytes, not %q", image.Digest, repo.Link, image.ImageSizeBytes)
+	}
+}
+
+// TestDriver runs the conformance suite of the driver protocol against a driver; any driver can be tested by calling it from a go test
+func TestDriver(t *testing.T, params Params) {
+	client := startDriver(t, params)
+
+	t.Run("UnknownMethod", func(t *testing.T) {
+		if err := client.Call("NoSuchMethod", nil, nil); err == nil {
+			t.Error("Unknown methods should be answered with an error")
+		}
+
+		// the driver should keep answering after an error
+		if err := client.Login(params.Username, params.Password); err != nil {
+			t.Errorf("The driver should keep working after an error: %v", err)
+		}
+	})
+
+	repositories := client.GetAllRepos()
+
+	t.Run("GetAllRepos", func(t *testing.T) {
+		seen := map[string]bool{}
+
+		for _, repository := range repositories {
+			if repository == "" || seen[repository] {
+				t.Errorf("Repositories should be unique and non-empty, got %q twice or empty", repository)
+			}
+
+			seen[repository] = true
+		}
+	})
+
+	if params.MaxRepos > 0 && len(repositories) > params.MaxRepos {
+		repositories = repositories[:params.MaxRepos]
+	}
+
+	parsedRepos := []cr.Repository{}
+
+	t.Run("ParseRepo", func(t *testing.T) {
+		for _, repository := range repositories {
+			repo := client.ParseRepo(repository)
+
+			for _, image := range repo.Images {
+				checkImage(t, repo, image)
+			}
+
+			parsedRepos = append(parsedRepos, repo)
+		}
+	})
+
+	if !params.AllowDeletion {
+		return
+	}
+
+	t.Run("DeleteImage", func(t *testing.T) {
+		for _, repo := range parsedRepos {
+			for _, image := range repo.Images {
+				if image.KeptData.Reason.IsKept() {
+					continue
+				}
+
+				if err := client.DeleteImage(repo.Link, image, true); err != nil {
+					t.Fatalf("Could not delete image %v of %v: %v", image.Digest, repo.Link, err)
+				}
+
+				for _, remainingImage := range client.ParseRepo(repo.Link).Images {
+					if remainingImage.Digest[0] == image.Digest[0] {
+						t.Errorf("Image %v of %v should be deleted", image.Digest, repo.Link)
+					}
+				}
+
+				return
+			}
+		}
+
+		t.Error("There should be an image to delete")
+	})
+}
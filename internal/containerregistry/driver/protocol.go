@@ -0,0 +1,85 @@
+package driver
+
+import (
+	"encoding/json"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+)
+
+// ProtocolVersion is the version of the driver protocol; drivers answer the Init request with the version that they speak
+const ProtocolVersion = 1
+
+// ExecutablePrefix is the prefix of the executables of the drivers, e.g. faulty-crane-driver-artifactstore for the driver named artifactstore
+const ExecutablePrefix = "faulty-crane-driver-"
+
+// The methods of the protocol; Init is always the first request, the rest mirror containerregistry.Client
+const (
+	MethodInit        = "Init"
+	MethodLogin       = "Login"
+	MethodGetAllRepos = "GetAllRepos"
+	MethodParseRepo   = "ParseRepo"
+	MethodDeleteImage = "DeleteImage"
+)
+
+// ErrorCodeProtected is the error code of a DeleteImage response when the registry refuses to delete the image on purpose
+const ErrorCodeProtected = "protected"
+
+// RequestDTO is a single line of json that faulty-crane writes to the stdin of the driver
+type RequestDTO struct {
+	// ID is echoed back in the response
+	ID     int64           `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// ResponseDTO is a single line of json that the driver writes to its stdout for each request; exactly one of Result and Error is set
+type ResponseDTO struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *ErrorDTO       `json:"error,omitempty"`
+}
+
+// ErrorDTO is the error of a failed request
+type ErrorDTO struct {
+	// Code is optional and machine readable, e.g. ErrorCodeProtected
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// InitParamsDTO are the parameters of the Init request
+type InitParamsDTO struct {
+	ProtocolVersion int `json:"protocolVersion"`
+	// Options are the driver options of the configuration file, e.g. the url of the registry
+	Options map[string]string `json:"options,omitempty"`
+}
+
+// InitResultDTO is the result of the Init request
+type InitResultDTO struct {
+	ProtocolVersion int    `json:"protocolVersion"`
+	Name            string `json:"name,omitempty"`
+	// Capabilities are what the driver can do; drivers that leave them out are assumed to delete images by digest and nothing more
+	Capabilities *cr.Capabilities `json:"capabilities,omitempty"`
+}
+
+// LoginParamsDTO are the parameters of the Login request
+type LoginParamsDTO struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// GetAllReposResultDTO is the result of the GetAllRepos request
+type GetAllReposResultDTO struct {
+	Repositories []string `json:"repositories"`
+}
+
+// ParseRepoParamsDTO are the parameters of the ParseRepo request; the result is a repository in the format of the plan file
+type ParseRepoParamsDTO struct {
+	Repository string `json:"repository"`
+}
+
+// DeleteImageParamsDTO are the parameters of the DeleteImage request; the result is an empty object
+type DeleteImageParamsDTO struct {
+	Repository   string            `json:"repository"`
+	Image        cr.ContainerImage `json:"image"`
+	SilentErrors bool              `json:"silentErrors"`
+}
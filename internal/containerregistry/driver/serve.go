@@ -0,0 +1,134 @@
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+)
+
+// NewClientFunc builds the client that a driver serves, out of the driver options of the configuration file
+type NewClientFunc func(options map[string]string) (cr.Client, error)
+
+// server answers the requests of faulty-crane with a containerregistry.Client
+type server struct {
+	name      string
+	newClient NewClientFunc
+	client    cr.Client
+}
+
+// errorDTOOf turns a go error into the error of a driver response
+func errorDTOOf(err error) *ErrorDTO {
+	if errors.Is(err, cr.ErrImageProtected) {
+		return &ErrorDTO{Code: ErrorCodeProtected, Message: err.Error()}
+	}
+
+	return &ErrorDTO{Message: err.Error()}
+}
+
+// handle answers a single request and returns its result
+func (server *server) handle(request RequestDTO) (interface{}, error) {
+	if request.Method != MethodInit && server.client == nil {
+		return nil, errors.New("the driver is not initialised")
+	}
+
+	switch request.Method {
+	case MethodInit:
+		params := InitParamsDTO{}
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		if params.ProtocolVersion != ProtocolVersion {
+			return nil, fmt.Errorf("unsupported protocol version %v", params.ProtocolVersion)
+		}
+
+		client, err := server.newClient(params.Options)
+		if err != nil {
+			return nil, err
+		}
+
+		server.client = client
+		capabilities := client.Capabilities()
+
+		return InitResultDTO{ProtocolVersion: ProtocolVersion, Name: server.name, Capabilities: &capabilities}, nil
+	case MethodLogin:
+		params := LoginParamsDTO{}
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		return struct{}{}, server.client.Login(params.Username, params.Password)
+	case MethodGetAllRepos:
+		repositories := server.client.GetAllRepos()
+		if repositories == nil {
+			repositories = []string{}
+		}
+
+		return GetAllReposResultDTO{Repositories: repositories}, nil
+	case MethodParseRepo:
+		params := ParseRepoParamsDTO{}
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		return server.client.ParseRepo(params.Repository), nil
+	case MethodDeleteImage:
+		params := DeleteImageParamsDTO{}
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, err
+		}
+
+		return struct{}{}, server.client.DeleteImage(params.Repository, params.Image, params.SilentErrors)
+	}
+
+	return nil, fmt.Errorf("unknown method %v", request.Method)
+}
+
+// Serve implements the driver side of the protocol on top of a containerregistry.Client, so that drivers can be written in go; it returns when faulty-crane closes the input
+func Serve(name string, newClient NewClientFunc, in io.Reader, out io.Writer) error {
+	server := &server{name: name, newClient: newClient}
+	reader := bufio.NewReader(in)
+
+	for {
+		requestBytes, err := reader.ReadBytes('\n')
+
+		if err == io.EOF && len(requestBytes) == 0 {
+			return nil
+		}
+
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		request := RequestDTO{}
+		response := ResponseDTO{}
+
+		if err := json.Unmarshal(requestBytes, &request); err != nil {
+			response.Error = &ErrorDTO{Message: fmt.Sprintf("invalid request: %v", err)}
+		} else {
+			response.ID = request.ID
+
+			result, err := server.handle(request)
+
+			if err != nil {
+				response.Error = errorDTOOf(err)
+			} else if response.Result, err = json.Marshal(result); err != nil {
+				response.Error = errorDTOOf(err)
+			}
+		}
+
+		responseBytes, err := json.Marshal(response)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err = out.Write(append(responseBytes, '\n')); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package ecr
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Credentials are the aws credentials that requests are signed with; temporary credentials also have a session token and an expiration
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Expiration is the zero time for credentials that do not expire
+	Expiration time.Time
+	// Source describes where the credentials were found, for logging purposes
+	Source string
+}
+
+// credentialsProvider finds credentials from the standard aws sources and renews them before they expire
+type credentialsProvider struct {
+	mutex   sync.Mutex
+	profile string
+	region  string
+	current Credentials
+	// metadataClient is used for the credential endpoints of ecs and ec2, which should answer almost immediately when they exist
+	metadataClient *http.Client
+}
+
+// expiryWindow is how long before their expiration temporary credentials are renewed
+var expiryWindow = 5 * time.Minute
+
+var defaultContainerCredentialsHost = "http://169.254.170.2"
+var defaultInstanceMetadataEndpoint = "http://169.254.169.254"
+
+// get returns valid credentials, renewing them if they are about to expire
+func (provider *credentialsProvider) get() (Credentials, error) {
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+
+	if provider.current.AccessKeyID != "" && (provider.current.Expiration.IsZero() || time.Until(provider.current.Expiration) > expiryWindow) {
+		return provider.current, nil
+	}
+
+	credentials, err := provider.resolve()
+
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	log.Debugf("Using aws credentials from %v", credentials.Source)
+	provider.current = credentials
+
+	return credentials, nil
+}
+
+// resolve goes through the credential sources in the same order as the aws sdks: environment, web identity, shared credentials file, ecs container and ec2 instance metadata
+func (provider *credentialsProvider) resolve() (Credentials, error) {
+	if accessKeyID := os.Getenv("AWS_ACCESS_KEY_ID"); accessKeyID != "" && provider.profile == "" {
+		return Credentials{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Source:          "environment",
+		}, nil
+	}
+
+	if tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"); tokenFile != "" && os.Getenv("AWS_ROLE_ARN") != "" && provider.profile == "" {
+		return provider.fromWebIdentity(tokenFile, os.Getenv("AWS_ROLE_ARN"))
+	}
+
+	credentials, found, err := provider.fromSharedCredentialsFile()
+
+	if err != nil || found {
+		return credentials, err
+	}
+
+	if relativeURI := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relativeURI != "" {
+		return provider.fromCredentialsEndpoint(defaultContainerCredentialsHost+relativeURI, http.Header{}, "ecs container")
+	}
+
+	if fullURI := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI"); fullURI != "" {
+		headers := http.Header{}
+		if token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN"); token != "" {
+			headers.Set("Authorization", token)
+		}
+
+		return provider.fromCredentialsEndpoint(fullURI, headers, "container credentials endpoint")
+	}
+
+	if !strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true") {
+		return provider.fromInstanceMetadata()
+	}
+
+	return Credentials{}, errors.New("no aws credentials found")
+}
+
+// fromSharedCredentialsFile reads the static credentials of the profile from the shared credentials file, e.g. ~/.aws/credentials
+func (provider *credentialsProvider) fromSharedCredentialsFile() (Credentials, bool, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Credentials{}, false, nil
+		}
+
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+
+	profile := provider.profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		if provider.profile != "" {
+			return Credentials{}, false, fmt.Errorf("profile %v was requested but the shared credentials file %v cannot be read: %v", profile, path, err)
+		}
+
+		return Credentials{}, false, nil
+	}
+
+	defer file.Close()
+
+	values := map[string]string{}
+	inProfile := false
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+			continue
+		}
+
+		if key, value, isKeyValue := strings.Cut(line, "="); isKeyValue && inProfile {
+			values[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+		}
+	}
+
+	if values["aws_access_key_id"] == "" {
+		if provider.profile != "" {
+			return Credentials{}, false, fmt.Errorf("profile %v has no static credentials in %v", profile, path)
+		}
+
+		return Credentials{}, false, nil
+	}
+
+	return Credentials{
+		AccessKeyID:     values["aws_access_key_id"],
+		SecretAccessKey: values["aws_secret_access_key"],
+		SessionToken:    values["aws_session_token"],
+		Source:          fmt.Sprintf("profile %v of %v", profile, path),
+	}, true, nil
+}
+
+// temporaryCredentialsDTO is the response of the ecs and ec2 credential endpoints
+type temporaryCredentialsDTO struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string
+	Token           string
+	Expiration      time.Time
+}
+
+func (provider *credentialsProvider) fromCredentialsEndpoint(endpoint string, headers http.Header, source string) (Credentials, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	req.Header = headers
+
+	bodyBytes, err := provider.doMetadataRequest(req)
+
+	if err != nil {
+		return Credentials{}, fmt.Errorf("could not get credentials from the %v: %v", source, err)
+	}
+
+	credentialsResp := temporaryCredentialsDTO{}
+	err = json.Unmarshal(bodyBytes, &credentialsResp)
+
+	if err != nil {
+		return Credentials{}, fmt.Errorf("invalid %v credentials response: %v", source, err)
+	}
+
+	return Credentials{
+		AccessKeyID:     credentialsResp.AccessKeyID,
+		SecretAccessKey: credentialsResp.SecretAccessKey,
+		SessionToken:    credentialsResp.Token,
+		Expiration:      credentialsResp.Expiration,
+		Source:          source,
+	}, nil
+}
+
+// fromInstanceMetadata gets the credentials of the instance role through imdsv2
+func (provider *credentialsProvider) fromInstanceMetadata() (Credentials, error) {
+	endpoint := os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT")
+	if endpoint == "" {
+		endpoint = defaultInstanceMetadataEndpoint
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+
+	req, _ := http.NewRequest("PUT", endpoint+"/latest/api/token", nil)
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+
+	token, err := provider.doMetadataRequest(req)
+
+	if err != nil {
+		return Credentials{}, fmt.Errorf("no aws credentials found, the last attempt was the ec2 instance metadata: %v", err)
+	}
+
+	headers := http.Header{}
+	headers.Set("X-aws-ec2-metadata-token", string(token))
+
+	req, _ = http.NewRequest("GET", endpoint+"/latest/meta-data/iam/security-credentials/", nil)
+	req.Header = headers.Clone()
+
+	roles, err := provider.doMetadataRequest(req)
+
+	if err != nil {
+		return Credentials{}, fmt.Errorf("could not find the role of the ec2 instance: %v", err)
+	}
+
+	role := strings.TrimSpace(strings.SplitN(string(roles), "\n", 2)[0])
+
+	return provider.fromCredentialsEndpoint(endpoint+"/latest/meta-data/iam/security-credentials/"+role, headers, "ec2 instance metadata")
+}
+
+func (provider *credentialsProvider) doMetadataRequest(req *http.Request) ([]byte, error) {
+	resp, err := provider.metadataClient.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%v: %v", resp.Status, string(bodyBytes))
+	}
+
+	return bodyBytes, nil
+}
+
+// assumeRoleWithWebIdentityDTO is the xml response of the sts AssumeRoleWithWebIdentity action
+type assumeRoleWithWebIdentityDTO struct {
+	Credentials struct {
+		AccessKeyID     string `xml:"AccessKeyId"`
+		SecretAccessKey string
+		SessionToken    string
+		Expiration      time.Time
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+// fromWebIdentity exchanges a web identity token (e.g. the service account token of an eks pod) for the credentials of a role
+func (provider *credentialsProvider) fromWebIdentity(tokenFile string, roleARN string) (Credentials, error) {
+	token, err := ioutil.ReadFile(tokenFile)
+
+	if err != nil {
+		return Credentials{}, fmt.Errorf("could not read the web identity token: %v", err)
+	}
+
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("faulty-crane-%d", time.Now().Unix())
+	}
+
+	query := url.Values{}
+	query.Set("Action", "AssumeRoleWithWebIdentity")
+	query.Set("Version", "2011-06-15")
+	query.Set("RoleArn", roleARN)
+	query.Set("RoleSessionName", sessionName)
+	query.Set("WebIdentityToken", strings.TrimSpace(string(token)))
+
+	endpoint := os.Getenv("AWS_STS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://sts." + provider.region + "." + dnsSuffixOf(provider.region)
+	}
+
+	// the web identity token is the proof of identity, so this request is not signed
+	resp, err := http.PostForm(strings.TrimSuffix(endpoint, "/")+"/", query)
+
+	if err != nil {
+		return Credentials{}, fmt.Errorf("could not assume role %v: %v", roleARN, err)
+	}
+
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return Credentials{}, fmt.Errorf("could not assume role %v: %v %v", roleARN, resp.Status, string(bodyBytes))
+	}
+
+	assumeRoleResp := assumeRoleWithWebIdentityDTO{}
+	err = xml.Unmarshal(bodyBytes, &assumeRoleResp)
+
+	if err != nil {
+		return Credentials{}, fmt.Errorf("invalid AssumeRoleWithWebIdentity response: %v", err)
+	}
+
+	return Credentials{
+		AccessKeyID:     assumeRoleResp.Credentials.AccessKeyID,
+		SecretAccessKey: assumeRoleResp.Credentials.SecretAccessKey,
+		SessionToken:    assumeRoleResp.Credentials.SessionToken,
+		Expiration:      assumeRoleResp.Credentials.Expiration,
+		Source:          "web identity of role " + roleARN,
+	}, nil
+}
+
+func newCredentialsProvider(profile string, region string) *credentialsProvider {
+	return &credentialsProvider{
+		profile:        profile,
+		region:         region,
+		metadataClient: &http.Client{Timeout: 2 * time.Second},
+	}
+}
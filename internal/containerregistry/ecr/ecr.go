@@ -0,0 +1,276 @@
+package ecr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	log "github.com/sirupsen/logrus"
+)
+
+// batchDeleteSize is the maximum number of images that BatchDeleteImage accepts per call
+var batchDeleteSize = 100
+
+var targetPrefix = "AmazonEC2ContainerRegistry_V20150921."
+
+// dnsSuffixOf returns the domain of the aws partition of a region
+func dnsSuffixOf(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "amazonaws.com.cn"
+	}
+
+	return "amazonaws.com"
+}
+
+// Login finds the aws credentials from the standard sources; the username and the password are not used, ecr requests are signed with the aws credentials
+func (client *RegistryClient) Login(username string, password string) error {
+	_, err := client.credentials.get()
+
+	if err != nil {
+		return err
+	}
+
+	client.httpClient.InjectAuthInRequest = func(req *http.Request) {
+		credentials, err := client.credentials.get()
+
+		if err != nil {
+			log.Errorf("Could not renew the aws credentials: %v", err)
+		}
+
+		payload := []byte{}
+
+		if req.GetBody != nil {
+			body, _ := req.GetBody()
+			payload, _ = ioutil.ReadAll(body)
+		}
+
+		signRequest(req, payload, credentials, client.region, "ecr", time.Now())
+	}
+
+	return nil
+}
+
+// call executes an ecr api action and unmarshals its response
+func (client *RegistryClient) call(action string, request interface{}, response interface{}) {
+	jsonPayload, _ := json.Marshal(request)
+
+	bodyBytes, err := client.httpClient.PostRequestWithHeadersTo("/", jsonPayload, http.Header{
+		"X-Amz-Target": {targetPrefix + action},
+		"Content-Type": {"application/x-amz-json-1.1"},
+	}, false, false)
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	err = json.Unmarshal(bodyBytes, response)
+
+	if err != nil {
+		log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+	}
+}
+
+// Capabilities returns what the client can do; ECR deletes images by digest in batches and records when they were last pulled
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest: true,
+		BulkDelete:     true,
+		LastPulled:     true,
+	}
+}
+
+// DeleteImage deletes a single image by its digest, along with all its tags
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	deletedCount, _, err := client.DeleteImages(imageRepo, []cr.ContainerImage{image}, silentErrors)
+
+	if err != nil {
+		return err
+	}
+
+	if deletedCount == 0 {
+		return fmt.Errorf("image %v@%v was not deleted", imageRepo, image.Digest)
+	}
+
+	return nil
+}
+
+// DeleteImages deletes the given images by their digests with BatchDeleteImage, in chunks of 100
+func (client *RegistryClient) DeleteImages(imageRepo string, images []cr.ContainerImage, silentErrors bool) (int, int, error) {
+	deletedDigests := map[string]bool{}
+
+	for chunkStart := 0; chunkStart < len(images); chunkStart += batchDeleteSize {
+		chunk := images[chunkStart:int(math.Min(float64(chunkStart+batchDeleteSize), float64(len(images))))]
+
+		request := BatchDeleteImageRequestDTO{
+			RegistryID:     client.registryID,
+			RepositoryName: imageRepo,
+			ImageIds:       []ImageIdentifierDTO{},
+		}
+
+		for _, image := range chunk {
+			for _, digest := range image.Digest {
+				request.ImageIds = append(request.ImageIds, ImageIdentifierDTO{ImageDigest: digest})
+			}
+		}
+
+		jsonPayload, _ := json.Marshal(request)
+
+		bodyBytes, err := client.httpClient.PostRequestWithHeadersTo("/", jsonPayload, http.Header{
+			"X-Amz-Target": {targetPrefix + "BatchDeleteImage"},
+			"Content-Type": {"application/x-amz-json-1.1"},
+		}, true, silentErrors)
+
+		if err != nil {
+			return deletedCountOf(images, deletedDigests), 0, err
+		}
+
+		deleteResp := BatchDeleteImageDTO{}
+		err = json.Unmarshal(bodyBytes, &deleteResp)
+
+		if err != nil {
+			return deletedCountOf(images, deletedDigests), 0, err
+		}
+
+		for _, imageID := range deleteResp.ImageIds {
+			deletedDigests[imageID.ImageDigest] = true
+		}
+
+		if !silentErrors {
+			for _, failure := range deleteResp.Failures {
+				log.Errorf("Could not delete %v@%v: %v %v", imageRepo, failure.ImageID.ImageDigest, failure.FailureCode, failure.FailureReason)
+			}
+		}
+	}
+
+	return deletedCountOf(images, deletedDigests), 0, nil
+}
+
+// deletedCountOf returns how many of the images are among the deleted digests
+func deletedCountOf(images []cr.ContainerImage, deletedDigests map[string]bool) int {
+	deletedCount := 0
+
+	for _, image := range images {
+		if len(image.Digest) > 0 && deletedDigests[image.Digest[0]] {
+			deletedCount++
+		}
+	}
+
+	return deletedCount
+}
+
+// GetAllRepos returns all the repositories of the registry
+func (client *RegistryClient) GetAllRepos() []string {
+	repositories := []string{}
+	request := DescribeRepositoriesRequestDTO{
+		RegistryID: client.registryID,
+		MaxResults: 1000,
+	}
+
+	for {
+		repositoriesResp := DescribeRepositoriesDTO{}
+		client.call("DescribeRepositories", request, &repositoriesResp)
+
+		for _, repository := range repositoriesResp.Repositories {
+			repositories = append(repositories, repository.RepositoryName)
+		}
+
+		if repositoriesResp.NextToken == "" { // no more pages to GET
+			break
+		}
+
+		request.NextToken = repositoriesResp.NextToken
+	}
+
+	return repositories
+}
+
+func epochSecondsToMs(seconds float64) string {
+	return strconv.FormatInt(int64(math.Round(seconds*1000)), 10)
+}
+
+// ParseRepo parses all the images of a repository
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	request := DescribeImagesRequestDTO{
+		RegistryID:     client.registryID,
+		RepositoryName: repositoryLink,
+		MaxResults:     1000,
+	}
+
+	for {
+		imagesResp := DescribeImagesDTO{}
+		client.call("DescribeImages", request, &imagesResp)
+
+		for _, imageDetail := range imagesResp.ImageDetails {
+			pushedMs := epochSecondsToMs(imageDetail.ImagePushedAt)
+
+			repoImage := cr.ContainerImage{
+				Tag:            imageDetail.ImageTags,
+				Digest:         []string{imageDetail.ImageDigest},
+				MediaType:      imageDetail.ImageManifestMediaType,
+				ImageSizeBytes: strconv.FormatInt(imageDetail.ImageSizeInBytes, 10),
+				TimeCreatedMs:  pushedMs,
+				TimeUploadedMs: pushedMs,
+				Repo:           fmt.Sprintf("%v.dkr.ecr.%v.%v/%v", imageDetail.RegistryID, client.region, dnsSuffixOf(client.region), repositoryLink),
+			}
+
+			if repoImage.Tag == nil {
+				repoImage.Tag = []string{}
+			}
+
+			if imageDetail.LastRecordedPullTime != 0 {
+				repoImage.TimeLastPulledMs = epochSecondsToMs(imageDetail.LastRecordedPullTime)
+			}
+
+			repository.Images = append(repository.Images, repoImage)
+		}
+
+		if imagesResp.NextToken == "" { // no more pages to GET
+			break
+		}
+
+		request.NextToken = imagesResp.NextToken
+	}
+
+	return repository
+}
+
+// NewECRClientParams are the required parameters to build an ECR client
+type NewECRClientParams struct {
+	Region string
+	// RegistryID is the aws account id of the registry; empty means the registry of the account of the credentials
+	RegistryID string
+	// Profile is the profile of the shared credentials file to use; empty means the standard aws credential sources
+	Profile string
+	// Endpoint overrides the ecr api endpoint, e.g. for testing against a local endpoint; empty means the endpoint of the region
+	Endpoint string
+}
+
+// NewECRClient builds a new ECR client
+func NewECRClient(params NewECRClientParams) cr.Client {
+	endpoint := params.Endpoint
+	if endpoint == "" {
+		endpoint = "https://api.ecr." + params.Region + "." + dnsSuffixOf(params.Region)
+	}
+
+	return &RegistryClient{
+		httpClient: myhttp.NewClient(myhttp.NewClientParams{
+			BaseURL:             strings.TrimSuffix(endpoint, "/"),
+			InjectAuthInRequest: nil, // we set this once we have logged in
+		}),
+		region:      params.Region,
+		registryID:  params.RegistryID,
+		credentials: newCredentialsProvider(params.Profile, params.Region),
+	}
+}
@@ -0,0 +1,246 @@
+package ecr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+)
+
+// authorize lets through the requests that are signed for the ecr api of the region
+func authorize(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(r.Header.Get("Authorization"), "/eu-west-1/ecr/aws4_request") {
+		w.WriteHeader(http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
+
+// newFakeAPI starts a local stand-in of the ecr api with two pages of repositories and images; the image deletions are recorded along with their requests
+func newFakeAPI(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, authorize)
+
+	registry.Handle("POST", "/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/x-amz-json-1.1" {
+			t.Errorf("Wrong content type %v", r.Header.Get("Content-Type"))
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+
+		switch target := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), targetPrefix); target {
+		case "DescribeRepositories":
+			request := DescribeRepositoriesRequestDTO{}
+			_ = json.Unmarshal(body, &request)
+
+			if request.NextToken == "" {
+				_ = json.NewEncoder(w).Encode(DescribeRepositoriesDTO{Repositories: []RepositoryDTO{{RegistryID: "123", RepositoryName: "team/app"}}, NextToken: "page2"})
+			} else {
+				_ = json.NewEncoder(w).Encode(DescribeRepositoriesDTO{Repositories: []RepositoryDTO{{RegistryID: "123", RepositoryName: "worker"}}})
+			}
+		case "DescribeImages":
+			request := DescribeImagesRequestDTO{}
+			_ = json.Unmarshal(body, &request)
+
+			if request.RepositoryName != "team/app" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			if request.NextToken == "" {
+				_, _ = fmt.Fprint(w, `{"imageDetails":[{"registryId":"123","repositoryName":"team/app","imageDigest":"sha256:abc","imageTags":["latest","v1"],"imageSizeInBytes":1000,"imagePushedAt":1643814245.123,"lastRecordedPullTime":1646233445.0}],"nextToken":"page2"}`)
+			} else {
+				_, _ = fmt.Fprint(w, `{"imageDetails":[{"registryId":"123","repositoryName":"team/app","imageDigest":"sha256:def","imageSizeInBytes":500,"imagePushedAt":1643814000}]}`)
+			}
+		case "BatchDeleteImage":
+			request := BatchDeleteImageRequestDTO{}
+			_ = json.Unmarshal(body, &request)
+			registry.Record(registrytest.Deletion{Path: target, Payload: string(body)})
+
+			response := BatchDeleteImageDTO{ImageIds: []ImageIdentifierDTO{}, Failures: []ImageFailureDTO{}}
+
+			for _, imageID := range request.ImageIds {
+				if imageID.ImageDigest == "sha256:missing" {
+					response.Failures = append(response.Failures, ImageFailureDTO{ImageID: imageID, FailureCode: "ImageNotFound"})
+				} else {
+					response.ImageIds = append(response.ImageIds, imageID)
+				}
+			}
+
+			_ = json.NewEncoder(w).Encode(response)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})
+
+	return registry
+}
+
+func newTestClient(t *testing.T) (cr.Client, *registrytest.Registry) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+
+	registry := newFakeAPI(t)
+	client := NewECRClient(NewECRClientParams{
+		Region:   "eu-west-1",
+		Endpoint: registry.URL,
+	})
+
+	err := client.Login("", "")
+
+	if err != nil {
+		t.Errorf("Login should not fail: %v", err)
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"team/app", "worker"}) {
+		t.Errorf("Wrong repos %v", client.GetAllRepos())
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	repo := client.ParseRepo("team/app")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("Exactly 2 images should be parsed, not %v", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"latest", "v1"}) || image.ImageSizeBytes != "1000" || image.Repo != "123.dkr.ecr.eu-west-1.amazonaws.com/team/app" {
+		t.Errorf("Wrong image data %+v", image)
+	}
+
+	if image.TimeUploadedMs != "1643814245123" || image.TimeLastPulledMs != "1646233445000" {
+		t.Errorf("Wrong push or pull time %v %v", image.TimeUploadedMs, image.TimeLastPulledMs)
+	}
+
+	if repo.Images[1].TimeLastPulledMs != "" || repo.Images[1].Tag == nil {
+		t.Errorf("Wrong data of never pulled untagged image %+v", repo.Images[1])
+	}
+}
+
+func TestDeleteImages(t *testing.T) {
+	client, registry := newTestClient(t)
+
+	images := []cr.ContainerImage{}
+	for i := 0; i < 249; i++ {
+		images = append(images, cr.ContainerImage{Digest: []string{fmt.Sprintf("sha256:%03d", i)}})
+	}
+	images = append(images, cr.ContainerImage{Digest: []string{"sha256:missing"}})
+
+	deletedCount, scheduledCount, err := client.(cr.BulkDeleter).DeleteImages("team/app", images, true)
+
+	if err != nil || deletedCount != 249 || scheduledCount != 0 {
+		t.Errorf("All the existing images should be deleted, deleted %v (%v)", deletedCount, err)
+	}
+
+	deleteRequests := []BatchDeleteImageRequestDTO{}
+
+	for _, deletion := range registry.Deletions() {
+		request := BatchDeleteImageRequestDTO{}
+		_ = json.Unmarshal([]byte(deletion.Payload), &request)
+		deleteRequests = append(deleteRequests, request)
+	}
+
+	if len(deleteRequests) != 3 || len(deleteRequests[0].ImageIds) != 100 || len(deleteRequests[2].ImageIds) != 50 {
+		t.Errorf("Images should be deleted in chunks of 100, got %v chunks", len(deleteRequests))
+	}
+
+	if client.DeleteImage("team/app", cr.ContainerImage{Digest: []string{"sha256:missing"}}, true) == nil {
+		t.Error("Deleting a missing image should fail")
+	}
+}
+
+func TestSharedCredentialsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	_ = ioutil.WriteFile(path, []byte("[default]\naws_access_key_id = DEFAULT\naws_secret_access_key = x\n\n[ci]\naws_access_key_id=CI\naws_secret_access_key=y\naws_session_token = z\n"), 0600)
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+
+	credentials, err := newCredentialsProvider("ci", "eu-west-1").get()
+
+	if err != nil || credentials.AccessKeyID != "CI" || credentials.SecretAccessKey != "y" || credentials.SessionToken != "z" {
+		t.Errorf("Wrong credentials of profile %+v (%v)", credentials, err)
+	}
+
+	_, err = newCredentialsProvider("missing", "eu-west-1").get()
+
+	if err == nil {
+		t.Error("A missing profile should fail")
+	}
+}
+
+func TestInstanceMetadataCredentials(t *testing.T) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	credentialsRequests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "PUT" && r.URL.Path == "/latest/api/token":
+			_, _ = fmt.Fprint(w, "imds-token")
+		case r.Header.Get("X-aws-ec2-metadata-token") != "imds-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			_, _ = fmt.Fprint(w, "node-role")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/node-role":
+			credentialsRequests++
+			_, _ = fmt.Fprintf(w, `{"Code":"Success","AccessKeyId":"ASIA","SecretAccessKey":"s","Token":"t","Expiration":"%v"}`, expiration.Format(time.RFC3339))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "missing"))
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "")
+	t.Setenv("AWS_CONTAINER_CREDENTIALS_FULL_URI", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", server.URL)
+
+	provider := newCredentialsProvider("", "eu-west-1")
+	credentials, err := provider.get()
+
+	if err != nil || credentials.AccessKeyID != "ASIA" || credentials.SessionToken != "t" || !credentials.Expiration.Equal(expiration) {
+		t.Fatalf("Wrong instance credentials %+v (%v)", credentials, err)
+	}
+
+	_, _ = provider.get()
+
+	if credentialsRequests != 1 {
+		t.Error("Valid credentials should be cached")
+	}
+
+	provider.current.Expiration = time.Now().Add(time.Minute)
+	_, _ = provider.get()
+
+	if credentialsRequests != 2 {
+		t.Error("Credentials that are about to expire should be renewed")
+	}
+}
+
+func TestMain(m *testing.M) {
+	// make sure that the tests never reach a real credential source
+	os.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	os.Exit(m.Run())
+}
@@ -0,0 +1,85 @@
+package ecr
+
+import myhttp "github.com/hytromo/faulty-crane/internal/http"
+
+// RegistryClient is an amazon ecr client, it uses the ecr api which is signed with sigv4 instead of the docker registry api
+type RegistryClient struct {
+	httpClient myhttp.Client
+	region     string
+	// registryID is the aws account id of the registry; empty means the registry of the account of the credentials
+	registryID  string
+	credentials *credentialsProvider
+}
+
+// RepositoryDTO is the DTO of an ecr repository
+type RepositoryDTO struct {
+	RegistryID     string `json:"registryId"`
+	RepositoryName string `json:"repositoryName"`
+}
+
+// DescribeRepositoriesRequestDTO is the request of the DescribeRepositories action
+type DescribeRepositoriesRequestDTO struct {
+	RegistryID string `json:"registryId,omitempty"`
+	NextToken  string `json:"nextToken,omitempty"`
+	MaxResults int    `json:"maxResults,omitempty"`
+}
+
+// DescribeRepositoriesDTO is the response of the DescribeRepositories action
+type DescribeRepositoriesDTO struct {
+	Repositories []RepositoryDTO `json:"repositories"`
+	NextToken    string          `json:"nextToken"`
+}
+
+// ImageDetailDTO is the DTO of an ecr image
+type ImageDetailDTO struct {
+	RegistryID             string   `json:"registryId"`
+	RepositoryName         string   `json:"repositoryName"`
+	ImageDigest            string   `json:"imageDigest"`
+	ImageTags              []string `json:"imageTags"`
+	ImageSizeInBytes       int64    `json:"imageSizeInBytes"`
+	ImageManifestMediaType string   `json:"imageManifestMediaType"`
+	// ImagePushedAt is in seconds since the epoch, with a fractional part
+	ImagePushedAt float64 `json:"imagePushedAt"`
+	// LastRecordedPullTime is in seconds since the epoch and it is missing for images that have never been pulled
+	LastRecordedPullTime float64 `json:"lastRecordedPullTime"`
+}
+
+// DescribeImagesRequestDTO is the request of the DescribeImages action
+type DescribeImagesRequestDTO struct {
+	RegistryID     string `json:"registryId,omitempty"`
+	RepositoryName string `json:"repositoryName"`
+	NextToken      string `json:"nextToken,omitempty"`
+	MaxResults     int    `json:"maxResults,omitempty"`
+}
+
+// DescribeImagesDTO is the response of the DescribeImages action
+type DescribeImagesDTO struct {
+	ImageDetails []ImageDetailDTO `json:"imageDetails"`
+	NextToken    string           `json:"nextToken"`
+}
+
+// ImageIdentifierDTO identifies an image either by its digest or by its tag
+type ImageIdentifierDTO struct {
+	ImageDigest string `json:"imageDigest,omitempty"`
+	ImageTag    string `json:"imageTag,omitempty"`
+}
+
+// BatchDeleteImageRequestDTO is the request of the BatchDeleteImage action
+type BatchDeleteImageRequestDTO struct {
+	RegistryID     string               `json:"registryId,omitempty"`
+	RepositoryName string               `json:"repositoryName"`
+	ImageIds       []ImageIdentifierDTO `json:"imageIds"`
+}
+
+// ImageFailureDTO describes why an image could not be deleted
+type ImageFailureDTO struct {
+	ImageID       ImageIdentifierDTO `json:"imageId"`
+	FailureCode   string             `json:"failureCode"`
+	FailureReason string             `json:"failureReason"`
+}
+
+// BatchDeleteImageDTO is the response of the BatchDeleteImage action
+type BatchDeleteImageDTO struct {
+	ImageIds []ImageIdentifierDTO `json:"imageIds"`
+	Failures []ImageFailureDTO    `json:"failures"`
+}
@@ -0,0 +1,144 @@
+package ecr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+func hexSHA256(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode encodes everything except the unreserved characters of RFC 3986, as sigv4 requires
+func uriEncode(value string) string {
+	var encoded strings.Builder
+
+	for _, b := range []byte(value) {
+		if (b >= 'A' && b <= 'Z') || (b >= 'a' && b <= 'z') || (b >= '0' && b <= '9') || b == '-' || b == '_' || b == '.' || b == '~' {
+			encoded.WriteByte(b)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+
+	return encoded.String()
+}
+
+// canonicalURIOf encodes each segment of the already escaped path once more, as sigv4 requires for all the services but s3
+func canonicalURIOf(requestURL *url.URL) string {
+	path := requestURL.EscapedPath()
+
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func canonicalQueryOf(requestURL *url.URL) string {
+	query := requestURL.Query()
+	pairs := []string{}
+
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// canonicalHeadersOf returns the canonical headers and the signed headers lists; all the headers of the request are signed along with the host
+func canonicalHeadersOf(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	values := map[string]string{"host": host}
+
+	for key, headerValues := range req.Header {
+		name := strings.ToLower(key)
+		if name == "authorization" {
+			continue
+		}
+
+		trimmedValues := make([]string, len(headerValues))
+		for i, value := range headerValues {
+			trimmedValues[i] = strings.Join(strings.Fields(value), " ")
+		}
+
+		values[name] = strings.Join(trimmedValues, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + values[name] + "\n")
+	}
+
+	return canonicalHeaders.String(), strings.Join(names, ";")
+}
+
+// signRequest signs a request with AWS Signature Version 4 by setting its X-Amz-Date and Authorization headers
+func signRequest(req *http.Request, payload []byte, credentials Credentials, region string, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	canonicalHeaders, signedHeaders := canonicalHeadersOf(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURIOf(req.URL),
+		canonicalQueryOf(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		hexSHA256(payload),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	signingKey := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%v/%v, SignedHeaders=%v, Signature=%v", credentials.AccessKeyID, scope, signedHeaders, signature))
+}
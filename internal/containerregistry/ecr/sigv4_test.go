@@ -0,0 +1,41 @@
+package ecr
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestSignRequest checks the signer against the get-vanilla case of the aws sigv4 test suite
+func TestSignRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+
+	signRequest(req, []byte{}, Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "service", now)
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+
+	if req.Header.Get("Authorization") != expected {
+		t.Errorf("Wrong signature %v", req.Header.Get("Authorization"))
+	}
+}
+
+func TestSignRequestWithQuery(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", nil)
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+
+	signRequest(req, []byte{}, Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}, "us-east-1", "service", now)
+
+	// get-vanilla-query-order-key-case of the aws sigv4 test suite
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"
+
+	if req.Header.Get("Authorization") != expected {
+		t.Errorf("Wrong signature %v", req.Header.Get("Authorization"))
+	}
+}
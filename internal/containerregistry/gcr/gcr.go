@@ -4,32 +4,107 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
 	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	"github.com/hytromo/faulty-crane/internal/tokensource"
 	"github.com/hytromo/faulty-crane/internal/utils/stringutil"
 	log "github.com/sirupsen/logrus"
 )
 
+// regionalHosts are all the hosts that the images of a gcp project can be in
+var regionalHosts = []string{"gcr.io", "us.gcr.io", "eu.gcr.io", "asia.gcr.io"}
+
+// baseURLOf returns the registry api url of a host
+var baseURLOf = func(host string) string {
+	return fmt.Sprintf("https://%s/v2", host)
+}
+
 // GoogleContainerRegistryClient is a GCR client
 type GoogleContainerRegistryClient struct {
-	httpClient myhttp.Client
+	// httpClients are the clients of the hosts, by host
+	httpClients map[string]myhttp.Client
+	// hosts are the hosts that are swept, in order
+	hosts []string
+	// project limits the repositories to the ones of a gcp project; when it is set, the links of the repositories start with their host, as the same repository can exist in many hosts
+	project     string
+	tokenSource *tokensource.CachedSource
+}
+
+// hostAndPathOf splits a repository link into its host and its path on that host
+func (client *GoogleContainerRegistryClient) hostAndPathOf(repositoryLink string) (string, string) {
+	if client.project == "" {
+		return client.hosts[0], repositoryLink
+	}
+
+	host, path, _ := strings.Cut(repositoryLink, "/")
+
+	return host, path
 }
 
-// Login logins to GCR
+// Login gets the first access token, so that a misconfigured token source fails early
 func (client *GoogleContainerRegistryClient) Login(username string, password string) error {
 	// GCR client does not need to login to get any kind of token, it just needs to specify the token in each request
-	// regardless, we need to specify this function to comply with the CRClient interface
+	_, err := client.tokenSource.AccessToken()
+
+	return err
+}
+
+func (client *GoogleContainerRegistryClient) injectAuth(req *http.Request) {
+	token, err := client.tokenSource.AccessToken()
+
+	if err != nil {
+		log.Errorf("Could not get a new access token: %v", err)
+	}
+
+	req.SetBasicAuth("_token", token)
+}
+
+// refreshAuth drops the rejected token, so that the request is retried with a new one
+func (client *GoogleContainerRegistryClient) refreshAuth(resp *http.Response) error {
+	client.tokenSource.Invalidate()
+
 	return nil
 }
 
+// Capabilities returns what the client can do; GCR deletes images by digest and links image indexes to their platform manifests, but it does not track pulls
+func (client *GoogleContainerRegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest: true,
+		ManifestLists:  true,
+		FetchManifests: true,
+	}
+}
+
+// GetManifest returns the manifest of an image by its digest
+func (client *GoogleContainerRegistryClient) GetManifest(repositoryLink string, digest string) ([]byte, error) {
+	host, repositoryPath := client.hostAndPathOf(repositoryLink)
+
+	bodyBytes, _, err := client.httpClients[host].GetRequestWithHeadersTo("/"+repositoryPath+"/manifests/"+digest, http.Header{
+		"Accept": []string{strings.Join(cr.ManifestMediaTypes, ", ")},
+	})
+
+	return bodyBytes, err
+}
+
+// GetBlob returns a blob of a repository by its digest; GCR redirects the blobs to cloud storage
+func (client *GoogleContainerRegistryClient) GetBlob(repositoryLink string, digest string) ([]byte, error) {
+	host, repositoryPath := client.hostAndPathOf(repositoryLink)
+
+	return client.httpClients[host].GetRequestTo("/" + repositoryPath + "/blobs/" + digest)
+}
+
 // DeleteImage deletes an image from GCR
 func (client *GoogleContainerRegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	host, imagePath := client.hostAndPathOf(imageRepo)
+	httpClient := client.httpClients[host]
+
 	// all the tags of the image need to be deleted first
 	var err error
 
 	for _, tag := range image.Tag {
-		err = client.httpClient.DeleteRequestTo("/"+imageRepo+"/manifests/"+tag, true, silentErrors)
+		err = httpClient.DeleteRequestTo("/"+imagePath+"/manifests/"+tag, true, silentErrors)
 		if err != nil {
 			return err
 		}
@@ -37,7 +112,7 @@ func (client *GoogleContainerRegistryClient) DeleteImage(imageRepo string, image
 
 	for _, digest := range image.Digest {
 		// after all the image tags have been deleted, we can delete the image itself
-		err = client.httpClient.DeleteRequestTo("/"+imageRepo+"/manifests/"+digest, true, silentErrors)
+		err = httpClient.DeleteRequestTo("/"+imagePath+"/manifests/"+digest, true, silentErrors)
 		if err != nil {
 			return err
 		}
@@ -46,8 +121,28 @@ func (client *GoogleContainerRegistryClient) DeleteImage(imageRepo string, image
 	return nil
 }
 
-// GetAllRepos parses or repos of GCR
+// GetAllRepos parses the repos of GCR; when a project is set, all the regional hosts are swept and only the repositories of the project are kept
 func (client *GoogleContainerRegistryClient) GetAllRepos() []string {
+	if client.project == "" {
+		return client.getHostRepos(client.hosts[0])
+	}
+
+	repositories := []string{}
+
+	for _, host := range client.hosts {
+		for _, repository := range client.getHostRepos(host) {
+			// the catalog also contains the repositories of the other projects that the token can see
+			if strings.HasPrefix(repository, client.project+"/") {
+				repositories = append(repositories, host+"/"+repository)
+			}
+		}
+	}
+
+	return repositories
+}
+
+func (client *GoogleContainerRegistryClient) getHostRepos(host string) []string {
+	httpClient := client.httpClients[host]
 	repositories := []string{}
 
 	catalogResp := cr.CatalogDTO{
@@ -55,7 +150,7 @@ func (client *GoogleContainerRegistryClient) GetAllRepos() []string {
 	}
 
 	for {
-		bodyBytes, err := client.httpClient.GetRequestTo(catalogResp.Next)
+		bodyBytes, err := httpClient.GetRequestTo(catalogResp.Next)
 
 		if err != nil {
 			log.Fatalf("Error on api call: %v", err.Error())
@@ -74,7 +169,7 @@ func (client *GoogleContainerRegistryClient) GetAllRepos() []string {
 			break
 		} else { // more pages to GET
 			// remove the prefix of the link as our gcr client works with suffixes
-			catalogResp.Next = stringutil.TrimLeftChars(catalogResp.Next, len(client.httpClient.BaseURL))
+			catalogResp.Next = stringutil.TrimLeftChars(catalogResp.Next, len(httpClient.BaseURL))
 			if catalogResp.Next[0] != '/' {
 				catalogResp.Next = "/" + catalogResp.Next
 			}
@@ -86,17 +181,21 @@ func (client *GoogleContainerRegistryClient) GetAllRepos() []string {
 
 // ParseRepo parses a specific repo
 func (client *GoogleContainerRegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	host, repositoryPath := client.hostAndPathOf(repositoryLink)
+	httpClient := client.httpClients[host]
+
 	repository := cr.Repository{
 		Link:   repositoryLink,
+		Host:   host,
 		Images: []cr.ContainerImage{},
 	}
 
 	listTagsResp := cr.ListTagsDTO{
-		Next: "/" + repositoryLink + "/tags/list", // initial request
+		Next: "/" + repositoryPath + "/tags/list", // initial request
 	}
 
 	for {
-		bodyBytes, err := client.httpClient.GetRequestTo(listTagsResp.Next)
+		bodyBytes, err := httpClient.GetRequestTo(listTagsResp.Next)
 
 		if err != nil {
 			log.Fatalf("Error on api call: %v", err.Error())
@@ -107,7 +206,7 @@ func (client *GoogleContainerRegistryClient) ParseRepo(repositoryLink string) cr
 
 		for digest, image := range listTagsResp.Manifest {
 			image.Digest = []string{digest}
-			image.Repo = client.httpClient.BaseURL + "/" + repositoryLink
+			image.Repo = host + "/" + repositoryPath
 			repository.Images = append(repository.Images, image)
 		}
 
@@ -120,25 +219,75 @@ func (client *GoogleContainerRegistryClient) ParseRepo(repositoryLink string) cr
 		}
 	}
 
+	// the platform manifests of multi-arch images are listed as untagged images of their own, they need to be linked to their image index
+	for i, image := range repository.Images {
+		if cr.IsIndexMediaType(image.MediaType) {
+			repository.Images[i].Children = client.getIndexChildren(httpClient, repositoryPath, image.Digest[0])
+		}
+	}
+
+	cr.LinkIndexChildren(repository.Images)
+
 	return repository
 }
 
+// getIndexChildren returns the digests of the manifests that an image index references
+func (client *GoogleContainerRegistryClient) getIndexChildren(httpClient myhttp.Client, repositoryPath string, digest string) []string {
+	bodyBytes, _, err := httpClient.GetRequestWithHeadersTo("/"+repositoryPath+"/manifests/"+digest, http.Header{
+		"Accept": []string{strings.Join(cr.IndexMediaTypes, ", ")},
+	})
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	manifestList := cr.ManifestListDTO{}
+	err = json.Unmarshal(bodyBytes, &manifestList)
+
+	if err != nil {
+		log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	children := []string{}
+
+	for _, manifest := range manifestList.Manifests {
+		children = append(children, manifest.Digest)
+	}
+
+	return children
+}
+
 // NewGCRClientParams are the required parameters to build a GCR client
 type NewGCRClientParams struct {
 	// one of gcr.io, us.gcr.io, eu.gcr.io, asia.gcr.io https://cloud.google.com/container-registry/docs/overview#registries
 	Hostname string
-	// e.g. the result of `gcloud auth print-access-token`
-	Token string
+	// Project sweeps all the regional hosts for the repositories of a gcp project instead of using a single host
+	Project string
+	// TokenSource gives out the access tokens, e.g. the result of `gcloud auth print-access-token`; its tokens are refreshed before they expire
+	TokenSource tokensource.Source
 }
 
 // NewGCRClient builds a new GCR client
 func NewGCRClient(params NewGCRClientParams) cr.Client {
-	return &GoogleContainerRegistryClient{
-		httpClient: myhttp.NewClient(myhttp.NewClientParams{
-			BaseURL: fmt.Sprintf("https://%s/v2", params.Hostname),
-			InjectAuthInRequest: func(req *http.Request) {
-				req.SetBasicAuth("_token", params.Token)
-			},
-		}),
+	client := &GoogleContainerRegistryClient{
+		httpClients: map[string]myhttp.Client{},
+		hosts:       []string{params.Hostname},
+		project:     params.Project,
+		tokenSource: tokensource.NewCachedSource(params.TokenSource),
+	}
+
+	if params.Project != "" {
+		client.hosts = regionalHosts
 	}
+
+	// all the hosts share the same token
+	for _, host := range client.hosts {
+		client.httpClients[host] = myhttp.NewClient(myhttp.NewClientParams{
+			BaseURL:             baseURLOf(host),
+			InjectAuthInRequest: client.injectAuth,
+			RefreshAuth:         client.refreshAuth,
+		})
+	}
+
+	return client
 }
@@ -0,0 +1,135 @@
+package gcr
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+	"github.com/hytromo/faulty-crane/internal/tokensource"
+)
+
+// newFakeHosts starts a local stand-in of all the regional gcr hosts, each one under a path prefix of its own
+func newFakeHosts(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, registrytest.BasicAuth("_token", "token"))
+
+	registry.Handle("GET", "/gcr.io/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("last") != "" {
+			_, _ = w.Write([]byte(`{"repositories": ["my-project/tools/builder"]}`))
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"repositories": ["my-project/app", "other-project/app"], "next": "http://` + r.Host + `/gcr.io/v2/_catalog?last=other-project/app"}`))
+	})
+	registry.Handle("GET", "/eu.gcr.io/v2/_catalog", registrytest.Status(http.StatusOK, `{"repositories": ["my-project/app", "my-project-2/app"]}`))
+	registry.Handle("GET", "/us.gcr.io/v2/_catalog", registrytest.Status(http.StatusOK, `{"repositories": []}`))
+	registry.Handle("GET", "/asia.gcr.io/v2/_catalog", registrytest.Status(http.StatusOK, `{"repositories": []}`))
+	registry.Handle("GET", "/gcr.io/v2/my-project/multi-arch/tags/list", registrytest.Status(http.StatusOK, `{"name": "my-project/multi-arch", "tags": ["v1"], "manifest": {`+
+		`"sha256:index": {"imageSizeBytes": "0", "mediaType": "application/vnd.docker.distribution.manifest.list.v2+json", "tag": ["v1"], "timeCreatedMs": "1", "timeUploadedMs": "2"},`+
+		`"sha256:amd64": {"imageSizeBytes": "100", "mediaType": "application/vnd.docker.distribution.manifest.v2+json", "tag": [], "timeCreatedMs": "1", "timeUploadedMs": "2"},`+
+		`"sha256:arm64": {"imageSizeBytes": "100", "mediaType": "application/vnd.docker.distribution.manifest.v2+json", "tag": [], "timeCreatedMs": "1", "timeUploadedMs": "2"}}}`))
+	registry.Handle("GET", "/gcr.io/v2/my-project/multi-arch/manifests/sha256:index", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Accept"), "application/vnd.docker.distribution.manifest.list.v2+json") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json", "manifests": [{"digest": "sha256:amd64"}, {"digest": "sha256:arm64"}]}`))
+	})
+	registry.Handle("GET", "/eu.gcr.io/v2/my-project/app/tags/list", registrytest.Status(http.StatusOK, `{"name": "my-project/app", "tags": ["v1"], "manifest": {"sha256:abc": {"imageSizeBytes": "100", "mediaType": "application/vnd.docker.distribution.manifest.v2+json", "tag": ["v1"], "timeCreatedMs": "1", "timeUploadedMs": "2"}}}`))
+
+	originalBaseURLOf := baseURLOf
+	baseURLOf = func(host string) string {
+		return registry.URL + "/" + host + "/v2"
+	}
+	t.Cleanup(func() { baseURLOf = originalBaseURLOf })
+
+	return registry
+}
+
+func TestProjectSweep(t *testing.T) {
+	registry := newFakeHosts(t)
+
+	client := NewGCRClient(NewGCRClientParams{
+		Project:     "my-project",
+		TokenSource: tokensource.StaticSource{AccessToken: "token"},
+	})
+
+	if err := client.Login("", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	repos := client.GetAllRepos()
+	sort.Strings(repos)
+
+	if !reflect.DeepEqual(repos, []string{"eu.gcr.io/my-project/app", "gcr.io/my-project/app", "gcr.io/my-project/tools/builder"}) {
+		t.Errorf("Only the repositories of the project should be found in all the hosts, got %v", repos)
+	}
+
+	repo := client.ParseRepo("eu.gcr.io/my-project/app")
+
+	if repo.Host != "eu.gcr.io" || repo.Link != "eu.gcr.io/my-project/app" || len(repo.Images) != 1 {
+		t.Fatalf("Wrong repository %+v", repo)
+	}
+
+	if repo.Images[0].Repo != "eu.gcr.io/my-project/app" || !reflect.DeepEqual(repo.Images[0].Digest, []string{"sha256:abc"}) {
+		t.Errorf("Wrong image %+v", repo.Images[0])
+	}
+
+	if err := client.DeleteImage(repo.Link, repo.Images[0], true); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{"/eu.gcr.io/v2/my-project/app/manifests/v1", "/eu.gcr.io/v2/my-project/app/manifests/sha256:abc"}) {
+		t.Errorf("The image should be deleted from its own host, deleted %v", registry.Deleted())
+	}
+}
+
+func TestSingleHost(t *testing.T) {
+	newFakeHosts(t)
+
+	client := NewGCRClient(NewGCRClientParams{
+		Hostname:    "eu.gcr.io",
+		TokenSource: tokensource.StaticSource{AccessToken: "token"},
+	})
+
+	if repos := client.GetAllRepos(); !reflect.DeepEqual(repos, []string{"my-project/app", "my-project-2/app"}) {
+		t.Errorf("A single host should return its whole catalog, got %v", repos)
+	}
+
+	repo := client.ParseRepo("my-project/app")
+
+	if repo.Host != "eu.gcr.io" || !strings.HasPrefix(repo.Images[0].Repo, "eu.gcr.io/") {
+		t.Errorf("Wrong repository %+v", repo)
+	}
+}
+
+func TestManifestList(t *testing.T) {
+	newFakeHosts(t)
+
+	client := NewGCRClient(NewGCRClientParams{
+		Hostname:    "gcr.io",
+		TokenSource: tokensource.StaticSource{AccessToken: "token"},
+	})
+
+	repo := client.ParseRepo("my-project/multi-arch")
+
+	if len(repo.Images) != 3 {
+		t.Fatalf("Exactly 3 images should be parsed, not %v", len(repo.Images))
+	}
+
+	for _, image := range repo.Images {
+		switch image.Digest[0] {
+		case "sha256:index":
+			if !reflect.DeepEqual(image.Children, []string{"sha256:amd64", "sha256:arm64"}) || !image.IsIndex() {
+				t.Errorf("The index should reference its platform manifests %+v", image)
+			}
+		default:
+			if !reflect.DeepEqual(image.Parents, []string{"sha256:index"}) || image.IsStandalone() {
+				t.Errorf("The platform manifest should be linked to its index %+v", image)
+			}
+		}
+	}
+}
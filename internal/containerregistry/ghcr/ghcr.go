@@ -0,0 +1,188 @@
+package ghcr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	log "github.com/sirupsen/logrus"
+)
+
+var defaultEndpoint = "https://api.github.com"
+
+func (client *RegistryClient) getUser(path string) UserDTO {
+	bodyBytes, err := client.httpClient.GetRequestTo(path)
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	user := UserDTO{}
+	err = json.Unmarshal(bodyBytes, &user)
+
+	if err != nil {
+		log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	return user
+}
+
+// packagePath returns the api path of a package given a repository link, e.g. my-org/team/app
+func (client *RegistryClient) packagePath(repositoryLink string) string {
+	packageName := strings.TrimPrefix(repositoryLink, client.owner+"/")
+
+	// package names can contain slashes which need to be escaped
+	return client.packagesPath + "/packages/container/" + url.PathEscape(packageName)
+}
+
+// Login sets the token that is used on every request and finds out whether the owner is an organization or a user
+func (client *RegistryClient) Login(username string, password string) error {
+	client.httpClient.InjectAuthInRequest = func(req *http.Request) {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", password))
+		req.Header.Add("Accept", "application/vnd.github+json")
+	}
+
+	owner := client.getUser("/users/" + client.owner)
+
+	if owner.Type == "Organization" {
+		client.packagesPath = "/orgs/" + client.owner
+		return nil
+	}
+
+	// the private packages of a user are only visible through the /user endpoints
+	if strings.EqualFold(client.getUser("/user").Login, client.owner) {
+		client.packagesPath = "/user"
+	} else {
+		client.packagesPath = "/users/" + client.owner
+	}
+
+	return nil
+}
+
+// Capabilities returns what the client can do; GHCR deletes package versions by digest
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest: true,
+	}
+}
+
+// DeleteImage deletes a package version, along with all its tags
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	return client.httpClient.DeleteRequestTo(client.packagePath(imageRepo)+"/versions/"+image.VersionID, true, silentErrors)
+}
+
+// GetAllRepos returns all the container packages of the owner
+func (client *RegistryClient) GetAllRepos() []string {
+	repositories := []string{}
+	next := client.packagesPath + "/packages?package_type=container&per_page=100" // initial request
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		packagesResp := []PackageDTO{}
+		err = json.Unmarshal(bodyBytes, &packagesResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, pkg := range packagesResp {
+			repositories = append(repositories, client.owner+"/"+pkg.Name)
+		}
+
+		next = myhttp.NextLink(headers)
+	}
+
+	return repositories
+}
+
+// ParseRepo parses all the versions of a container package
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	next := client.packagePath(repositoryLink) + "/versions?per_page=100" // initial request
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		versionsResp := []VersionDTO{}
+		err = json.Unmarshal(bodyBytes, &versionsResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, version := range versionsResp {
+			repoImage := cr.ContainerImage{
+				VersionID: strconv.FormatInt(version.ID, 10),
+				Digest:    []string{version.Name},
+				Tag:       version.Metadata.Container.Tags,
+				// the packages api does not expose the size of the images, so it stays unknown
+				Repo: "ghcr.io/" + strings.ToLower(repositoryLink),
+			}
+
+			if repoImage.Tag == nil {
+				repoImage.Tag = []string{}
+			}
+
+			t, err := time.Parse(time.RFC3339, version.CreatedAt)
+
+			if err != nil {
+				// without an upload time the age and the number rules cannot judge the version, so it is left alone
+				log.Errorf("Version %v of %v contains invalid creation time: %v, skipping it", version.ID, repositoryLink, version.CreatedAt)
+				continue
+			}
+
+			createdMs := strconv.FormatInt(t.UTC().UnixMilli(), 10)
+			repoImage.TimeCreatedMs = createdMs
+			repoImage.TimeUploadedMs = createdMs
+
+			repository.Images = append(repository.Images, repoImage)
+		}
+
+		next = myhttp.NextLink(headers)
+	}
+
+	return repository
+}
+
+// NewGHCRClientParams are the required parameters to build a GHCR client
+type NewGHCRClientParams struct {
+	// Owner is the organization or the user that owns the packages
+	Owner string
+	// Endpoint overrides the GitHub API endpoint, e.g. for GitHub Enterprise; empty means the public api
+	Endpoint string
+}
+
+// NewGHCRClient builds a new GHCR client
+func NewGHCRClient(params NewGHCRClientParams) cr.Client {
+	endpoint := params.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	return &RegistryClient{
+		httpClient: myhttp.NewClient(myhttp.NewClientParams{
+			BaseURL:             strings.TrimSuffix(endpoint, "/"),
+			InjectAuthInRequest: nil, // we set this once we have logged in
+		}),
+		owner: params.Owner,
+	}
+}
@@ -0,0 +1,99 @@
+package ghcr
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+)
+
+// newFakeAPI starts a local stand-in of the GitHub Packages API for an organization
+func newFakeAPI(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, registrytest.BearerToken("token"))
+
+	registry.Handle("GET", "/users/my-org", registrytest.JSON(UserDTO{Login: "my-org", Type: "Organization"}))
+	registry.Handle("GET", "/orgs/my-org/packages", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "" {
+			_ = json.NewEncoder(w).Encode([]PackageDTO{{ID: 2, Name: "worker"}})
+			return
+		}
+
+		if r.URL.Query().Get("package_type") != "container" {
+			t.Error("Only container packages should be listed")
+		}
+
+		w.Header().Set("Link", `<`+"http://"+r.Host+`/orgs/my-org/packages?package_type=container&per_page=100&page=2>; rel="next", <http://`+r.Host+`/orgs/my-org/packages?page=2>; rel="last"`)
+		_ = json.NewEncoder(w).Encode([]PackageDTO{{ID: 1, Name: "team/app"}})
+	})
+	registry.Handle("GET", "/orgs/my-org/packages/container/team%2Fapp/versions", registrytest.JSON([]VersionDTO{
+		{ID: 11, Name: "sha256:abc", CreatedAt: "2022-02-02T15:04:05Z", Metadata: VersionMetadataDTO{PackageType: "container", Container: ContainerMetadataDTO{Tags: []string{"latest", "v1"}}}},
+		{ID: 12, Name: "sha256:def", CreatedAt: "2022-02-01T15:04:05Z"},
+	}))
+
+	return registry
+}
+
+func newTestClient(t *testing.T) (cr.Client, *registrytest.Registry) {
+	registry := newFakeAPI(t)
+	client := NewGHCRClient(NewGHCRClientParams{
+		Owner:    "my-org",
+		Endpoint: registry.URL,
+	})
+
+	err := client.Login("", "token")
+
+	if err != nil {
+		t.Error("Login should not fail")
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"my-org/team/app", "my-org/worker"}) {
+		t.Errorf("Wrong repos %v", client.GetAllRepos())
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	repo := client.ParseRepo("my-org/team/app")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("Exactly 2 images should be parsed, not %v", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"latest", "v1"}) || !reflect.DeepEqual(image.Digest, []string{"sha256:abc"}) || image.VersionID != "11" || image.ImageSizeBytes != "" {
+		t.Errorf("Wrong image data %+v", image)
+	}
+
+	if image.TimeUploadedMs != "1643814245000" || image.Repo != "ghcr.io/my-org/team/app" {
+		t.Errorf("Wrong upload time or repo: %v %v", image.TimeUploadedMs, image.Repo)
+	}
+
+	if repo.Images[1].Tag == nil || len(repo.Images[1].Tag) != 0 {
+		t.Error("Untagged versions should have an empty tag list")
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	client, registry := newTestClient(t)
+
+	err := client.DeleteImage("my-org/team/app", cr.ContainerImage{VersionID: "12", Digest: []string{"sha256:def"}}, false)
+
+	if err != nil {
+		t.Error("Delete should not fail")
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{"/orgs/my-org/packages/container/team%2Fapp/versions/12"}) {
+		t.Errorf("Wrong deleted versions %v", registry.Deleted())
+	}
+}
@@ -0,0 +1,44 @@
+package ghcr
+
+import myhttp "github.com/hytromo/faulty-crane/internal/http"
+
+// RegistryClient is a GitHub Container Registry client, it uses the GitHub Packages API
+type RegistryClient struct {
+	httpClient myhttp.Client
+	owner      string
+	// packagesPath is the api path that the packages of the owner live under, e.g. /orgs/my-org or /user
+	packagesPath string
+}
+
+// UserDTO is the Data Transfer Object for the /user and /users/{username} api calls
+type UserDTO struct {
+	Login string
+	// Type is either "User" or "Organization"
+	Type string
+}
+
+// PackageDTO is the DTO of a container package
+type PackageDTO struct {
+	ID   int64
+	Name string
+}
+
+// ContainerMetadataDTO is the container specific metadata of a package version
+type ContainerMetadataDTO struct {
+	Tags []string
+}
+
+// VersionMetadataDTO is the metadata of a package version
+type VersionMetadataDTO struct {
+	PackageType string `json:"package_type"`
+	Container   ContainerMetadataDTO
+}
+
+// VersionDTO is the DTO of a package version; the name of a container package version is the digest of the image
+type VersionDTO struct {
+	ID        int64
+	Name      string
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+	Metadata  VersionMetadataDTO
+}
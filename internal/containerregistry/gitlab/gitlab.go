@@ -0,0 +1,322 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	log "github.com/sirupsen/logrus"
+)
+
+// Login sets the access token that is used on every request
+func (client *RegistryClient) Login(username string, password string) error {
+	client.httpClient.InjectAuthInRequest = func(req *http.Request) {
+		req.Header.Add("PRIVATE-TOKEN", password)
+	}
+
+	return nil
+}
+
+// walkRepositories finds all the registry repositories of all the projects of the group, including its subgroups
+func (client *RegistryClient) walkRepositories() {
+	client.repositories = map[string]RepositoryDTO{}
+	next := "/groups/" + url.PathEscape(client.group) + "/projects?include_subgroups=true&per_page=100" // initial request
+
+	projects := []ProjectDTO{}
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		projectsResp := []ProjectDTO{}
+		err = json.Unmarshal(bodyBytes, &projectsResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		projects = append(projects, projectsResp...)
+		next = myhttp.NextLink(headers)
+	}
+
+	for _, project := range projects {
+		if project.ContainerRegistryAccessLevel == "disabled" {
+			continue
+		}
+
+		next = fmt.Sprintf("/projects/%d/registry/repositories?per_page=100", project.ID)
+
+		for next != "" {
+			bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+			if err != nil {
+				log.Fatalf("Error on api call: %v", err.Error())
+			}
+
+			repositoriesResp := []RepositoryDTO{}
+			err = json.Unmarshal(bodyBytes, &repositoriesResp)
+
+			if err != nil {
+				log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+			}
+
+			for _, repository := range repositoriesResp {
+				client.repositories[repository.Path] = repository
+				client.repositoryPaths = append(client.repositoryPaths, repository.Path)
+			}
+
+			next = myhttp.NextLink(headers)
+		}
+	}
+}
+
+// repositoryOf returns the registry repository of a repository link, walking the group if this has not happened yet (e.g. when applying a plan file)
+func (client *RegistryClient) repositoryOf(repositoryLink string) RepositoryDTO {
+	client.walkOnce.Do(client.walkRepositories)
+
+	repository, exists := client.repositories[repositoryLink]
+
+	if !exists {
+		log.Fatalf("Repository %v does not exist in group %v", repositoryLink, client.group)
+	}
+
+	return repository
+}
+
+func tagsPathOf(repository RepositoryDTO) string {
+	return fmt.Sprintf("/projects/%d/registry/repositories/%d/tags", repository.ProjectID, repository.ID)
+}
+
+// Capabilities returns what the client can do; GitLab deletes tags only
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteTagsOnly: true,
+	}
+}
+
+// DeleteImage deletes all the tags of an image
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	tagsPath := tagsPathOf(client.repositoryOf(imageRepo))
+
+	for _, tag := range image.Tag {
+		err := client.httpClient.DeleteRequestTo(tagsPath+"/"+url.PathEscape(tag), true, silentErrors)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Capabilities returns what the client can do; on top of the tags, it deletes them in bulk
+func (client *BulkRegistryClient) Capabilities() cr.Capabilities {
+	capabilities := client.RegistryClient.Capabilities()
+	capabilities.BulkDelete = true
+
+	return capabilities
+}
+
+// maxNameRegexDeleteLength caps the regex of a bulk tag deletion, so that a repository with many tags to delete does not end up with an unbounded regex
+var maxNameRegexDeleteLength = 4096
+
+// DeleteImages deletes the tags of the given images with a single call to the bulk deletion endpoint; GitLab carries out the deletion asynchronously, allows it once per hour per repository and always keeps the latest tag, which is deleted separately. The images are reported as scheduled and the ones whose tags do not fit in the regex are left for a later run
+func (client *BulkRegistryClient) DeleteImages(imageRepo string, images []cr.ContainerImage, silentErrors bool) (int, int, error) {
+	tagsPath := tagsPathOf(client.repositoryOf(imageRepo))
+	deletedCount := 0
+	scheduledCount := 0
+	leftCount := 0
+	quotedTags := []string{}
+	nameRegexLength := len("^(?:)$")
+
+	for _, image := range images {
+		imageQuotedTags := []string{}
+		hasLatestTag := false
+
+		for _, tag := range image.Tag {
+			if tag == "latest" {
+				hasLatestTag = true
+				continue
+			}
+
+			imageQuotedTags = append(imageQuotedTags, regexp.QuoteMeta(tag))
+		}
+
+		imageRegexLength := len(strings.Join(imageQuotedTags, "|")) + 1
+
+		if len(imageQuotedTags) > 0 && nameRegexLength+imageRegexLength > maxNameRegexDeleteLength {
+			leftCount++
+			continue
+		}
+
+		if hasLatestTag {
+			err := client.httpClient.DeleteRequestTo(tagsPath+"/latest", true, silentErrors)
+			if err != nil {
+				return deletedCount, 0, err
+			}
+		}
+
+		if len(imageQuotedTags) == 0 {
+			deletedCount++
+			continue
+		}
+
+		quotedTags = append(quotedTags, imageQuotedTags...)
+		nameRegexLength += imageRegexLength
+		scheduledCount++
+	}
+
+	if leftCount > 0 && !silentErrors {
+		log.Infof("Leaving %v image(s) of %v for a later run, as their tags do not fit in a single bulk deletion", leftCount, imageRepo)
+	}
+
+	if len(quotedTags) == 0 {
+		return deletedCount, 0, nil
+	}
+
+	jsonPayload, _ := json.Marshal(BulkDeleteTagsDTO{
+		NameRegexDelete: "^(?:" + strings.Join(quotedTags, "|") + ")$",
+	})
+
+	err := client.httpClient.DeleteRequestWithPayloadTo(tagsPath, jsonPayload, true, silentErrors)
+
+	if err != nil {
+		return deletedCount, 0, err
+	}
+
+	return deletedCount, scheduledCount, nil
+}
+
+// GetAllRepos returns the paths of all the registry repositories of the group
+func (client *RegistryClient) GetAllRepos() []string {
+	client.walkOnce.Do(client.walkRepositories)
+
+	return client.repositoryPaths
+}
+
+func (client *RegistryClient) getTagDetails(repository RepositoryDTO, tagName string) TagDTO {
+	bodyBytes, err := client.httpClient.GetRequestTo(tagsPathOf(repository) + "/" + url.PathEscape(tagName))
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	tag := TagDTO{}
+	err = json.Unmarshal(bodyBytes, &tag)
+
+	if err != nil {
+		log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	return tag
+}
+
+// ParseRepo parses all the tags of a registry repository
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	registryRepository := client.repositoryOf(repositoryLink)
+	tagNames := []string{}
+	next := tagsPathOf(registryRepository) + "?per_page=100" // initial request
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		tagsResp := []TagDTO{}
+		err = json.Unmarshal(bodyBytes, &tagsResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, tag := range tagsResp {
+			tagNames = append(tagNames, tag.Name)
+		}
+
+		next = myhttp.NextLink(headers)
+	}
+
+	// the tag list does not contain the digest, the size and the creation time, so we need to fetch each tag; tags pointing to the same digest are grouped into a single image
+	imageIndexOfDigest := map[string]int{}
+
+	for _, tagName := range tagNames {
+		tag := client.getTagDetails(registryRepository, tagName)
+
+		if imageIndex, exists := imageIndexOfDigest[tag.Digest]; exists {
+			repository.Images[imageIndex].Tag = append(repository.Images[imageIndex].Tag, tag.Name)
+			continue
+		}
+
+		repoImage := cr.ContainerImage{
+			Tag:            []string{tag.Name},
+			Digest:         []string{tag.Digest},
+			ImageSizeBytes: strconv.FormatInt(tag.TotalSize, 10),
+			Repo:           registryRepository.Location,
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, tag.CreatedAt)
+
+		if err != nil {
+			// without an upload time the age and the number rules cannot judge the tag, so it is left alone
+			log.Errorf("Tag %v of %v contains invalid creation time: %v, skipping it", tag.Name, repositoryLink, tag.CreatedAt)
+			continue
+		}
+
+		createdMs := strconv.FormatInt(t.UTC().UnixMilli(), 10)
+		repoImage.TimeCreatedMs = createdMs
+		repoImage.TimeUploadedMs = createdMs
+
+		imageIndexOfDigest[tag.Digest] = len(repository.Images)
+		repository.Images = append(repository.Images, repoImage)
+	}
+
+	return repository
+}
+
+// NewGitLabClientParams are the required parameters to build a GitLab client
+type NewGitLabClientParams struct {
+	// Host is the url of the GitLab instance, e.g. https://gitlab.example.com
+	Host string
+	// Group is the full path of the group whose projects are going to be cleaned, e.g. my-group/sub-group
+	Group string
+	// BulkDelete makes the client delete the tags of each repository through the bulk tag deletion endpoint
+	BulkDelete bool
+}
+
+// NewGitLabClient builds a new GitLab client
+func NewGitLabClient(params NewGitLabClientParams) cr.Client {
+	baseURL := strings.TrimSuffix(params.Host, "/")
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "https://" + baseURL
+	}
+
+	client := &RegistryClient{
+		httpClient: myhttp.NewClient(myhttp.NewClientParams{
+			BaseURL:             baseURL + "/api/v4",
+			InjectAuthInRequest: nil, // we set this once we have logged in
+		}),
+		group: params.Group,
+	}
+
+	if params.BulkDelete {
+		return &BulkRegistryClient{client}
+	}
+
+	return client
+}
@@ -0,0 +1,177 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+)
+
+// newFakeAPI starts a local stand-in of the GitLab API with one group that has two projects, one of them without a registry
+func newFakeAPI(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, registrytest.HeaderValue("PRIVATE-TOKEN", "token"))
+	tagsPath := "/api/v4/projects/1/registry/repositories/10/tags"
+
+	registry.Handle("GET", "/api/v4/groups/my-group%2Fsub/projects", registrytest.JSON([]ProjectDTO{
+		{ID: 1, PathWithNamespace: "my-group/sub/app", ContainerRegistryAccessLevel: "enabled"},
+		{ID: 2, PathWithNamespace: "my-group/sub/docs", ContainerRegistryAccessLevel: "disabled"},
+	}))
+	registry.Handle("GET", "/api/v4/projects/1/registry/repositories", registrytest.JSON([]RepositoryDTO{
+		{ID: 10, ProjectID: 1, Path: "my-group/sub/app", Location: "registry.example.com/my-group/sub/app"},
+		{ID: 11, ProjectID: 1, Path: "my-group/sub/app/worker", Location: "registry.example.com/my-group/sub/app/worker"},
+	}))
+	registry.Handle("GET", tagsPath, registrytest.JSON([]TagDTO{{Name: "main"}, {Name: "latest"}, {Name: "feature-x"}}))
+
+	for _, tag := range []string{"main", "latest"} {
+		registry.Handle("GET", tagsPath+"/"+tag, registrytest.JSON(TagDTO{Name: tag, Digest: "sha256:abc", TotalSize: 1000, CreatedAt: "2022-02-02T15:04:05.123+00:00"}))
+	}
+
+	registry.Handle("GET", tagsPath+"/feature-x", registrytest.JSON(TagDTO{Name: "feature-x", Digest: "sha256:def", TotalSize: 500, CreatedAt: "2022-01-02T15:04:05.000+00:00"}))
+
+	return registry
+}
+
+func newTestClient(t *testing.T, bulkDelete bool) (cr.Client, *registrytest.Registry) {
+	registry := newFakeAPI(t)
+	client := NewGitLabClient(NewGitLabClientParams{
+		Host:       registry.URL,
+		Group:      "my-group/sub",
+		BulkDelete: bulkDelete,
+	})
+
+	err := client.Login("", "token")
+
+	if err != nil {
+		t.Error("Login should not fail")
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	client, _ := newTestClient(t, false)
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"my-group/sub/app", "my-group/sub/app/worker"}) {
+		t.Errorf("Wrong repos %v", client.GetAllRepos())
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t, false)
+
+	repo := client.ParseRepo("my-group/sub/app")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("Tags with the same digest should be grouped, got %v images", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"main", "latest"}) || !reflect.DeepEqual(image.Digest, []string{"sha256:abc"}) {
+		t.Errorf("Wrong tags or digest: %v %v", image.Tag, image.Digest)
+	}
+
+	if image.ImageSizeBytes != "1000" || image.TimeUploadedMs != "1643814245123" || image.Repo != "registry.example.com/my-group/sub/app" {
+		t.Errorf("Wrong image data %+v", image)
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	client, registry := newTestClient(t, false)
+
+	if _, isBulkDeleter := client.(cr.BulkDeleter); isBulkDeleter {
+		t.Error("The client should not bulk delete unless asked to")
+	}
+
+	err := client.DeleteImage("my-group/sub/app", cr.ContainerImage{Tag: []string{"main", "latest"}}, false)
+
+	if err != nil {
+		t.Error("Delete should not fail")
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{
+		"/api/v4/projects/1/registry/repositories/10/tags/main",
+		"/api/v4/projects/1/registry/repositories/10/tags/latest",
+	}) {
+		t.Errorf("Wrong deletions %v", registry.Deleted())
+	}
+}
+
+func TestBulkDeleteImages(t *testing.T) {
+	client, registry := newTestClient(t, true)
+
+	bulkDeleter, isBulkDeleter := client.(cr.BulkDeleter)
+
+	if !isBulkDeleter {
+		t.Fatal("The client should bulk delete")
+	}
+
+	if capabilities := client.Capabilities(); !capabilities.BulkDelete || !capabilities.DeleteTagsOnly || capabilities.DeleteByDigest {
+		t.Errorf("Wrong capabilities %+v", capabilities)
+	}
+
+	deletedCount, scheduledCount, err := bulkDeleter.DeleteImages("my-group/sub/app", []cr.ContainerImage{
+		{Tag: []string{"main", "latest"}},
+		{Tag: []string{"feature-x.1"}},
+	}, false)
+
+	if err != nil || deletedCount != 0 || scheduledCount != 2 {
+		t.Errorf("Both images should be scheduled for deletion, as gitlab deletes them asynchronously, got %v deleted and %v scheduled", deletedCount, scheduledCount)
+	}
+
+	deletions := registry.Deletions()
+
+	if len(deletions) != 2 || deletions[0].Path != "/api/v4/projects/1/registry/repositories/10/tags/latest" || deletions[1].Path != "/api/v4/projects/1/registry/repositories/10/tags" {
+		t.Fatalf("The latest tag should be deleted on its own and the rest in bulk: %v", deletions)
+	}
+
+	payload := BulkDeleteTagsDTO{}
+	_ = json.Unmarshal([]byte(deletions[1].Payload), &payload)
+	nameRegex := regexp.MustCompile(payload.NameRegexDelete)
+
+	for tag, shouldMatch := range map[string]bool{"main": true, "feature-x.1": true, "feature-x11": false, "main-2": false, "latest": false} {
+		if nameRegex.MatchString(tag) != shouldMatch {
+			t.Errorf("Tag %v matching the bulk deletion regex should be %v", tag, shouldMatch)
+		}
+	}
+}
+
+func TestBulkDeleteImagesFailure(t *testing.T) {
+	client, registry := newTestClient(t, true)
+	registry.Handle("DELETE", "/api/v4/projects/1/registry/repositories/11/tags", registrytest.Status(http.StatusBadRequest, `{"message":"This request has already been made. You can run this at most once an hour for a given container repository"}`))
+
+	deletedCount, scheduledCount, err := client.(cr.BulkDeleter).DeleteImages("my-group/sub/app/worker", []cr.ContainerImage{{Tag: []string{"v1"}}}, true)
+
+	if err == nil || deletedCount != 0 || scheduledCount != 0 {
+		t.Errorf("A rejected bulk deletion should be reported, got %v deleted, %v scheduled and error %v", deletedCount, scheduledCount, err)
+	}
+}
+
+func TestBulkDeleteImagesRegexCap(t *testing.T) {
+	client, registry := newTestClient(t, true)
+
+	defer func(previousLength int) { maxNameRegexDeleteLength = previousLength }(maxNameRegexDeleteLength)
+	maxNameRegexDeleteLength = 20
+
+	_, scheduledCount, err := client.(cr.BulkDeleter).DeleteImages("my-group/sub/app", []cr.ContainerImage{
+		{Tag: []string{"v1"}},
+		{Tag: []string{"v2"}},
+		{Tag: []string{"release-candidate"}},
+		{Tag: []string{"v3"}},
+	}, true)
+
+	if err != nil || scheduledCount != 3 {
+		t.Errorf("The images whose tags do not fit in the regex should be left for a later run, got %v scheduled (%v)", scheduledCount, err)
+	}
+
+	payload := BulkDeleteTagsDTO{}
+	_ = json.Unmarshal([]byte(registry.Deletions()[0].Payload), &payload)
+
+	if payload.NameRegexDelete != "^(?:v1|v2|v3)$" {
+		t.Errorf("Wrong bulk deletion regex %v", payload.NameRegexDelete)
+	}
+}
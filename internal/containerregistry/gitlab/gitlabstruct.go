@@ -0,0 +1,58 @@
+package gitlab
+
+import (
+	"sync"
+
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+)
+
+// RegistryClient is a GitLab container registry client that walks all the projects of a group
+type RegistryClient struct {
+	httpClient myhttp.Client
+	group      string
+	// walkOnce guards the walk of the group's projects, which is needed both for listing and for deleting
+	walkOnce sync.Once
+	// repositories are the registry repositories of the group by their path, e.g. group/project/image
+	repositories map[string]RepositoryDTO
+	// repositoryPaths keeps the order that the repositories were found in
+	repositoryPaths []string
+}
+
+// BulkRegistryClient is a GitLab client that deletes the tags of each repository through the bulk tag deletion endpoint
+type BulkRegistryClient struct {
+	*RegistryClient
+}
+
+// ProjectDTO is the DTO of a GitLab project
+type ProjectDTO struct {
+	ID                int64
+	PathWithNamespace string `json:"path_with_namespace"`
+	// ContainerRegistryAccessLevel is one of disabled, private, enabled
+	ContainerRegistryAccessLevel string `json:"container_registry_access_level"`
+}
+
+// RepositoryDTO is the DTO of a registry repository of a project
+type RepositoryDTO struct {
+	ID        int64
+	Name      string
+	Path      string
+	ProjectID int64 `json:"project_id"`
+	// Location is the full name of the image, e.g. registry.gitlab.com/group/project/image
+	Location string
+}
+
+// TagDTO is the DTO of a registry repository tag; the details are only returned when fetching a single tag
+type TagDTO struct {
+	Name      string
+	Path      string
+	Location  string
+	Digest    string
+	Revision  string
+	CreatedAt string `json:"created_at"`
+	TotalSize int64  `json:"total_size"`
+}
+
+// BulkDeleteTagsDTO is the Data Transfer Object for the bulk tag deletion api call
+type BulkDeleteTagsDTO struct {
+	NameRegexDelete string `json:"name_regex_delete"`
+}
@@ -0,0 +1,255 @@
+package harbor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+	log "github.com/sirupsen/logrus"
+)
+
+// Login sets the basic auth credentials that are used on every request; robot accounts work as well
+func (client *RegistryClient) Login(username string, password string) error {
+	client.httpClient.InjectAuthInRequest = func(req *http.Request) {
+		req.SetBasicAuth(username, password)
+	}
+
+	return nil
+}
+
+// repositoryPath returns the api path of a repository given a repository link, e.g. library/team/nginx
+func repositoryPath(repositoryLink string) string {
+	project, repository, _ := strings.Cut(repositoryLink, "/")
+
+	// harbor needs the slashes of the repository name to be encoded twice
+	return "/api/v2.0/projects/" + url.PathEscape(project) + "/repositories/" + url.PathEscape(url.PathEscape(repository))
+}
+
+// artifactsPath returns the api path of the artifacts of a repository given a repository link
+func artifactsPath(repositoryLink string) string {
+	return repositoryPath(repositoryLink) + "/artifacts"
+}
+
+func (client *RegistryClient) getAll(path string, handlePage func(bodyBytes []byte) error) {
+	next := path
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		err = handlePage(bodyBytes)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		next = myhttp.NextLink(headers)
+	}
+}
+
+func immutableTagsOf(artifact ArtifactDTO) []string {
+	immutableTags := []string{}
+
+	for _, tag := range artifact.Tags {
+		if tag.Immutable {
+			immutableTags = append(immutableTags, tag.Name)
+		}
+	}
+
+	return immutableTags
+}
+
+// Capabilities returns what the client can do; Harbor deletes artifacts by digest, records when they were last pulled and deletes whole repositories
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest:   true,
+		LastPulled:       true,
+		DeleteRepository: true,
+	}
+}
+
+// DeleteImage deletes an artifact along with all its tags; artifacts that became immutable since the plan was made are skipped, while a refusal for lack of permissions, e.g. of a robot account without delete rights, is a failure
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	artifactPath := artifactsPath(imageRepo) + "/" + image.Digest[0]
+
+	// the refusals are answered at once, as retrying them does not change the answer
+	err := client.httpClient.DeleteRequestAnsweringTo(artifactPath, []int{http.StatusNotFound, http.StatusForbidden, http.StatusPreconditionFailed}, true, silentErrors)
+
+	statusErr := &myhttp.StatusError{}
+
+	if !errors.As(err, &statusErr) {
+		return err
+	}
+
+	switch statusErr.StatusCode {
+	case http.StatusNotFound:
+		// already deleted, e.g. by another run or by harbor's own retention policy
+		return nil
+	case http.StatusPreconditionFailed:
+		if !silentErrors {
+			log.Warnf("Skipping %v@%v, it is protected by an immutable tag rule: %v", imageRepo, image.Digest[0], statusErr.Body)
+		}
+
+		return cr.ErrImageProtected
+	}
+
+	if !silentErrors {
+		log.Errorf("Could not delete %v@%v, harbor forbids it: %v", imageRepo, image.Digest[0], statusErr.Body)
+	}
+
+	return fmt.Errorf("deleting %v@%v is forbidden: %w", imageRepo, image.Digest[0], statusErr)
+}
+
+// DeleteRepository deletes a repository along with everything that is left in it
+func (client *RegistryClient) DeleteRepository(repositoryLink string, silentErrors bool) error {
+	return client.httpClient.DeleteRequestTo(repositoryPath(repositoryLink), true, silentErrors)
+}
+
+// GetAllRepos returns all the repositories of the project, or of all the projects if no project is specified
+func (client *RegistryClient) GetAllRepos() []string {
+	projects := []string{}
+
+	if client.project != "" {
+		projects = append(projects, client.project)
+	} else {
+		client.getAll("/api/v2.0/projects?page_size=100", func(bodyBytes []byte) error {
+			projectsResp := []ProjectDTO{}
+			err := json.Unmarshal(bodyBytes, &projectsResp)
+
+			for _, project := range projectsResp {
+				projects = append(projects, project.Name)
+			}
+
+			return err
+		})
+	}
+
+	repositories := []string{}
+
+	for _, project := range projects {
+		client.getAll("/api/v2.0/projects/"+url.PathEscape(project)+"/repositories?page_size=100", func(bodyBytes []byte) error {
+			repositoriesResp := []RepositoryDTO{}
+			err := json.Unmarshal(bodyBytes, &repositoriesResp)
+
+			for _, repository := range repositoriesResp {
+				repositories = append(repositories, repository.Name)
+			}
+
+			return err
+		})
+	}
+
+	return repositories
+}
+
+// msOf converts a harbor timestamp to unix milliseconds; the zero time (e.g. an artifact that was never pulled) gives an empty string
+func msOf(timestamp string) (string, error) {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+
+	if err != nil {
+		return "", err
+	}
+
+	if t.Year() <= 1 {
+		return "", nil
+	}
+
+	return strconv.FormatInt(t.UTC().UnixMilli(), 10), nil
+}
+
+// ParseRepo parses all the artifacts of a repository
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	client.getAll(artifactsPath(repositoryLink)+"?with_tag=true&with_immutable_status=true&page_size=100", func(bodyBytes []byte) error {
+		artifactsResp := []ArtifactDTO{}
+		err := json.Unmarshal(bodyBytes, &artifactsResp)
+
+		for _, artifact := range artifactsResp {
+			repoImage := cr.ContainerImage{
+				Tag:            []string{},
+				Digest:         []string{artifact.Digest},
+				MediaType:      artifact.ManifestMediaType,
+				ImageSizeBytes: strconv.FormatInt(artifact.Size, 10),
+				Repo:           client.hostname + "/" + repositoryLink,
+			}
+
+			for _, tag := range artifact.Tags {
+				repoImage.Tag = append(repoImage.Tag, tag.Name)
+			}
+
+			pushedMs, err := msOf(artifact.PushTime)
+
+			if err != nil || pushedMs == "" {
+				// without an upload time the age and the number rules cannot judge the artifact, so it is left alone
+				log.Errorf("Artifact %v of %v contains invalid push time: %v, skipping it", artifact.Digest, repositoryLink, artifact.PushTime)
+				continue
+			}
+
+			repoImage.TimeCreatedMs = pushedMs
+			repoImage.TimeUploadedMs = pushedMs
+
+			pulledMs, err := msOf(artifact.PullTime)
+
+			if err == nil {
+				repoImage.TimeLastPulledMs = pulledMs
+			} else {
+				log.Errorf("Artifact %v of %v contains invalid pull time: %v", artifact.Digest, repositoryLink, artifact.PullTime)
+			}
+
+			if immutableTags := immutableTagsOf(artifact); len(immutableTags) > 0 {
+				// harbor refuses to delete artifacts with immutable tags, no matter what the filters decide
+				repoImage.KeptData = keepreasons.KeptData{
+					Reason:   keepreasons.Protected,
+					Metadata: fmt.Sprintf("immutable tags %v", strings.Join(immutableTags, ",")),
+				}
+			}
+
+			repository.Images = append(repository.Images, repoImage)
+		}
+
+		return err
+	})
+
+	return repository
+}
+
+// NewHarborClientParams are the required parameters to build a Harbor client
+type NewHarborClientParams struct {
+	// Host is the harbor host, e.g. harbor.example.com or http://localhost:8080; https is assumed when no scheme is specified
+	Host string
+	// Project limits the client to a single project; empty means all the projects that the user can see
+	Project string
+}
+
+// NewHarborClient builds a new Harbor client
+func NewHarborClient(params NewHarborClientParams) cr.Client {
+	baseURL := strings.TrimSuffix(params.Host, "/")
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "https://" + baseURL
+	}
+
+	return &RegistryClient{
+		httpClient: myhttp.NewClient(myhttp.NewClientParams{
+			// the api paths are absolute because the pagination links of harbor are relative to the host
+			BaseURL:             baseURL,
+			InjectAuthInRequest: nil, // we set this once we have logged in
+		}),
+		hostname: strings.TrimPrefix(strings.TrimPrefix(baseURL, "https://"), "http://"),
+		project:  params.Project,
+	}
+}
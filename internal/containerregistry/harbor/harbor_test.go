@@ -0,0 +1,163 @@
+package harbor
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+var artifacts = []ArtifactDTO{
+	{
+		Digest:            "sha256:abc",
+		Size:              1000,
+		ManifestMediaType: "application/vnd.oci.image.manifest.v1+json",
+		PushTime:          "2022-02-02T15:04:05.123Z",
+		PullTime:          "2022-03-02T15:04:05.000Z",
+		Tags:              []TagDTO{{Name: "v1", Immutable: true}, {Name: "latest"}},
+	},
+	{
+		Digest:   "sha256:def",
+		Size:     500,
+		PushTime: "2022-01-02T15:04:05.000Z",
+		PullTime: "0001-01-01T00:00:00.000Z",
+	},
+}
+
+// newFakeAPI starts a local stand-in of the Harbor API with two projects
+func newFakeAPI(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, registrytest.BasicAuth("robot$cleaner", "secret"))
+
+	registry.Handle("GET", "/api/v2.0/projects", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") != "" {
+			_ = json.NewEncoder(w).Encode([]ProjectDTO{{ProjectID: 2, Name: "team"}})
+			return
+		}
+
+		w.Header().Set("Link", `</api/v2.0/projects?page=2&page_size=100>; rel="next"`)
+		_ = json.NewEncoder(w).Encode([]ProjectDTO{{ProjectID: 1, Name: "library"}})
+	})
+	registry.Handle("GET", "/api/v2.0/projects/library/repositories", registrytest.JSON([]RepositoryDTO{{Name: "library/nginx"}}))
+	registry.Handle("GET", "/api/v2.0/projects/team/repositories", registrytest.JSON([]RepositoryDTO{{Name: "team/backend/api"}}))
+	registry.Handle("GET", "/api/v2.0/projects/team/repositories/backend%252Fapi/artifacts", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("with_immutable_status") != "true" {
+			t.Error("The immutability of the tags should be requested")
+		}
+
+		_ = json.NewEncoder(w).Encode(artifacts)
+	})
+
+	return registry
+}
+
+func newTestClient(t *testing.T, project string) (cr.Client, *registrytest.Registry) {
+	registry := newFakeAPI(t)
+	client := NewHarborClient(NewHarborClientParams{
+		Host:    registry.URL,
+		Project: project,
+	})
+
+	err := client.Login("robot$cleaner", "secret")
+
+	if err != nil {
+		t.Error("Login should not fail")
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	client, _ := newTestClient(t, "")
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"library/nginx", "team/backend/api"}) {
+		t.Errorf("Wrong repos %v", client.GetAllRepos())
+	}
+
+	client, _ = newTestClient(t, "team")
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"team/backend/api"}) {
+		t.Errorf("Only the repos of the project should be returned, not %v", client.GetAllRepos())
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t, "")
+
+	repo := client.ParseRepo("team/backend/api")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("Exactly 2 images should be parsed, not %v", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"v1", "latest"}) || image.ImageSizeBytes != "1000" || image.Repo[len(image.Repo)-len("/team/backend/api"):] != "/team/backend/api" {
+		t.Errorf("Wrong image data %+v", image)
+	}
+
+	if image.TimeUploadedMs != "1643814245123" || image.TimeLastPulledMs != "1646233445000" {
+		t.Errorf("Wrong push or pull time %v %v", image.TimeUploadedMs, image.TimeLastPulledMs)
+	}
+
+	if image.KeptData.Reason != keepreasons.Protected || image.KeptData.Metadata != "immutable tags v1" {
+		t.Errorf("Image with immutable tags should be protected, got %+v", image.KeptData)
+	}
+
+	if repo.Images[1].TimeLastPulledMs != "" || repo.Images[1].KeptData.Reason != keepreasons.None || len(repo.Images[1].Tag) != 0 {
+		t.Errorf("Wrong untagged image data %+v", repo.Images[1])
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	client, registry := newTestClient(t, "")
+	artifactsPath := "/api/v2.0/projects/team/repositories/backend%252Fapi/artifacts/"
+
+	registry.Handle("DELETE", artifactsPath+"sha256:abc", registrytest.Status(http.StatusPreconditionFailed, `{"errors":[{"code":"PRECONDITION","message":"the operation is prohibited by the immutable rule"}]}`))
+	registry.Handle("DELETE", artifactsPath+"sha256:gone", registrytest.Status(http.StatusNotFound, ""))
+	registry.Handle("DELETE", artifactsPath+"sha256:forbidden", registrytest.Status(http.StatusForbidden, `{"errors":[{"code":"FORBIDDEN","message":"forbidden"}]}`))
+
+	err := client.DeleteImage("team/backend/api", cr.ContainerImage{Digest: []string{"sha256:abc"}}, true)
+
+	if err != cr.ErrImageProtected {
+		t.Errorf("Deleting an immutable artifact should be skipped, got %v", err)
+	}
+
+	err = client.DeleteImage("team/backend/api", cr.ContainerImage{Digest: []string{"sha256:def"}}, false)
+
+	if err != nil {
+		t.Error("Delete should not fail")
+	}
+
+	err = client.DeleteImage("team/backend/api", cr.ContainerImage{Digest: []string{"sha256:gone"}}, true)
+
+	if err != nil {
+		t.Errorf("An artifact that is already gone should count as deleted, got %v", err)
+	}
+
+	err = client.DeleteImage("team/backend/api", cr.ContainerImage{Digest: []string{"sha256:forbidden"}}, true)
+
+	if err == nil || errors.Is(err, cr.ErrImageProtected) {
+		t.Errorf("Lacking the permission to delete should be a failure, not a skip, got %v", err)
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{artifactsPath + "sha256:def"}) {
+		t.Errorf("Wrong deleted artifacts %v", registry.Deleted())
+	}
+}
+
+func TestDeleteRepository(t *testing.T) {
+	client, registry := newTestClient(t, "")
+
+	if err := client.(cr.RepositoryDeleter).DeleteRepository("team/backend/api", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{"/api/v2.0/projects/team/repositories/backend%252Fapi"}) {
+		t.Errorf("Wrong deleted repositories %v", registry.Deleted())
+	}
+}
@@ -0,0 +1,43 @@
+package harbor
+
+import myhttp "github.com/hytromo/faulty-crane/internal/http"
+
+// RegistryClient is a Harbor client, it uses the Harbor api instead of the docker registry api in order to get the pull times and the immutability of the artifacts
+type RegistryClient struct {
+	httpClient myhttp.Client
+	// hostname is the registry host without the scheme, e.g. harbor.example.com
+	hostname string
+	// project limits the client to a single project; empty means all the projects that the user can see
+	project string
+}
+
+// ProjectDTO is the DTO of a Harbor project
+type ProjectDTO struct {
+	ProjectID int64 `json:"project_id"`
+	Name      string
+}
+
+// RepositoryDTO is the DTO of a Harbor repository
+type RepositoryDTO struct {
+	// Name is the full name of the repository, including its project, e.g. library/nginx
+	Name string
+}
+
+// TagDTO is the DTO of a tag of a Harbor artifact
+type TagDTO struct {
+	Name string
+	// Immutable is true when an immutability rule of the project matches the tag; such artifacts cannot be deleted
+	Immutable bool
+}
+
+// ArtifactDTO is the DTO of a Harbor artifact, e.g. an image or an image index
+type ArtifactDTO struct {
+	Digest            string
+	Size              int64
+	MediaType         string `json:"media_type"`
+	ManifestMediaType string `json:"manifest_media_type"`
+	PushTime          string `json:"push_time"`
+	// PullTime is the zero time when the artifact has never been pulled
+	PullTime string `json:"pull_time"`
+	Tags     []TagDTO
+}
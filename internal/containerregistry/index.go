@@ -0,0 +1,57 @@
+package containerregistry
+
+// IndexMediaTypes are the media types of the manifests that reference other manifests instead of layers
+var IndexMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// ManifestMediaTypes are all the manifest formats that we understand, sent in the Accept header so that the registry does not downgrade the manifests
+var ManifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// IsIndexMediaType returns if the media type is the one of an image index / manifest list
+func IsIndexMediaType(mediaType string) bool {
+	for _, indexMediaType := range IndexMediaTypes {
+		if mediaType == indexMediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsIndex returns if the image is an image index / manifest list
+func (image ContainerImage) IsIndex() bool {
+	return len(image.Children) > 0 || IsIndexMediaType(image.MediaType)
+}
+
+// IsStandalone returns if the image stands on its own, e.g. it is tagged or it is not referenced by any image index; the untagged platform manifests of a multi-arch image and the referrers of an image, like its signatures, are not standalone
+func (image ContainerImage) IsStandalone() bool {
+	return !image.IsReferrer() && (len(image.Tag) > 0 || len(image.Parents) == 0)
+}
+
+// LinkIndexChildren sets the parents of the images of a repository, based on the children of its image indexes
+func LinkIndexChildren(images []ContainerImage) {
+	imageIndexOfDigest := map[string]int{}
+
+	for i, image := range images {
+		images[i].Parents = nil
+
+		for _, digest := range image.Digest {
+			imageIndexOfDigest[digest] = i
+		}
+	}
+
+	for _, image := range images {
+		for _, childDigest := range image.Children {
+			if childIndex, exists := imageIndexOfDigest[childDigest]; exists {
+				images[childIndex].Parents = append(images[childIndex].Parents, image.Digest[0])
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+)
+
+// resourceOf returns the resource that a registry api path refers to, so that the tokens can be cached per resource
+func resourceOf(path string) string {
+	path = strings.TrimPrefix(path, "/v2/")
+
+	if strings.HasPrefix(path, "_catalog") {
+		return "registry:catalog"
+	}
+
+	for _, separator := range []string{"/tags/", "/manifests/", "/blobs/", "/referrers/"} {
+		if index := strings.LastIndex(path, separator); index != -1 {
+			return "repository:" + path[:index]
+		}
+	}
+
+	return path
+}
+
+// scopeOf returns the resource and the action that a request needs, so that e.g. the pull and the delete tokens of the same repository do not replace each other
+func scopeOf(req *http.Request) string {
+	action := "pull"
+
+	switch req.Method {
+	case "DELETE":
+		action = "delete"
+	case "POST", "PUT", "PATCH":
+		action = "push"
+	}
+
+	return resourceOf(req.URL.Path) + ":" + action
+}
+
+func (client *RegistryClient) injectAuth(req *http.Request) {
+	client.auth.mutex.RLock()
+	defer client.auth.mutex.RUnlock()
+
+	if token, exists := client.auth.tokens[scopeOf(req)]; exists {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		return
+	}
+
+	if client.auth.useBasicAuth {
+		req.SetBasicAuth(client.username, client.password)
+	}
+}
+
+// refreshAuth follows the WWW-Authenticate challenge of a 401 response, so that the next try of the request carries the right credentials
+func (client *RegistryClient) refreshAuth(resp *http.Response) error {
+	parsedChallenge := myhttp.ParseChallenge(resp.Header.Get("WWW-Authenticate"))
+
+	switch parsedChallenge.Scheme {
+	case "basic":
+		if client.username == "" {
+			return errors.New("the registry requires a username and a password")
+		}
+
+		client.auth.mutex.Lock()
+		client.auth.useBasicAuth = true
+		client.auth.mutex.Unlock()
+
+		return nil
+	case "bearer":
+		token, err := client.fetchToken(parsedChallenge)
+
+		if err != nil {
+			return err
+		}
+
+		client.auth.mutex.Lock()
+		client.auth.tokens[scopeOf(resp.Request)] = token
+		client.auth.mutex.Unlock()
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported authentication challenge '%v'", resp.Header.Get("WWW-Authenticate"))
+}
+
+func (client *RegistryClient) fetchToken(parsedChallenge myhttp.Challenge) (string, error) {
+	realm := parsedChallenge.Params["realm"]
+
+	if realm == "" {
+		return "", errors.New("bearer challenge without a realm")
+	}
+
+	query := url.Values{}
+	if service := parsedChallenge.Params["service"]; service != "" {
+		query.Set("service", service)
+	}
+
+	for _, scope := range strings.Fields(parsedChallenge.Params["scope"]) {
+		query.Add("scope", scope)
+	}
+
+	tokenURL := realm
+	if len(query) > 0 {
+		separator := "?"
+		if strings.Contains(realm, "?") {
+			separator = "&"
+		}
+		tokenURL = realm + separator + query.Encode()
+	}
+
+	tokenClient := myhttp.NewClient(myhttp.NewClientParams{
+		InjectAuthInRequest: func(req *http.Request) {
+			if client.username != "" {
+				req.SetBasicAuth(client.username, client.password)
+			}
+		},
+	})
+
+	bodyBytes, err := tokenClient.GetRequestTo(tokenURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	tokenResp := TokenDTO{}
+	err = json.Unmarshal(bodyBytes, &tokenResp)
+
+	if err != nil {
+		return "", fmt.Errorf("invalid token response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+
+	if tokenResp.AccessToken != "" {
+		return tokenResp.AccessToken, nil
+	}
+
+	return "", errors.New("the token endpoint did not return any token")
+}
@@ -0,0 +1,343 @@
+package oci
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	log "github.com/sirupsen/logrus"
+)
+
+func manifestHeaders() http.Header {
+	return http.Header{
+		"Accept": []string{strings.Join(cr.ManifestMediaTypes, ", ")},
+	}
+}
+
+// Login stores the credentials; they are used only when the registry challenges us for them
+func (client *RegistryClient) Login(username string, password string) error {
+	client.username = username
+	client.password = password
+
+	return nil
+}
+
+// Capabilities returns what the client can do; OCI registries delete manifests by digest and link image indexes to their platform manifests
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteByDigest: true,
+		ManifestLists:  true,
+		FetchManifests: true,
+	}
+}
+
+// GetManifest returns the manifest of an image by its digest
+func (client *RegistryClient) GetManifest(repositoryLink string, digest string) ([]byte, error) {
+	bodyBytes, _, err := client.httpClient.GetRequestWithHeadersTo("/v2/"+repositoryLink+"/manifests/"+digest, manifestHeaders())
+
+	return bodyBytes, err
+}
+
+// GetBlob returns a blob of a repository by its digest
+func (client *RegistryClient) GetBlob(repositoryLink string, digest string) ([]byte, error) {
+	return client.httpClient.GetRequestTo("/v2/" + repositoryLink + "/blobs/" + digest)
+}
+
+// DeleteImage deletes an image by its digest; this removes all the tags that point to it as well
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	var err error
+
+	for _, digest := range image.Digest {
+		err = client.httpClient.DeleteRequestTo("/v2/"+imageRepo+"/manifests/"+digest, true, silentErrors)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllRepos parses all the repos of the registry catalog
+func (client *RegistryClient) GetAllRepos() []string {
+	repositories := []string{}
+	next := "/v2/_catalog?n=100" // initial request
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		catalogResp := CatalogDTO{}
+		err = json.Unmarshal(bodyBytes, &catalogResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		repositories = append(repositories, catalogResp.Repositories...)
+		next = myhttp.NextLink(headers)
+	}
+
+	return repositories
+}
+
+func (client *RegistryClient) getAllTags(repositoryLink string) []string {
+	tags := []string{}
+	next := "/v2/" + repositoryLink + "/tags/list?n=100" // initial request
+
+	for next != "" {
+		bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo(next, nil)
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		tagsResp := TagsListDTO{}
+		err = json.Unmarshal(bodyBytes, &tagsResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		tags = append(tags, tagsResp.Tags...)
+		next = myhttp.NextLink(headers)
+	}
+
+	return tags
+}
+
+// resolveDigest finds the digest that a tag points to
+func (client *RegistryClient) resolveDigest(repositoryLink string, tag string) string {
+	headers, err := client.httpClient.HeadRequestTo("/v2/"+repositoryLink+"/manifests/"+tag, manifestHeaders())
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	if digest := headers.Get("Docker-Content-Digest"); digest != "" {
+		return digest
+	}
+
+	// not all registries return the digest header, in this case we can calculate the digest ourselves
+	bodyBytes, _, err := client.httpClient.GetRequestWithHeadersTo("/v2/"+repositoryLink+"/manifests/"+tag, manifestHeaders())
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	hash := sha256.Sum256(bodyBytes)
+
+	return "sha256:" + hex.EncodeToString(hash[:])
+}
+
+// newestMs returns the newest of two unix millisecond times; unknown times lose
+func newestMs(msA string, msB string) string {
+	parsedA, errA := strconv.ParseInt(msA, 10, 64)
+	parsedB, errB := strconv.ParseInt(msB, 10, 64)
+
+	if errA != nil || (errB == nil && parsedB > parsedA) {
+		return msB
+	}
+
+	return msA
+}
+
+// parseImage fetches the manifest and the image configuration in order to find the size and the creation time of an image
+func (client *RegistryClient) parseImage(repositoryLink string, digest string, tags []string) cr.ContainerImage {
+	image := cr.ContainerImage{
+		Tag:    tags,
+		Digest: []string{digest},
+		Repo:   client.hostname + "/" + repositoryLink,
+	}
+
+	bodyBytes, headers, err := client.httpClient.GetRequestWithHeadersTo("/v2/"+repositoryLink+"/manifests/"+digest, manifestHeaders())
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	manifest := ManifestDTO{}
+	err = json.Unmarshal(bodyBytes, &manifest)
+
+	if err != nil {
+		log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+	}
+
+	image.MediaType = manifest.MediaType
+	if image.MediaType == "" {
+		image.MediaType = headers.Get("Content-Type")
+	}
+
+	if manifest.Subject != nil {
+		image.Subject = manifest.Subject.Digest
+	}
+
+	// for image indexes we only know the size of the referenced manifests, not the size of their layers
+	totalImageSize := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		totalImageSize += layer.Size
+		image.Layers = append(image.Layers, cr.Layer{Digest: layer.Digest, Size: layer.Size})
+	}
+	for _, childManifest := range manifest.Manifests {
+		totalImageSize += childManifest.Size
+		image.Children = append(image.Children, childManifest.Digest)
+	}
+	image.ImageSizeBytes = strconv.FormatInt(totalImageSize, 10)
+
+	if manifest.Config.Digest == "" {
+		// image indexes do not have a configuration, so they are as old as their newest platform manifest; the time stays unknown when none of them has a creation time
+		for _, childManifest := range manifest.Manifests {
+			child := client.parseImage(repositoryLink, childManifest.Digest, []string{})
+
+			if newestMs(child.TimeUploadedMs, image.TimeUploadedMs) == child.TimeUploadedMs {
+				image.TimeCreatedMs = child.TimeCreatedMs
+				image.TimeUploadedMs = child.TimeUploadedMs
+			}
+		}
+
+		return image
+	}
+
+	configBytes, err := client.httpClient.GetRequestTo("/v2/" + repositoryLink + "/blobs/" + manifest.Config.Digest)
+
+	if err != nil {
+		log.Fatalf("Error on api call: %v", err.Error())
+	}
+
+	imageConfig := ImageConfigDTO{}
+	err = json.Unmarshal(configBytes, &imageConfig)
+
+	if err != nil {
+		// not an image configuration, e.g. an artifact with a custom config type
+		return image
+	}
+
+	created, err := time.Parse(time.RFC3339Nano, imageConfig.Created)
+
+	if err == nil {
+		createdMs := strconv.FormatInt(created.UTC().UnixMilli(), 10)
+		image.TimeCreatedMs = createdMs
+		image.TimeUploadedMs = createdMs
+	}
+
+	return image
+}
+
+// ParseRepo parses a specific repo
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	// multiple tags can point to the same manifest, so we group them by digest, keeping the order of the registry
+	digests := []string{}
+	tagsOfDigest := map[string][]string{}
+
+	for _, tag := range client.getAllTags(repositoryLink) {
+		digest := client.resolveDigest(repositoryLink, tag)
+
+		if _, exists := tagsOfDigest[digest]; !exists {
+			digests = append(digests, digest)
+		}
+
+		tagsOfDigest[digest] = append(tagsOfDigest[digest], tag)
+	}
+
+	for _, digest := range digests {
+		repository.Images = append(repository.Images, client.parseImage(repositoryLink, digest, tagsOfDigest[digest]))
+	}
+
+	cr.LinkIndexChildren(repository.Images)
+
+	client.addReferrers(repositoryLink, &repository)
+
+	return repository
+}
+
+// addReferrers adds the referrers of the images of a repository, e.g. their signatures and sboms, as they are usually untagged and cannot be found through the tags; referrers of referrers are followed as well
+func (client *RegistryClient) addReferrers(repositoryLink string, repository *cr.Repository) {
+	knownDigests := map[string]bool{}
+
+	for _, image := range repository.Images {
+		knownDigests[image.Digest[0]] = true
+	}
+
+	// the images that are appended are looked into as well
+	for i := 0; i < len(repository.Images); i++ {
+		subject := repository.Images[i].Digest[0]
+
+		bodyBytes, _, err := client.httpClient.GetRequestIfExistsTo("/v2/"+repositoryLink+"/referrers/"+subject, http.Header{
+			"Accept": []string{"application/vnd.oci.image.index.v1+json"},
+		})
+
+		if errors.Is(err, myhttp.ErrNotFound) {
+			// the registry does not support the referrers api, the referrers that follow the tag schema are found through their tags anyway
+			return
+		}
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		referrers := ManifestDTO{}
+		err = json.Unmarshal(bodyBytes, &referrers)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, referrer := range referrers.Manifests {
+			if knownDigests[referrer.Digest] {
+				continue
+			}
+
+			knownDigests[referrer.Digest] = true
+
+			referrerImage := client.parseImage(repositoryLink, referrer.Digest, nil)
+			if referrerImage.Subject == "" {
+				referrerImage.Subject = subject
+			}
+
+			repository.Images = append(repository.Images, referrerImage)
+		}
+	}
+}
+
+// NewOCIClientParams are the required parameters to build an OCI distribution client
+type NewOCIClientParams struct {
+	// Host is the registry host, e.g. registry.example.com:5000; https is assumed when no scheme is specified
+	Host string
+}
+
+// NewOCIClient builds a new client for a registry that implements the OCI distribution spec
+func NewOCIClient(params NewOCIClientParams) cr.Client {
+	baseURL := strings.TrimSuffix(params.Host, "/")
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "https://" + baseURL
+	}
+
+	client := &RegistryClient{
+		hostname: strings.TrimPrefix(strings.TrimPrefix(baseURL, "https://"), "http://"),
+		auth: &authState{
+			tokens: map[string]string{},
+		},
+	}
+
+	client.httpClient = myhttp.NewClient(myhttp.NewClientParams{
+		BaseURL:             baseURL,
+		InjectAuthInRequest: client.injectAuth,
+		RefreshAuth:         client.refreshAuth,
+	})
+
+	return client
+}
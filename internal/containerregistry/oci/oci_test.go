@@ -0,0 +1,251 @@
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/enrichment"
+)
+
+const (
+	digestA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	digestB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	configA = "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+	// digestArtifact is an untagged platform manifest of digestB without a creation time
+	digestArtifact = "sha256:eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"
+	// digestSignature is an untagged signature of digestA
+	digestSignature = "sha256:dddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddddd"
+)
+
+// fakeRegistry is a minimal registry that requires a bearer token per scope, like docker's token authentication
+type fakeRegistry struct {
+	mutex   sync.Mutex
+	deleted []string
+}
+
+func (registry *fakeRegistry) handler(serverURL *string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"token": "token-for-" + r.URL.Query().Get("scope"),
+		})
+	})
+
+	mux.HandleFunc("/v2/", func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+		scope := ""
+		if path == "_catalog" {
+			scope = "registry:catalog:*"
+		} else {
+			action := "pull"
+			if r.Method == "DELETE" {
+				action = "delete"
+			}
+			scope = fmt.Sprintf("repository:%v:%v", path[:strings.Index(path, "/")], action)
+		}
+
+		if r.Header.Get("Authorization") != "Bearer token-for-"+scope {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%v/token",service="fake",scope="%v"`, *serverURL, scope))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case path == "_catalog" && r.URL.Query().Get("last") == "":
+			w.Header().Set("Link", `</v2/_catalog?last=app&n=100>; rel="next"`)
+			_ = json.NewEncoder(w).Encode(CatalogDTO{Repositories: []string{"app"}})
+		case path == "_catalog":
+			_ = json.NewEncoder(w).Encode(CatalogDTO{Repositories: []string{"other"}})
+		case path == "app/tags/list":
+			_ = json.NewEncoder(w).Encode(TagsListDTO{Name: "app", Tags: []string{"v1", "latest", "v0"}})
+		case path == "app/manifests/v1" || path == "app/manifests/latest":
+			w.Header().Set("Docker-Content-Digest", digestA)
+		case path == "app/manifests/v0":
+			w.Header().Set("Docker-Content-Digest", digestB)
+		case path == "app/manifests/"+digestA && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ManifestDTO{
+				MediaType: "application/vnd.oci.image.manifest.v1+json",
+				Config:    DescriptorDTO{MediaType: "application/vnd.oci.image.config.v1+json", Digest: configA, Size: 10},
+				Layers:    []DescriptorDTO{{Size: 100}, {Size: 200}},
+			})
+		case path == "app/manifests/"+digestB && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ManifestDTO{
+				MediaType: "application/vnd.oci.image.index.v1+json",
+				Manifests: []DescriptorDTO{{Digest: digestA, Size: 5}, {Digest: digestArtifact, Size: 6}},
+			})
+		case path == "app/manifests/"+digestArtifact && r.Method == "GET":
+			_ = json.NewEncoder(w).Encode(ManifestDTO{MediaType: "application/vnd.oci.image.manifest.v1+json"})
+		case path == "app/blobs/"+configA:
+			_, _ = w.Write([]byte(`{"created": "2022-02-02T15:04:05.123Z", "os": "linux", "architecture": "arm64", "config": {"Labels": {"org.opencontainers.image.revision": "abc"}}}`))
+		case path == "signed/tags/list":
+			_ = json.NewEncoder(w).Encode(TagsListDTO{Name: "signed", Tags: []string{"v1"}})
+		case path == "signed/manifests/v1":
+			w.Header().Set("Docker-Content-Digest", digestA)
+		case path == "signed/manifests/"+digestA:
+			_ = json.NewEncoder(w).Encode(ManifestDTO{MediaType: "application/vnd.oci.image.manifest.v1+json"})
+		case path == "signed/manifests/"+digestSignature:
+			_ = json.NewEncoder(w).Encode(ManifestDTO{
+				MediaType: "application/vnd.oci.image.manifest.v1+json",
+				Layers:    []DescriptorDTO{{Size: 1}},
+				Subject:   &DescriptorDTO{Digest: digestA},
+			})
+		case path == "signed/referrers/"+digestA:
+			_ = json.NewEncoder(w).Encode(ManifestDTO{Manifests: []DescriptorDTO{{Digest: digestSignature}}})
+		case strings.HasPrefix(path, "signed/referrers/"):
+			_ = json.NewEncoder(w).Encode(ManifestDTO{Manifests: []DescriptorDTO{}})
+		case strings.HasPrefix(path, "app/manifests/") && r.Method == "DELETE":
+			registry.mutex.Lock()
+			registry.deleted = append(registry.deleted, strings.TrimPrefix(path, "app/manifests/"))
+			registry.mutex.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+
+	return mux
+}
+
+func newTestClient(t *testing.T) (cr.Client, *fakeRegistry) {
+	registry := &fakeRegistry{}
+	serverURL := ""
+	server := httptest.NewServer(registry.handler(&serverURL))
+	serverURL = server.URL
+	t.Cleanup(server.Close)
+
+	client := NewOCIClient(NewOCIClientParams{Host: server.URL})
+	err := client.Login("user", "pass")
+
+	if err != nil {
+		t.Error("Login should not fail")
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"app", "other"}) {
+		t.Error("All the pages of the catalog should be fetched")
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	repo := client.ParseRepo("app")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("Tags pointing to the same digest should be grouped, got %v images", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"v1", "latest"}) || !reflect.DeepEqual(image.Digest, []string{digestA}) {
+		t.Errorf("Wrong tags or digest: %v %v", image.Tag, image.Digest)
+	}
+
+	if image.ImageSizeBytes != "310" {
+		t.Errorf("Wrong image size %v", image.ImageSizeBytes)
+	}
+
+	if image.TimeUploadedMs != "1643814245123" {
+		t.Errorf("Wrong upload time %v", image.TimeUploadedMs)
+	}
+
+	if !strings.HasSuffix(image.Repo, "/app") || strings.Contains(image.Repo, "://") {
+		t.Errorf("Wrong repo %v", image.Repo)
+	}
+
+	index := repo.Images[1]
+
+	if index.MediaType != "application/vnd.oci.image.index.v1+json" || index.ImageSizeBytes != "11" || index.TimeUploadedMs != "1643814245123" {
+		t.Errorf("Wrong image index data, it should be as old as its newest platform manifest %+v", index)
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	client, registry := newTestClient(t)
+
+	err := client.DeleteImage("app", cr.ContainerImage{Tag: []string{"v0"}, Digest: []string{digestB}}, false)
+
+	if err != nil {
+		t.Error("Delete should not fail")
+	}
+
+	if !reflect.DeepEqual(registry.deleted, []string{digestB}) {
+		t.Errorf("The image should be deleted by digest, deleted %v", registry.deleted)
+	}
+}
+
+func TestResourceOf(t *testing.T) {
+	if resourceOf("/v2/team/app/manifests/latest") != "repository:team/app" || resourceOf("/v2/_catalog") != "registry:catalog" {
+		t.Error("Wrong resource")
+	}
+}
+
+func TestScopeOf(t *testing.T) {
+	pull, _ := http.NewRequest("GET", "http://registry/v2/team/app/manifests/latest", nil)
+	deletion, _ := http.NewRequest("DELETE", "http://registry/v2/team/app/manifests/latest", nil)
+
+	if scopeOf(pull) != "repository:team/app:pull" || scopeOf(deletion) != "repository:team/app:delete" {
+		t.Errorf("The pull and the delete tokens should be cached separately, got %v and %v", scopeOf(pull), scopeOf(deletion))
+	}
+}
+
+func TestReferrers(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	repo := client.ParseRepo("signed")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("The untagged signature should be found through the referrers api, got %v images", len(repo.Images))
+	}
+
+	signature := repo.Images[1]
+
+	if !reflect.DeepEqual(signature.Digest, []string{digestSignature}) || signature.Subject != digestA || len(signature.Tag) != 0 {
+		t.Errorf("Wrong signature %+v", signature)
+	}
+}
+
+func TestEnrichment(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	fetcher, isManifestFetcher := client.(cr.ManifestFetcher)
+
+	if !isManifestFetcher || !client.Capabilities().FetchManifests {
+		t.Fatal("The client should fetch manifests")
+	}
+
+	repo := enrichment.NewEnricher(fetcher).EnrichRepository(client.ParseRepo("app"))
+	image := repo.Images[0]
+
+	if image.Labels["org.opencontainers.image.revision"] != "abc" || image.ConfigCreatedMs != "1643814245123" {
+		t.Errorf("The labels and the creation time of the configuration should be fetched, got %+v", image)
+	}
+
+	if image.Platform == nil || *image.Platform != (cr.Platform{OS: "linux", Architecture: "arm64"}) {
+		t.Errorf("Wrong platform %+v", image.Platform)
+	}
+
+	if repo.Images[1].Platform != nil {
+		t.Error("Image indexes do not have a platform of their own")
+	}
+}
@@ -0,0 +1,65 @@
+package oci
+
+import (
+	"sync"
+
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+)
+
+// RegistryClient is a client for any registry that implements the OCI distribution spec (Harbor, registry:2 etc)
+type RegistryClient struct {
+	httpClient myhttp.Client
+	// hostname is the registry host without the scheme, e.g. registry.example.com:5000
+	hostname string
+	username string
+	password string
+	auth     *authState
+}
+
+// authState keeps the credentials that the registry asked for through its WWW-Authenticate challenges
+type authState struct {
+	mutex sync.RWMutex
+	// useBasicAuth is set when the registry challenges us with the basic scheme
+	useBasicAuth bool
+	// tokens are the bearer tokens per resource and action, e.g. "repository:team/app:pull" or "registry:catalog:pull"
+	tokens map[string]string
+}
+
+// TokenDTO is the Data Transfer Object for the token endpoint of a bearer challenge
+type TokenDTO struct {
+	Token       string
+	AccessToken string `json:"access_token"`
+}
+
+// CatalogDTO is the Data Transfer Object for the /v2/_catalog api call
+type CatalogDTO struct {
+	Repositories []string
+}
+
+// TagsListDTO is the Data Transfer Object for the /v2/<name>/tags/list api call
+type TagsListDTO struct {
+	Name string
+	Tags []string
+}
+
+// DescriptorDTO describes a blob or a manifest that is referenced by another manifest
+type DescriptorDTO struct {
+	MediaType string
+	Digest    string
+	Size      int64
+}
+
+// ManifestDTO is the Data Transfer Object of an image manifest or an image index / manifest list
+type ManifestDTO struct {
+	MediaType string
+	Config    DescriptorDTO
+	Layers    []DescriptorDTO
+	Manifests []DescriptorDTO
+	// Subject is the image that an artifact, e.g. a signature, refers to
+	Subject *DescriptorDTO
+}
+
+// ImageConfigDTO is the Data Transfer Object of an image configuration blob
+type ImageConfigDTO struct {
+	Created string
+}
@@ -0,0 +1,45 @@
+package containerregistry
+
+import "strings"
+
+// Path returns the path of the repository on its host, e.g. project/team/service for the link gcr.io/project/team/service
+func (repository Repository) Path() string {
+	if repository.Host == "" {
+		return repository.Link
+	}
+
+	return strings.TrimPrefix(repository.Link, repository.Host+"/")
+}
+
+// HasPathPrefix returns if the repository is the given path prefix or lies under it; the prefix can be given relative to the host or with the host included
+func (repository Repository) HasPathPrefix(prefix string) bool {
+	return HasPathPrefix(repository.Path(), prefix) || HasPathPrefix(repository.Link, prefix)
+}
+
+// CleanPathPrefix turns a path prefix like project/team-a/** into project/team-a
+func CleanPathPrefix(prefix string) string {
+	return strings.TrimRight(strings.TrimSuffix(prefix, "**"), "/")
+}
+
+// HasPathPrefix returns if the path is the given prefix or lies under it; project/team-a/** and project/team-a are the same prefix and both match project/team-a/service but not project/team-ab
+func HasPathPrefix(path string, prefix string) bool {
+	prefix = CleanPathPrefix(prefix)
+
+	if prefix == "" {
+		return true
+	}
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// PathLevels returns all the levels of the tree that a path lies under, from the root to the path itself, e.g. project, project/team, project/team/service
+func PathLevels(path string) []string {
+	segments := strings.Split(path, "/")
+	levels := make([]string, len(segments))
+
+	for i := range segments {
+		levels[i] = strings.Join(segments[:i+1], "/")
+	}
+
+	return levels
+}
@@ -0,0 +1,32 @@
+package containerregistry
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHasPathPrefix(t *testing.T) {
+	repository := Repository{Link: "gcr.io/project/team-a/service", Host: "gcr.io"}
+
+	if repository.Path() != "project/team-a/service" {
+		t.Errorf("Wrong path %v", repository.Path())
+	}
+
+	for _, prefix := range []string{"project/team-a/**", "project/team-a", "project/team-a/", "project/team-a/service", "gcr.io/project/**", "**"} {
+		if !repository.HasPathPrefix(prefix) {
+			t.Errorf("The repository should lie under %v", prefix)
+		}
+	}
+
+	for _, prefix := range []string{"project/team", "project/team-a/service/worker", "other/**"} {
+		if repository.HasPathPrefix(prefix) {
+			t.Errorf("The repository should not lie under %v", prefix)
+		}
+	}
+}
+
+func TestPathLevels(t *testing.T) {
+	if !reflect.DeepEqual(PathLevels("project/team/service"), []string{"project", "project/team", "project/team/service"}) {
+		t.Errorf("Wrong levels %v", PathLevels("project/team/service"))
+	}
+}
@@ -0,0 +1,189 @@
+package quay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	myhttp "github.com/hytromo/faulty-crane/internal/http"
+	log "github.com/sirupsen/logrus"
+)
+
+var defaultHost = "quay.io"
+
+// Login sets the oauth token that is used on every request
+func (client *RegistryClient) Login(username string, password string) error {
+	client.httpClient.InjectAuthInRequest = func(req *http.Request) {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", password))
+	}
+
+	return nil
+}
+
+// Capabilities returns what the client can do; Quay deletes tags only and garbage collects the manifests, so the untagged manifests, e.g. the platform manifests of multi-arch images or the manifests of already deleted tags, are never deleted by the client
+func (client *RegistryClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{
+		DeleteTagsOnly: true,
+	}
+}
+
+// DeleteImage deletes all the tags of an image; quay garbage collects the manifest once no tag points to it anymore
+func (client *RegistryClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	for _, tag := range image.Tag {
+		err := client.httpClient.DeleteRequestTo("/repository/"+imageRepo+"/tag/"+url.PathEscape(tag), true, silentErrors)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetAllRepos returns all the repositories of the organization
+func (client *RegistryClient) GetAllRepos() []string {
+	repositories := []string{}
+	nextPage := ""
+
+	for {
+		bodyBytes, err := client.httpClient.GetRequestTo(fmt.Sprintf("/repository?namespace=%s&next_page=%s", url.QueryEscape(client.organization), url.QueryEscape(nextPage)))
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		repositoriesResp := RepositoriesDTO{}
+		err = json.Unmarshal(bodyBytes, &repositoriesResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, repository := range repositoriesResp.Repositories {
+			if repository.Kind != "" && repository.Kind != "image" {
+				// e.g. application repositories do not contain container images
+				continue
+			}
+
+			repositories = append(repositories, repository.Namespace+"/"+repository.Name)
+		}
+
+		nextPage = repositoriesResp.NextPage
+		if nextPage == "" { // no more pages to GET
+			break
+		}
+	}
+
+	return repositories
+}
+
+// ParseRepo parses all the active tags of a repository, grouping the tags that point to the same manifest; untagged manifests are not listed, as only the garbage collection of quay can remove them
+func (client *RegistryClient) ParseRepo(repositoryLink string) cr.Repository {
+	repository := cr.Repository{
+		Link:   repositoryLink,
+		Images: []cr.ContainerImage{},
+	}
+
+	imageIndexOfDigest := map[string]int{}
+	// an image is uploaded when the first of its tags was pushed and it expires when the last of its tags expires
+	uploadedTsOfImage := []int64{}
+	expiresTsOfImage := []int64{}
+
+	for page := 1; ; page++ {
+		bodyBytes, err := client.httpClient.GetRequestTo(fmt.Sprintf("/repository/%s/tag/?onlyActiveTags=true&limit=100&page=%d", repositoryLink, page))
+
+		if err != nil {
+			log.Fatalf("Error on api call: %v", err.Error())
+		}
+
+		tagsResp := TagsDTO{}
+		err = json.Unmarshal(bodyBytes, &tagsResp)
+
+		if err != nil {
+			log.Fatalf("Invalid api call response (%v): %v", string(bodyBytes), err.Error())
+		}
+
+		for _, tag := range tagsResp.Tags {
+			if imageIndex, exists := imageIndexOfDigest[tag.ManifestDigest]; exists {
+				repository.Images[imageIndex].Tag = append(repository.Images[imageIndex].Tag, tag.Name)
+
+				if tag.StartTs < uploadedTsOfImage[imageIndex] {
+					uploadedTsOfImage[imageIndex] = tag.StartTs
+				}
+
+				// a tag without expiration means that the image never expires
+				if tag.EndTs == 0 || expiresTsOfImage[imageIndex] == 0 {
+					expiresTsOfImage[imageIndex] = 0
+				} else if tag.EndTs > expiresTsOfImage[imageIndex] {
+					expiresTsOfImage[imageIndex] = tag.EndTs
+				}
+
+				continue
+			}
+
+			mediaType := "application/vnd.docker.distribution.manifest.v2+json"
+			if tag.IsManifestList {
+				mediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+			}
+
+			imageIndexOfDigest[tag.ManifestDigest] = len(repository.Images)
+			uploadedTsOfImage = append(uploadedTsOfImage, tag.StartTs)
+			expiresTsOfImage = append(expiresTsOfImage, tag.EndTs)
+			repository.Images = append(repository.Images, cr.ContainerImage{
+				Tag:            []string{tag.Name},
+				Digest:         []string{tag.ManifestDigest},
+				MediaType:      mediaType,
+				ImageSizeBytes: strconv.FormatInt(tag.Size, 10),
+				Repo:           client.hostname + "/" + repositoryLink,
+			})
+		}
+
+		if !tagsResp.HasAdditional { // no more pages to GET
+			break
+		}
+	}
+
+	for imageIndex := range repository.Images {
+		uploadedMs := strconv.FormatInt(uploadedTsOfImage[imageIndex]*1000, 10)
+		repository.Images[imageIndex].TimeCreatedMs = uploadedMs
+		repository.Images[imageIndex].TimeUploadedMs = uploadedMs
+
+		if expiresTsOfImage[imageIndex] != 0 {
+			repository.Images[imageIndex].TimeExpiresMs = strconv.FormatInt(expiresTsOfImage[imageIndex]*1000, 10)
+		}
+	}
+
+	return repository
+}
+
+// NewQuayClientParams are the required parameters to build a Quay client
+type NewQuayClientParams struct {
+	// Host is the quay host, e.g. https://quay.example.com; https is assumed when no scheme is specified and quay.io when empty
+	Host string
+	// Organization is the namespace whose repositories are going to be cleaned
+	Organization string
+}
+
+// NewQuayClient builds a new Quay client
+func NewQuayClient(params NewQuayClientParams) cr.Client {
+	baseURL := strings.TrimSuffix(params.Host, "/")
+	if baseURL == "" {
+		baseURL = defaultHost
+	}
+
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "https://" + baseURL
+	}
+
+	return &RegistryClient{
+		httpClient: myhttp.NewClient(myhttp.NewClientParams{
+			BaseURL:             baseURL + "/api/v1",
+			InjectAuthInRequest: nil, // we set this once we have logged in
+		}),
+		hostname:     strings.TrimPrefix(strings.TrimPrefix(baseURL, "https://"), "http://"),
+		organization: params.Organization,
+	}
+}
@@ -0,0 +1,120 @@
+package quay
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/registrytest"
+)
+
+// newFakeAPI starts a local stand-in of the Quay API for an organization with two pages of repositories and tags
+func newFakeAPI(t *testing.T) *registrytest.Registry {
+	registry := registrytest.New(t, registrytest.BearerToken("token"))
+
+	registry.Handle("GET", "/api/v1/repository", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("next_page") != "" {
+			_ = json.NewEncoder(w).Encode(RepositoriesDTO{Repositories: []RepositoryDTO{{Namespace: "my-org", Name: "worker"}}})
+			return
+		}
+
+		if r.URL.Query().Get("namespace") != "my-org" {
+			t.Errorf("Wrong namespace %v", r.URL.Query().Get("namespace"))
+		}
+
+		_ = json.NewEncoder(w).Encode(RepositoriesDTO{
+			Repositories: []RepositoryDTO{{Namespace: "my-org", Name: "app", Kind: "image"}, {Namespace: "my-org", Name: "chart", Kind: "application"}},
+			NextPage:     "abc",
+		})
+	})
+	registry.Handle("GET", "/api/v1/repository/my-org/app/tag/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("onlyActiveTags") != "true" {
+			t.Error("Only active tags should be listed")
+		}
+
+		if r.URL.Query().Get("page") == "1" {
+			_ = json.NewEncoder(w).Encode(TagsDTO{
+				Tags: []TagDTO{
+					{Name: "latest", ManifestDigest: "sha256:abc", Size: 1000, StartTs: 1643814245},
+					{Name: "pr-1", ManifestDigest: "sha256:def", Size: 500, StartTs: 1643800000, EndTs: 1643900000},
+				},
+				Page:          1,
+				HasAdditional: true,
+			})
+		} else {
+			_ = json.NewEncoder(w).Encode(TagsDTO{
+				Tags: []TagDTO{
+					{Name: "v1", ManifestDigest: "sha256:abc", Size: 1000, StartTs: 1643700000},
+					{Name: "pr-1-retry", ManifestDigest: "sha256:def", Size: 500, StartTs: 1643810000, EndTs: 1643950000},
+				},
+				Page: 2,
+			})
+		}
+	})
+
+	return registry
+}
+
+func newTestClient(t *testing.T) (cr.Client, *registrytest.Registry) {
+	registry := newFakeAPI(t)
+	client := NewQuayClient(NewQuayClientParams{
+		Host:         registry.URL,
+		Organization: "my-org",
+	})
+
+	err := client.Login("", "token")
+
+	if err != nil {
+		t.Error("Login should not fail")
+	}
+
+	return client, registry
+}
+
+func TestGetAllRepos(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	if !reflect.DeepEqual(client.GetAllRepos(), []string{"my-org/app", "my-org/worker"}) {
+		t.Errorf("Wrong repos %v", client.GetAllRepos())
+	}
+}
+
+func TestParseRepo(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	repo := client.ParseRepo("my-org/app")
+
+	if len(repo.Images) != 2 {
+		t.Fatalf("Tags with the same digest should be grouped, got %v images", len(repo.Images))
+	}
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Tag, []string{"latest", "v1"}) || !reflect.DeepEqual(image.Digest, []string{"sha256:abc"}) || image.ImageSizeBytes != "1000" {
+		t.Errorf("Wrong image data %+v", image)
+	}
+
+	if image.TimeUploadedMs != "1643700000000" || image.TimeExpiresMs != "" {
+		t.Errorf("The image should be uploaded with its first tag and should never expire: %+v", image)
+	}
+
+	if repo.Images[1].TimeExpiresMs != "1643950000000" {
+		t.Errorf("The image should expire along with its last tag, not at %v", repo.Images[1].TimeExpiresMs)
+	}
+}
+
+func TestDeleteImage(t *testing.T) {
+	client, registry := newTestClient(t)
+
+	err := client.DeleteImage("my-org/app", cr.ContainerImage{Tag: []string{"pr-1", "pr-1-retry"}, Digest: []string{"sha256:def"}}, false)
+
+	if err != nil {
+		t.Error("Delete should not fail")
+	}
+
+	if !reflect.DeepEqual(registry.Deleted(), []string{"/api/v1/repository/my-org/app/tag/pr-1", "/api/v1/repository/my-org/app/tag/pr-1-retry"}) {
+		t.Errorf("Wrong deleted tags %v", registry.Deleted())
+	}
+}
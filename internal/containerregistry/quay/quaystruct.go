@@ -0,0 +1,43 @@
+package quay
+
+import myhttp "github.com/hytromo/faulty-crane/internal/http"
+
+// RegistryClient is a Quay.io / Red Hat Quay client, it uses the Quay api instead of the docker registry api
+type RegistryClient struct {
+	httpClient myhttp.Client
+	// hostname is the registry host without the scheme, e.g. quay.io
+	hostname     string
+	organization string
+}
+
+// RepositoryDTO is the DTO of a Quay repository
+type RepositoryDTO struct {
+	Namespace string
+	Name      string
+	Kind      string
+}
+
+// RepositoriesDTO is the Data Transfer Object for the /repository api call
+type RepositoriesDTO struct {
+	Repositories []RepositoryDTO
+	NextPage     string `json:"next_page"`
+}
+
+// TagDTO is the DTO of a Quay tag
+type TagDTO struct {
+	Name           string
+	ManifestDigest string `json:"manifest_digest"`
+	IsManifestList bool   `json:"is_manifest_list"`
+	Size           int64
+	// StartTs is the unix timestamp in seconds of when the tag started pointing to its manifest
+	StartTs int64 `json:"start_ts"`
+	// EndTs is the unix timestamp in seconds of when the tag expires, if an expiration has been set
+	EndTs int64 `json:"end_ts"`
+}
+
+// TagsDTO is the Data Transfer Object for the /repository/{repository}/tag/ api call
+type TagsDTO struct {
+	Tags          []TagDTO
+	Page          int
+	HasAdditional bool `json:"has_additional"`
+}
@@ -0,0 +1,37 @@
+package containerregistry
+
+import "regexp"
+
+// referrerTagRegex matches the tags that tools like cosign give to the artifacts of an image, e.g. sha256-<digest>.sig, .att or .sbom, as well as the referrers tag schema of the registries that do not support the referrers api
+var referrerTagRegex = regexp.MustCompile(`^sha256-([0-9a-f]{64})(\.[a-z]+)?$`)
+
+// SubjectOfTag returns the digest of the image that a referrer tag points to, e.g. sha256:<digest> for sha256-<digest>.sig
+func SubjectOfTag(tag string) (string, bool) {
+	match := referrerTagRegex.FindStringSubmatch(tag)
+
+	if match == nil {
+		return "", false
+	}
+
+	return "sha256:" + match[1], true
+}
+
+// SubjectDigest returns the digest of the image that the image refers to, if it is a signature, an attestation, an sbom or any other referrer
+func (image ContainerImage) SubjectDigest() string {
+	if image.Subject != "" {
+		return image.Subject
+	}
+
+	for _, tag := range image.Tag {
+		if subject, isReferrer := SubjectOfTag(tag); isReferrer {
+			return subject
+		}
+	}
+
+	return ""
+}
+
+// IsReferrer returns if the image refers to another image, e.g. it is its signature
+func (image ContainerImage) IsReferrer() bool {
+	return image.SubjectDigest() != ""
+}
@@ -0,0 +1,26 @@
+package containerregistry
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubjectDigest(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	for _, tag := range []string{"sha256-" + strings.Repeat("a", 64) + ".sig", "sha256-" + strings.Repeat("a", 64) + ".att", "sha256-" + strings.Repeat("a", 64)} {
+		if subject := (ContainerImage{Tag: []string{"other", tag}}).SubjectDigest(); subject != digest {
+			t.Errorf("The subject of %v should be %v, not %v", tag, digest, subject)
+		}
+	}
+
+	for _, tag := range []string{"v1", "sha256-abc.sig", "sha256-" + strings.Repeat("a", 64) + ".sig.bak"} {
+		if (ContainerImage{Tag: []string{tag}}).IsReferrer() {
+			t.Errorf("%v should not be a referrer tag", tag)
+		}
+	}
+
+	if (ContainerImage{Subject: digest}).SubjectDigest() != digest {
+		t.Error("The subject field should be used")
+	}
+}
@@ -0,0 +1,156 @@
+package registrytest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// Authorize tells if a request may reach the routes of the registry; it answers the request itself when it may not, e.g. with 401 Unauthorized
+type Authorize func(w http.ResponseWriter, r *http.Request) bool
+
+// Deletion is a deletion that the registry received
+type Deletion struct {
+	// Path is the escaped path of the request, along with its query if any
+	Path string
+	// Payload is the body of the request, e.g. the tags of a bulk deletion
+	Payload string
+}
+
+// Registry is a local stand-in of a registry api: it authorizes every request, answers the routes of the test and records the deletions that no route answers
+type Registry struct {
+	URL       string
+	authorize Authorize
+	mutex     sync.Mutex
+	routes    map[string]http.HandlerFunc
+	deletions []Deletion
+}
+
+// New starts a fake registry that is stopped at the end of the test; a nil authorize lets every request through
+func New(t *testing.T, authorize Authorize) *Registry {
+	registry := &Registry{
+		authorize: authorize,
+		routes:    map[string]http.HandlerFunc{},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(registry.serveHTTP))
+	t.Cleanup(server.Close)
+
+	registry.URL = server.URL
+
+	return registry
+}
+
+// BearerToken lets through the requests that carry the token
+func BearerToken(token string) Authorize {
+	return HeaderValue("Authorization", "Bearer "+token)
+}
+
+// BasicAuth lets through the requests that carry the username and the password
+func BasicAuth(username string, password string) Authorize {
+	return HeaderValue("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+}
+
+// HeaderValue lets through the requests whose header has the value, e.g. the PRIVATE-TOKEN of GitLab
+func HeaderValue(header string, value string) Authorize {
+	return func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get(header) != value {
+			w.WriteHeader(http.StatusUnauthorized)
+			return false
+		}
+
+		return true
+	}
+}
+
+// Handle answers the requests of the method to the escaped path, e.g. "/orgs/my-org/packages"; an empty method matches all of them and the handler looks at the query on its own, e.g. for pagination
+func (registry *Registry) Handle(method string, path string, handler http.HandlerFunc) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.routes[method+" "+path] = handler
+}
+
+// Record records a deletion that a route answers itself, e.g. the manifests of a bulk deletion
+func (registry *Registry) Record(deletion Deletion) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	registry.deletions = append(registry.deletions, deletion)
+}
+
+// Deletions returns the deletions that the registry received, in order
+func (registry *Registry) Deletions() []Deletion {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	return append([]Deletion{}, registry.deletions...)
+}
+
+// Deleted returns the paths of the deletions that the registry received, in order
+func (registry *Registry) Deleted() []string {
+	deleted := []string{}
+
+	for _, deletion := range registry.Deletions() {
+		deleted = append(deleted, deletion.Path)
+	}
+
+	return deleted
+}
+
+// JSON answers with the value encoded as json
+func JSON(value interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(value)
+	}
+}
+
+// Status answers with the status code and the body, e.g. the refusal of a deletion
+func Status(statusCode int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func (registry *Registry) handlerOf(method string, path string) http.HandlerFunc {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+
+	if handler, exists := registry.routes[method+" "+path]; exists {
+		return handler
+	}
+
+	return registry.routes[" "+path]
+}
+
+func (registry *Registry) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if registry.authorize != nil && !registry.authorize(w, r) {
+		return
+	}
+
+	path := r.URL.EscapedPath()
+
+	if handler := registry.handlerOf(r.Method, path); handler != nil {
+		handler(w, r)
+		return
+	}
+
+	if r.Method != "DELETE" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+
+	payload, _ := ioutil.ReadAll(r.Body)
+	registry.Record(Deletion{Path: path, Payload: string(payload)})
+
+	w.WriteHeader(http.StatusAccepted)
+}
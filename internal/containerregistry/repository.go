@@ -0,0 +1,30 @@
+package containerregistry
+
+import "strconv"
+
+// RepositoryDeletionReason is the reason why a whole repository is planned to be deleted after its images
+type RepositoryDeletionReason string
+
+const (
+	// RepositoryEmpty means that the repository will have no images left after the apply
+	RepositoryEmpty RepositoryDeletionReason = "empty"
+	// RepositoryInactive means that nothing has been pushed to the repository for longer than the configured duration
+	RepositoryInactive RepositoryDeletionReason = "inactive"
+)
+
+// LastActivityMs returns when the repository was last updated according to the registry, or else when its most recent image was uploaded; it returns 0 if it is not known
+func (repository Repository) LastActivityMs() int64 {
+	if lastUpdatedMs, err := strconv.ParseInt(repository.TimeLastUpdatedMs, 10, 64); err == nil {
+		return lastUpdatedMs
+	}
+
+	var lastActivityMs int64 = 0
+
+	for _, image := range repository.Images {
+		if uploadedMs, err := strconv.ParseInt(image.TimeUploadedMs, 10, 64); err == nil && uploadedMs > lastActivityMs {
+			lastActivityMs = uploadedMs
+		}
+	}
+
+	return lastActivityMs
+}
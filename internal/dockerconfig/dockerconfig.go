@@ -0,0 +1,225 @@
+package dockerconfig
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubKey is the key that docker uses for the docker hub credentials, both in the auths and for the credential helpers
+const dockerHubKey = "https://index.docker.io/v1/"
+
+// identityTokenUsername is the username that credential helpers return along with an identity token instead of a password
+const identityTokenUsername = "<token>"
+
+// Credentials are the credentials of a registry as stored by docker login
+type Credentials struct {
+	Username string
+	// Password is the password or, when IdentityToken is set, the identity token (refresh token) of the registry
+	Password      string
+	IdentityToken bool
+	// Source describes where the credentials were found, for logging purposes
+	Source string
+}
+
+// authDTO is an entry of the auths of the docker config file
+type authDTO struct {
+	// Auth is the base64 encoded username:password
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// configFileDTO is the part of the docker config file that holds the credentials
+type configFileDTO struct {
+	Auths map[string]authDTO `json:"auths"`
+	// CredsStore is the default credential helper, e.g. desktop or osxkeychain
+	CredsStore string `json:"credsStore"`
+	// CredHelpers are the credential helpers per registry host, e.g. gcr.io: gcr
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// helperCredentialsDTO is the output of the get command of a credential helper
+type helperCredentialsDTO struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// errHelperCredentialsNotFound is returned when a credential helper has no credentials for a registry
+var errHelperCredentialsNotFound = errors.New("credentials not found")
+
+// ConfigPath returns the path of the docker config file, respecting DOCKER_CONFIG like the docker cli does
+func ConfigPath() string {
+	if configDir := os.Getenv("DOCKER_CONFIG"); configDir != "" {
+		return filepath.Join(configDir, "config.json")
+	}
+
+	home, err := os.UserHomeDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// keyOf normalizes a registry host or an auths key to the form that docker uses to store its credentials, e.g. https://eu.gcr.io/v2/ becomes eu.gcr.io
+func keyOf(registry string) string {
+	host := strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")
+	host = strings.SplitN(host, "/", 2)[0]
+
+	switch host {
+	case "docker.io", "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com", "hub.docker.com":
+		return dockerHubKey
+	}
+
+	return host
+}
+
+// getFromHelper runs the get command of the docker-credential-<helper> executable
+func getFromHelper(helper string, serverURL string) (helperCredentialsDTO, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(serverURL)
+
+	stdout := bytes.Buffer{}
+	cmd.Stdout = &stdout
+
+	err := cmd.Run()
+
+	if err != nil {
+		if strings.Contains(stdout.String(), "credentials not found") {
+			return helperCredentialsDTO{}, errHelperCredentialsNotFound
+		}
+
+		return helperCredentialsDTO{}, fmt.Errorf("credential helper %v failed: %v %v", helper, err, strings.TrimSpace(stdout.String()))
+	}
+
+	credentials := helperCredentialsDTO{}
+	err = json.Unmarshal(stdout.Bytes(), &credentials)
+
+	if err != nil {
+		return helperCredentialsDTO{}, fmt.Errorf("invalid output of credential helper %v: %v", helper, err)
+	}
+
+	return credentials, nil
+}
+
+func fromAuth(auth authDTO, source string) (Credentials, bool, error) {
+	if auth.IdentityToken != "" {
+		return Credentials{
+			Username:      auth.Username,
+			Password:      auth.IdentityToken,
+			IdentityToken: true,
+			Source:        source,
+		}, true, nil
+	}
+
+	if auth.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+
+		if err != nil {
+			return Credentials{}, false, fmt.Errorf("invalid auth of %v: %v", source, err)
+		}
+
+		username, password, isValid := strings.Cut(string(decoded), ":")
+
+		if !isValid {
+			return Credentials{}, false, fmt.Errorf("invalid auth of %v, it should be username:password", source)
+		}
+
+		return Credentials{
+			Username: username,
+			Password: password,
+			Source:   source,
+		}, true, nil
+	}
+
+	if auth.Password != "" {
+		return Credentials{
+			Username: auth.Username,
+			Password: auth.Password,
+			Source:   source,
+		}, true, nil
+	}
+
+	return Credentials{}, false, nil
+}
+
+// Lookup finds the credentials of a registry in the docker config file the same way the docker cli does: first the credential helper of the registry, then the default credential store and then the auths of the file itself
+func Lookup(registry string) (Credentials, bool, error) {
+	path := ConfigPath()
+
+	if path == "" {
+		return Credentials{}, false, nil
+	}
+
+	fileBytes, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Credentials{}, false, nil
+		}
+
+		return Credentials{}, false, err
+	}
+
+	config := configFileDTO{}
+	err = json.Unmarshal(fileBytes, &config)
+
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("invalid docker config file %v: %v", path, err)
+	}
+
+	key := keyOf(registry)
+
+	helper := config.CredsStore
+	for helperRegistry, registryHelper := range config.CredHelpers {
+		if keyOf(helperRegistry) == key {
+			helper = registryHelper
+		}
+	}
+
+	if helper != "" {
+		helperCredentials, err := getFromHelper(helper, key)
+
+		if err == nil && helperCredentials.Secret != "" {
+			credentials := Credentials{
+				Username: helperCredentials.Username,
+				Password: helperCredentials.Secret,
+				Source:   "credential helper " + helper,
+			}
+
+			if helperCredentials.Username == identityTokenUsername {
+				credentials.Username = ""
+				credentials.IdentityToken = true
+			}
+
+			return credentials, true, nil
+		}
+
+		if err != nil && !errors.Is(err, errHelperCredentialsNotFound) {
+			return Credentials{}, false, err
+		}
+	}
+
+	for authKey, auth := range config.Auths {
+		if keyOf(authKey) == key {
+			credentials, found, err := fromAuth(auth, "auths of "+path)
+
+			if err != nil || found {
+				return credentials, found, err
+			}
+		}
+	}
+
+	return Credentials{}, false, nil
+}
@@ -0,0 +1,107 @@
+package dockerconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeDockerConfig writes a docker config file in a temporary DOCKER_CONFIG along with a fake docker-credential-fake helper that knows a single registry
+func writeDockerConfig(t *testing.T, config string) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+
+	if err := ioutil.WriteFile(filepath.Join(configDir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	helper := `#!/bin/sh
+read server
+if [ "$1" = "get" ] && [ "$server" = "eu.gcr.io" ]; then
+	echo '{"ServerURL": "eu.gcr.io", "Username": "_dcgcr_2_0_0_token", "Secret": "access-token"}'
+	exit 0
+fi
+if [ "$1" = "get" ] && [ "$server" = "myregistry.azurecr.io" ]; then
+	echo '{"ServerURL": "myregistry.azurecr.io", "Username": "<token>", "Secret": "refresh-token"}'
+	exit 0
+fi
+echo "credentials not found in native keychain"
+exit 1
+`
+
+	if err := ioutil.WriteFile(filepath.Join(configDir, "docker-credential-fake"), []byte(helper), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", configDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestAuths(t *testing.T) {
+	writeDockerConfig(t, `{"auths": {
+		"https://index.docker.io/v1/": {"auth": "dXNlcjpkY2tyX3BhdF90b2tlbg=="},
+		"harbor.example.com": {"username": "robot", "password": "secret"},
+		"https://registry.example.com/v2/": {"username": "user", "identitytoken": "identity"}
+	}}`)
+
+	credentials, found, err := Lookup("docker.io")
+
+	if err != nil || !found || credentials.Username != "user" || credentials.Password != "dckr_pat_token" {
+		t.Errorf("Wrong docker hub credentials %+v (%v)", credentials, err)
+	}
+
+	credentials, found, err = Lookup("https://harbor.example.com")
+
+	if err != nil || !found || credentials.Username != "robot" || credentials.Password != "secret" {
+		t.Errorf("Wrong harbor credentials %+v (%v)", credentials, err)
+	}
+
+	credentials, found, err = Lookup("registry.example.com")
+
+	if err != nil || !found || !credentials.IdentityToken || credentials.Password != "identity" {
+		t.Errorf("Wrong identity token credentials %+v (%v)", credentials, err)
+	}
+
+	if _, found, _ = Lookup("ghcr.io"); found {
+		t.Error("Registries without credentials should not be found")
+	}
+}
+
+func TestCredentialHelpers(t *testing.T) {
+	writeDockerConfig(t, `{
+		"auths": {"ghcr.io": {"auth": "dXNlcjp0b2tlbg=="}},
+		"credsStore": "fake",
+		"credHelpers": {"quay.io": "missing"}
+	}`)
+
+	credentials, found, err := Lookup("eu.gcr.io")
+
+	if err != nil || !found || credentials.Password != "access-token" {
+		t.Errorf("Wrong credentials from the credential store %+v (%v)", credentials, err)
+	}
+
+	credentials, found, err = Lookup("myregistry.azurecr.io")
+
+	if err != nil || !found || !credentials.IdentityToken || credentials.Username != "" || credentials.Password != "refresh-token" {
+		t.Errorf("Wrong identity token from the credential store %+v (%v)", credentials, err)
+	}
+
+	// the credential store does not know this registry, so the auths of the file are used
+	credentials, found, err = Lookup("ghcr.io")
+
+	if err != nil || !found || credentials.Password != "token" {
+		t.Errorf("Wrong credentials from the auths %+v (%v)", credentials, err)
+	}
+
+	if _, _, err = Lookup("quay.io"); err == nil {
+		t.Error("A credential helper that cannot run should be reported")
+	}
+}
+
+func TestMissingConfig(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+
+	if _, found, err := Lookup("docker.io"); found || err != nil {
+		t.Error("A missing docker config file should mean no credentials")
+	}
+}
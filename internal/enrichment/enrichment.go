@@ -0,0 +1,229 @@
+package enrichment
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	log "github.com/sirupsen/logrus"
+)
+
+// configMediaTypes are the media types of the configurations of container images; the configurations of other artifacts, e.g. helm charts, are not looked into
+var configMediaTypes = map[string]bool{
+	"application/vnd.oci.image.config.v1+json":       true,
+	"application/vnd.docker.container.image.v1+json": true,
+}
+
+// descriptorDTO describes a blob that a manifest references
+type descriptorDTO struct {
+	MediaType string
+	Digest    string
+}
+
+// manifestDTO is the part of an image manifest or an image index that the enrichment needs
+type manifestDTO struct {
+	MediaType   string
+	Config      descriptorDTO
+	Layers      []cr.Layer
+	Annotations map[string]string
+}
+
+// configDTO is the part of an image configuration blob that the enrichment needs
+type configDTO struct {
+	Created      string
+	OS           string
+	Architecture string
+	Variant      string
+	Config       struct {
+		Labels map[string]string
+	}
+}
+
+// Enricher adds the labels, the annotations, the platform, the layers and the creation time of the configuration to the images, out of their manifests and configuration blobs; as they are addressed by their content, they are cached by digest across repositories
+type Enricher struct {
+	fetcher   cr.ManifestFetcher
+	mutex     sync.Mutex
+	manifests map[string]manifestDTO
+	configs   map[string]configDTO
+}
+
+// NewEnricher builds an enricher that fetches the manifests and the blobs through the registry client
+func NewEnricher(fetcher cr.ManifestFetcher) *Enricher {
+	return &Enricher{
+		fetcher:   fetcher,
+		manifests: map[string]manifestDTO{},
+		configs:   map[string]configDTO{},
+	}
+}
+
+func (enricher *Enricher) getManifest(repositoryLink string, digest string) (manifestDTO, error) {
+	enricher.mutex.Lock()
+	manifest, isCached := enricher.manifests[digest]
+	enricher.mutex.Unlock()
+
+	if isCached {
+		return manifest, nil
+	}
+
+	bodyBytes, err := enricher.fetcher.GetManifest(repositoryLink, digest)
+
+	if err == nil {
+		err = json.Unmarshal(bodyBytes, &manifest)
+	}
+
+	if err != nil {
+		return manifest, err
+	}
+
+	enricher.mutex.Lock()
+	enricher.manifests[digest] = manifest
+	enricher.mutex.Unlock()
+
+	return manifest, nil
+}
+
+func (enricher *Enricher) getConfig(repositoryLink string, digest string) (configDTO, error) {
+	enricher.mutex.Lock()
+	config, isCached := enricher.configs[digest]
+	enricher.mutex.Unlock()
+
+	if isCached {
+		return config, nil
+	}
+
+	bodyBytes, err := enricher.fetcher.GetBlob(repositoryLink, digest)
+
+	if err == nil {
+		err = json.Unmarshal(bodyBytes, &config)
+	}
+
+	if err != nil {
+		return config, err
+	}
+
+	enricher.mutex.Lock()
+	enricher.configs[digest] = config
+	enricher.mutex.Unlock()
+
+	return config, nil
+}
+
+// enrichImage adds what the manifest and the configuration of an image tell about it; the enrichment is optional, so an image that cannot be enriched is left as it is
+func (enricher *Enricher) enrichImage(repositoryLink string, image *cr.ContainerImage) {
+	manifest, err := enricher.getManifest(repositoryLink, image.Digest[0])
+
+	if err != nil {
+		log.Errorf("Could not fetch the manifest of %v@%v: %v", repositoryLink, image.Digest[0], err)
+		return
+	}
+
+	if len(manifest.Annotations) > 0 {
+		image.Annotations = manifest.Annotations
+	}
+
+	if len(manifest.Layers) > 0 {
+		image.Layers = manifest.Layers
+	}
+
+	if !configMediaTypes[manifest.Config.MediaType] {
+		// image indexes do not have a configuration and the configurations of other artifacts are not known
+		return
+	}
+
+	config, err := enricher.getConfig(repositoryLink, manifest.Config.Digest)
+
+	if err != nil {
+		log.Errorf("Could not fetch the configuration of %v@%v: %v", repositoryLink, image.Digest[0], err)
+		return
+	}
+
+	if len(config.Config.Labels) > 0 {
+		image.Labels = config.Config.Labels
+	}
+
+	if config.OS != "" || config.Architecture != "" {
+		image.Platform = &cr.Platform{
+			OS:           config.OS,
+			Architecture: config.Architecture,
+			Variant:      config.Variant,
+		}
+	}
+
+	if created, err := time.Parse(time.RFC3339Nano, config.Created); err == nil {
+		image.ConfigCreatedMs = strconv.FormatInt(created.UTC().UnixMilli(), 10)
+	}
+}
+
+// fetchLayers adds the layers of its manifest to an image that does not carry them, for the reclaimable sizes; an image whose manifest cannot be fetched is left without layers
+func (enricher *Enricher) fetchLayers(repositoryLink string, image *cr.ContainerImage) {
+	if len(image.Layers) > 0 || image.IsIndex() {
+		return
+	}
+
+	manifest, err := enricher.getManifest(repositoryLink, image.Digest[0])
+
+	if err != nil {
+		log.Errorf("Could not fetch the manifest of %v@%v: %v", repositoryLink, image.Digest[0], err)
+		return
+	}
+
+	if image.MediaType == "" {
+		// tells the image indexes apart, which have no layers of their own
+		image.MediaType = manifest.MediaType
+	}
+
+	if len(manifest.Layers) > 0 {
+		image.Layers = manifest.Layers
+	}
+}
+
+func (enricher *Enricher) imagesWorker(repositoryLink string, images <-chan *cr.ContainerImage, done chan<- bool, process func(string, *cr.ContainerImage)) {
+	for image := range images {
+		process(repositoryLink, image)
+		done <- true
+	}
+}
+
+// forEachImage processes all the images of a repository concurrently
+func (enricher *Enricher) forEachImage(repository cr.Repository, process func(string, *cr.ContainerImage)) cr.Repository {
+	imagesCount := len(repository.Images)
+
+	if imagesCount == 0 {
+		return repository
+	}
+
+	workersNum := int(math.Min(8, float64(imagesCount)))
+
+	imagesChan := make(chan *cr.ContainerImage, imagesCount) // jobs
+	doneChan := make(chan bool, imagesCount)                 // results
+
+	for i := 1; i <= workersNum; i++ {
+		go enricher.imagesWorker(repository.Link, imagesChan, doneChan, process)
+	}
+
+	for i := range repository.Images {
+		// feed the jobs to the workers
+		imagesChan <- &repository.Images[i]
+	}
+
+	close(imagesChan)
+
+	for range repository.Images {
+		<-doneChan
+	}
+
+	return repository
+}
+
+// EnrichRepository enriches all the images of a repository concurrently
+func (enricher *Enricher) EnrichRepository(repository cr.Repository) cr.Repository {
+	return enricher.forEachImage(repository, enricher.enrichImage)
+}
+
+// FetchLayers adds their layers to all the images of a repository concurrently, without the rest of the enrichment
+func (enricher *Enricher) FetchLayers(repository cr.Repository) cr.Repository {
+	return enricher.forEachImage(repository, enricher.fetchLayers)
+}
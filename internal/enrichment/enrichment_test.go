@@ -0,0 +1,135 @@
+package enrichment
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+)
+
+// fakeFetcher serves manifests and blobs out of maps and counts the fetches
+type fakeFetcher struct {
+	mutex     sync.Mutex
+	manifests map[string]string
+	blobs     map[string]string
+	fetches   int
+}
+
+func (fetcher *fakeFetcher) get(contents map[string]string, digest string) ([]byte, error) {
+	fetcher.mutex.Lock()
+	defer fetcher.mutex.Unlock()
+
+	fetcher.fetches++
+
+	content, exists := contents[digest]
+
+	if !exists {
+		return nil, errors.New("not found")
+	}
+
+	return []byte(content), nil
+}
+
+func (fetcher *fakeFetcher) GetManifest(repositoryLink string, digest string) ([]byte, error) {
+	return fetcher.get(fetcher.manifests, digest)
+}
+
+func (fetcher *fakeFetcher) GetBlob(repositoryLink string, digest string) ([]byte, error) {
+	return fetcher.get(fetcher.blobs, digest)
+}
+
+func TestEnrichRepository(t *testing.T) {
+	fetcher := &fakeFetcher{
+		manifests: map[string]string{
+			"sha256:image": `{"mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:config"}, "layers": [{"digest": "sha256:layer", "size": 10}], "annotations": {"org.opencontainers.image.source": "https://github.com/hytromo/faulty-crane"}}`,
+			"sha256:index": `{"mediaType": "application/vnd.oci.image.index.v1+json", "manifests": [], "annotations": {"team": "core"}}`,
+			"sha256:chart": `{"mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"mediaType": "application/vnd.cncf.helm.config.v1+json", "digest": "sha256:chart-config"}}`,
+		},
+		blobs: map[string]string{
+			"sha256:config": `{"created": "2022-02-02T15:04:05.123Z", "os": "linux", "architecture": "arm", "variant": "v7", "config": {"Labels": {"maintainer": "hytromo"}}}`,
+		},
+	}
+
+	enricher := NewEnricher(fetcher)
+
+	repo := enricher.EnrichRepository(cr.Repository{
+		Link: "app",
+		Images: []cr.ContainerImage{
+			{Digest: []string{"sha256:image"}},
+			{Digest: []string{"sha256:index"}},
+			{Digest: []string{"sha256:chart"}},
+			{Digest: []string{"sha256:missing"}},
+		},
+	})
+
+	image := repo.Images[0]
+
+	if !reflect.DeepEqual(image.Labels, map[string]string{"maintainer": "hytromo"}) || image.Annotations["org.opencontainers.image.source"] == "" {
+		t.Errorf("Wrong labels or annotations %+v", image)
+	}
+
+	if !reflect.DeepEqual(image.Layers, []cr.Layer{{Digest: "sha256:layer", Size: 10}}) {
+		t.Errorf("Wrong layers %+v", image.Layers)
+	}
+
+	if image.Platform == nil || *image.Platform != (cr.Platform{OS: "linux", Architecture: "arm", Variant: "v7"}) || image.ConfigCreatedMs != "1643814245123" {
+		t.Errorf("Wrong platform or creation time %+v", image)
+	}
+
+	if repo.Images[1].Annotations["team"] != "core" || repo.Images[1].Platform != nil {
+		t.Errorf("Image indexes have only annotations, got %+v", repo.Images[1])
+	}
+
+	// the configurations of other artifacts are not fetched, and images that cannot be fetched are left as they are
+	if repo.Images[2].Labels != nil || repo.Images[2].Platform != nil || repo.Images[3].Annotations != nil {
+		t.Errorf("Unknown artifacts should not be enriched, got %+v and %+v", repo.Images[2], repo.Images[3])
+	}
+
+	fetchesBefore := fetcher.fetches
+
+	// the same image in another repository is served from the cache
+	other := enricher.EnrichRepository(cr.Repository{Link: "other", Images: []cr.ContainerImage{{Digest: []string{"sha256:image"}}}})
+
+	if fetcher.fetches != fetchesBefore || other.Images[0].Platform == nil {
+		t.Errorf("The manifests and the configurations should be cached by digest, got %v fetches instead of %v", fetcher.fetches, fetchesBefore)
+	}
+}
+
+func TestFetchLayers(t *testing.T) {
+	fetcher := &fakeFetcher{
+		manifests: map[string]string{
+			"sha256:image": `{"mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"mediaType": "application/vnd.oci.image.config.v1+json", "digest": "sha256:config"}, "layers": [{"digest": "sha256:layer", "size": 10}]}`,
+			"sha256:index": `{"mediaType": "application/vnd.oci.image.index.v1+json", "manifests": []}`,
+		},
+	}
+
+	repo := NewEnricher(fetcher).FetchLayers(cr.Repository{
+		Link: "app",
+		Images: []cr.ContainerImage{
+			{Digest: []string{"sha256:image"}},
+			{Digest: []string{"sha256:index"}},
+			{Digest: []string{"sha256:missing"}},
+			// the layers that the registry reported already are not fetched again
+			{Digest: []string{"sha256:listed"}, Layers: []cr.Layer{{Digest: "sha256:listed-layer", Size: 5}}},
+		},
+	})
+
+	if !reflect.DeepEqual(repo.Images[0].Layers, []cr.Layer{{Digest: "sha256:layer", Size: 10}}) || repo.Images[0].Platform != nil {
+		t.Errorf("Only the layers should be fetched, got %+v", repo.Images[0])
+	}
+
+	if !repo.Images[1].IsIndex() || repo.Images[1].Layers != nil {
+		t.Errorf("Image indexes should be told apart, got %+v", repo.Images[1])
+	}
+
+	if repo.Images[2].Layers != nil {
+		t.Errorf("Images that cannot be fetched should be left without layers, got %+v", repo.Images[2].Layers)
+	}
+
+	// the configuration blobs are never fetched
+	if fetcher.fetches != 3 {
+		t.Errorf("Only the manifests without layers should be fetched, got %v fetches", fetcher.fetches)
+	}
+}
@@ -0,0 +1,66 @@
+package http
+
+import "strings"
+
+// Challenge is a parsed WWW-Authenticate response header, e.g. `Bearer realm="https://auth.example.com/token",service="registry",scope="repository:app:pull"`
+type Challenge struct {
+	// Scheme is lowercase, e.g. bearer or basic
+	Scheme string
+	// Params are keyed by their lowercase name
+	Params map[string]string
+}
+
+// ParseChallenge parses a WWW-Authenticate response header
+func ParseChallenge(header string) Challenge {
+	header = strings.TrimSpace(header)
+	parsed := Challenge{
+		Params: map[string]string{},
+	}
+
+	schemeEnd := strings.Index(header, " ")
+	if schemeEnd == -1 {
+		parsed.Scheme = strings.ToLower(header)
+		return parsed
+	}
+
+	parsed.Scheme = strings.ToLower(header[:schemeEnd])
+	rest := header[schemeEnd+1:]
+
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		equalsIndex := strings.Index(rest, "=")
+
+		if equalsIndex == -1 {
+			break
+		}
+
+		key := strings.ToLower(strings.TrimSpace(rest[:equalsIndex]))
+		rest = rest[equalsIndex+1:]
+		value := ""
+
+		if strings.HasPrefix(rest, `"`) {
+			// quoted values can contain commas, e.g. scope="repository:app:pull,delete"
+			closingQuote := strings.Index(rest[1:], `"`)
+			if closingQuote == -1 {
+				value = rest[1:]
+				rest = ""
+			} else {
+				value = rest[1 : closingQuote+1]
+				rest = rest[closingQuote+2:]
+			}
+		} else {
+			valueEnd := strings.Index(rest, ",")
+			if valueEnd == -1 {
+				value = rest
+				rest = ""
+			} else {
+				value = rest[:valueEnd]
+				rest = rest[valueEnd+1:]
+			}
+		}
+
+		parsed.Params[key] = strings.TrimSpace(value)
+	}
+
+	return parsed
+}
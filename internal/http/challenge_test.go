@@ -0,0 +1,15 @@
+package http
+
+import "testing"
+
+func TestParseChallenge(t *testing.T) {
+	parsed := ParseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:team/app:pull,delete"`)
+
+	if parsed.Scheme != "bearer" {
+		t.Error("Wrong scheme")
+	}
+
+	if parsed.Params["realm"] != "https://auth.example.com/token" || parsed.Params["service"] != "registry.example.com" || parsed.Params["scope"] != "repository:team/app:pull,delete" {
+		t.Errorf("Wrong params %v", parsed.Params)
+	}
+}
@@ -3,6 +3,7 @@ package http
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
@@ -11,25 +12,45 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// ErrNotFound is returned by the requests that treat a 404 Not Found response as an answer instead of an error to retry
+var ErrNotFound = errors.New("not found")
+
+// StatusError is returned by the requests that treat some error statuses as an answer instead of an error to retry
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (err *StatusError) Error() string {
+	return fmt.Sprintf("status %v: %v", err.StatusCode, err.Body)
+}
+
 // InjectAuthInRequest is a function to inject authorisation information on every request
 type InjectAuthInRequest func(req *http.Request)
 
+// RefreshAuth is a function that is called when the server responds with 401 Unauthorized; it gets the chance to acquire new credentials (e.g. by following a WWW-Authenticate challenge) before the request is retried
+type RefreshAuth func(resp *http.Response) error
+
 // Client is just a wrapper around the normal http client to provide some retry logic
 type Client struct {
 	BaseURL             string
 	realClient          *http.Client
 	InjectAuthInRequest InjectAuthInRequest
+	RefreshAuth         RefreshAuth
 }
 
-func (httpClient Client) newPOST(url string, jsonPayload []byte) *http.Request {
-	req, _ := http.NewRequest("POST", httpClient.getFullURLFor(url), bytes.NewBuffer(jsonPayload))
-	req.Header.Set("Content-Type", "application/json")
-
-	if httpClient.InjectAuthInRequest != nil {
-		httpClient.InjectAuthInRequest(req)
-	}
-
-	return req
+// requestOptions describes a single request that is going to be retried a few times on error
+type requestOptions struct {
+	method               string
+	url                  string
+	payload              []byte
+	headers              http.Header
+	allowCompleteFailure bool
+	silentErrors         bool
+	// notFoundIsAnswer returns ErrNotFound right away on 404 Not Found, instead of retrying
+	notFoundIsAnswer bool
+	// answerStatuses are returned right away as a *StatusError, instead of retrying
+	answerStatuses []int
 }
 
 func (httpClient *Client) getFullURLFor(url string) string {
@@ -40,18 +61,19 @@ func (httpClient *Client) getFullURLFor(url string) string {
 	return httpClient.BaseURL + url
 }
 
-func (httpClient Client) newGET(url string) *http.Request {
-	req, _ := http.NewRequest("GET", httpClient.getFullURLFor(url), nil)
+func (httpClient Client) newRequest(options requestOptions) *http.Request {
+	var req *http.Request
 
-	if httpClient.InjectAuthInRequest != nil {
-		httpClient.InjectAuthInRequest(req)
+	if options.payload != nil {
+		req, _ = http.NewRequest(options.method, httpClient.getFullURLFor(options.url), bytes.NewBuffer(options.payload))
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req, _ = http.NewRequest(options.method, httpClient.getFullURLFor(options.url), nil)
 	}
 
-	return req
-}
-
-func (httpClient Client) newDELETE(url string) *http.Request {
-	req, _ := http.NewRequest("DELETE", httpClient.getFullURLFor(url), nil)
+	for key, values := range options.headers {
+		req.Header[key] = values
+	}
 
 	if httpClient.InjectAuthInRequest != nil {
 		httpClient.InjectAuthInRequest(req)
@@ -60,16 +82,22 @@ func (httpClient Client) newDELETE(url string) *http.Request {
 	return req
 }
 
-// GetRequestTo does a GET request and retries a few times on error
-func (httpClient Client) GetRequestTo(url string) ([]byte, error) {
+// do executes a request and retries a few times on error; if the request fails completely and this is allowed, a nil response is returned along with the last error
+func (httpClient Client) do(options requestOptions) (*http.Response, []byte, error) {
 	triesCount := 1
+	refreshedAuth := false
+	var lastErr error
 
 	sleepOrExitOnError := func(err error) {
-		if triesCount > 3 {
+		if triesCount > 3 && !options.allowCompleteFailure {
 			log.Fatalf("HTTP request failed many times, fatal error: %v\n", err.Error())
 		}
 
-		log.Infof("HTTP request failed with %v, retrying...\n", err.Error())
+		lastErr = err
+
+		if !options.silentErrors {
+			log.Infof("HTTP request failed with %v, retrying...\n", err.Error())
+		}
 
 		triesCount++
 
@@ -77,8 +105,12 @@ func (httpClient Client) GetRequestTo(url string) ([]byte, error) {
 	}
 
 	for {
+		if triesCount >= 4 && options.allowCompleteFailure {
+			return nil, nil, fmt.Errorf("request failed many times: %w", lastErr) // the caller decides what a failed request means
+		}
+
 		resp, err := httpClient.realClient.Do(
-			httpClient.newGET(url),
+			httpClient.newRequest(options),
 		)
 
 		if err != nil {
@@ -87,121 +119,190 @@ func (httpClient Client) GetRequestTo(url string) ([]byte, error) {
 		}
 
 		bodyBytes, err := ioutil.ReadAll(resp.Body)
-
-		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			sleepOrExitOnError(errors.New(string(bodyBytes)))
-			continue
-		}
+		resp.Body.Close()
 
 		if err != nil {
 			sleepOrExitOnError(err)
 			continue
 		}
 
-		return bodyBytes, err
-	}
-}
-
-// PostRequestTo does a POST request and retries a few times on error
-func (httpClient Client) PostRequestTo(url string, jsonPayload []byte, allowCompleteFailure bool, silentErrors bool) ([]byte, error) {
-	triesCount := 1
-
-	sleepOrExitOnError := func(err error) {
-		if triesCount > 3 && !allowCompleteFailure {
-			log.Fatalf("HTTP request failed many times, fatal error: %v\n", err.Error())
-		}
+		if resp.StatusCode == http.StatusUnauthorized && httpClient.RefreshAuth != nil && !refreshedAuth {
+			// the credentials may have expired or may not be enough for this resource, give the owner of the client the chance to renew them once
+			refreshedAuth = true
 
-		if !silentErrors {
-			log.Infof("HTTP request failed with %v, retrying...\n", err.Error())
-		}
+			err = httpClient.RefreshAuth(resp)
 
-		triesCount++
+			if err != nil {
+				sleepOrExitOnError(err)
+			}
 
-		time.Sleep(1000 * time.Millisecond)
-	}
-
-	for {
-		if triesCount >= 4 && allowCompleteFailure {
-			return nil, nil // request retried too many times but we don't care anymore
-		}
-
-		resp, err := httpClient.realClient.Do(
-			httpClient.newPOST(url, jsonPayload),
-		)
-
-		if err != nil {
-			sleepOrExitOnError(err)
 			continue
 		}
 
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusNotFound && options.notFoundIsAnswer {
+			return resp, bodyBytes, ErrNotFound
+		}
 
-		if err != nil {
-			sleepOrExitOnError(err)
-			continue
+		for _, answerStatus := range options.answerStatuses {
+			if resp.StatusCode == answerStatus {
+				return resp, bodyBytes, &StatusError{StatusCode: resp.StatusCode, Body: string(bodyBytes)}
+			}
 		}
 
 		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			sleepOrExitOnError(errors.New(string(bodyBytes)))
+			if len(bodyBytes) == 0 {
+				sleepOrExitOnError(errors.New(resp.Status))
+			} else {
+				sleepOrExitOnError(errors.New(string(bodyBytes)))
+			}
 			continue
 		}
 
-		return bodyBytes, err
+		return resp, bodyBytes, nil
 	}
 }
 
-// DeleteRequestTo does a DELETE request and retries a few times on error
-func (httpClient Client) DeleteRequestTo(url string, allowCompleteFailure bool, silentErrors bool) error {
-	triesCount := 1
+// GetRequestTo does a GET request and retries a few times on error
+func (httpClient Client) GetRequestTo(url string) ([]byte, error) {
+	_, bodyBytes, err := httpClient.do(requestOptions{
+		method: "GET",
+		url:    url,
+	})
 
-	sleepOrExitOnError := func(err error) {
-		if triesCount > 3 && !allowCompleteFailure {
-			log.Fatalf("HTTP request failed many times, fatal error: %v\n", err.Error())
-		}
+	return bodyBytes, err
+}
 
-		if !silentErrors {
-			log.Infof("HTTP request failed with %v, retrying...\n", err.Error())
-		}
+// GetRequestWithHeadersTo does a GET request with extra request headers, retries a few times on error and returns the response headers as well
+func (httpClient Client) GetRequestWithHeadersTo(url string, headers http.Header) ([]byte, http.Header, error) {
+	resp, bodyBytes, err := httpClient.do(requestOptions{
+		method:  "GET",
+		url:     url,
+		headers: headers,
+	})
 
-		triesCount++
+	return bodyBytes, resp.Header, err
+}
 
-		time.Sleep(1000 * time.Millisecond)
-	}
+// GetRequestIfExistsTo is like GetRequestWithHeadersTo, but returns ErrNotFound without retrying when the resource does not exist, e.g. for optional apis
+func (httpClient Client) GetRequestIfExistsTo(url string, headers http.Header) ([]byte, http.Header, error) {
+	resp, bodyBytes, err := httpClient.do(requestOptions{
+		method:           "GET",
+		url:              url,
+		headers:          headers,
+		notFoundIsAnswer: true,
+	})
 
-	for {
-		if triesCount >= 4 && allowCompleteFailure {
-			return nil // request retried too many times but we don't care anymore
-		}
+	return bodyBytes, resp.Header, err
+}
 
-		resp, err := httpClient.realClient.Do(
-			httpClient.newDELETE(url),
-		)
+// GetRequestAnsweringTo is like GetRequestTo, but returns a *StatusError without retrying when the server responds with one of the answer statuses, e.g. when the resource does not exist, and returns the error instead of exiting when the request fails many times
+func (httpClient Client) GetRequestAnsweringTo(url string, answerStatuses []int, silentErrors bool) ([]byte, error) {
+	_, bodyBytes, err := httpClient.do(requestOptions{
+		method:               "GET",
+		url:                  url,
+		allowCompleteFailure: true,
+		silentErrors:         silentErrors,
+		answerStatuses:       answerStatuses,
+	})
+
+	return bodyBytes, err
+}
 
-		if err != nil {
-			sleepOrExitOnError(err)
-			continue
-		}
+// HeadRequestTo does a HEAD request with extra request headers, retries a few times on error and returns the response headers
+func (httpClient Client) HeadRequestTo(url string, headers http.Header) (http.Header, error) {
+	resp, _, err := httpClient.do(requestOptions{
+		method:  "HEAD",
+		url:     url,
+		headers: headers,
+	})
 
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
+	return resp.Header, err
+}
 
-		if err != nil {
-			sleepOrExitOnError(err)
-			continue
-		}
+// PostRequestTo does a POST request and retries a few times on error
+func (httpClient Client) PostRequestTo(url string, jsonPayload []byte, allowCompleteFailure bool, silentErrors bool) ([]byte, error) {
+	_, bodyBytes, err := httpClient.do(requestOptions{
+		method:               "POST",
+		url:                  url,
+		payload:              jsonPayload,
+		allowCompleteFailure: allowCompleteFailure,
+		silentErrors:         silentErrors,
+	})
+
+	return bodyBytes, err
+}
 
-		if resp.StatusCode < 200 || resp.StatusCode > 299 {
-			sleepOrExitOnError(errors.New(string(bodyBytes)))
-			continue
-		}
+// PostRequestAnsweringTo is like PostRequestTo, but returns a *StatusError without retrying when the server responds with one of the answer statuses, e.g. when it rejects the credentials of a login
+func (httpClient Client) PostRequestAnsweringTo(url string, jsonPayload []byte, answerStatuses []int, silentErrors bool) ([]byte, error) {
+	_, bodyBytes, err := httpClient.do(requestOptions{
+		method:               "POST",
+		url:                  url,
+		payload:              jsonPayload,
+		allowCompleteFailure: true,
+		silentErrors:         silentErrors,
+		answerStatuses:       answerStatuses,
+	})
+
+	return bodyBytes, err
+}
 
-		return err
-	}
+// PostRequestWithHeadersTo does a POST request with extra request headers and retries a few times on error; the extra headers override the default json content type
+func (httpClient Client) PostRequestWithHeadersTo(url string, jsonPayload []byte, headers http.Header, allowCompleteFailure bool, silentErrors bool) ([]byte, error) {
+	_, bodyBytes, err := httpClient.do(requestOptions{
+		method:               "POST",
+		url:                  url,
+		payload:              jsonPayload,
+		headers:              headers,
+		allowCompleteFailure: allowCompleteFailure,
+		silentErrors:         silentErrors,
+	})
+
+	return bodyBytes, err
+}
+
+// DeleteRequestTo does a DELETE request and retries a few times on error
+func (httpClient Client) DeleteRequestTo(url string, allowCompleteFailure bool, silentErrors bool) error {
+	_, _, err := httpClient.do(requestOptions{
+		method:               "DELETE",
+		url:                  url,
+		allowCompleteFailure: allowCompleteFailure,
+		silentErrors:         silentErrors,
+	})
+
+	return err
+}
+
+// DeleteRequestAnsweringTo is like DeleteRequestTo, but returns a *StatusError without retrying when the server responds with one of the answer statuses, e.g. when it refuses the deletion
+func (httpClient Client) DeleteRequestAnsweringTo(url string, answerStatuses []int, allowCompleteFailure bool, silentErrors bool) error {
+	_, _, err := httpClient.do(requestOptions{
+		method:               "DELETE",
+		url:                  url,
+		allowCompleteFailure: allowCompleteFailure,
+		silentErrors:         silentErrors,
+		answerStatuses:       answerStatuses,
+	})
+
+	return err
+}
+
+// DeleteRequestWithPayloadTo does a DELETE request with a json body and retries a few times on error
+func (httpClient Client) DeleteRequestWithPayloadTo(url string, jsonPayload []byte, allowCompleteFailure bool, silentErrors bool) error {
+	_, _, err := httpClient.do(requestOptions{
+		method:               "DELETE",
+		url:                  url,
+		payload:              jsonPayload,
+		allowCompleteFailure: allowCompleteFailure,
+		silentErrors:         silentErrors,
+	})
+
+	return err
 }
 
 // NewClientParams is the parameters required to build a new client
 type NewClientParams struct {
 	BaseURL             string
 	InjectAuthInRequest InjectAuthInRequest
+	RefreshAuth         RefreshAuth
 }
 
 // NewClient is building a new client
@@ -210,5 +311,28 @@ func NewClient(params NewClientParams) Client {
 		BaseURL:             params.BaseURL,
 		realClient:          &http.Client{},
 		InjectAuthInRequest: params.InjectAuthInRequest,
+		RefreshAuth:         params.RefreshAuth,
+	}
+}
+
+// NextLink extracts the URL of the next page from a `Link: <url>; rel="next"` response header, as used for pagination by many registry APIs; it returns an empty string when there are no more pages
+func NextLink(headers http.Header) string {
+	for _, link := range headers.Values("Link") {
+		for _, part := range strings.Split(link, ",") {
+			sections := strings.Split(part, ";")
+
+			if len(sections) < 2 {
+				continue
+			}
+
+			for _, param := range sections[1:] {
+				param = strings.ReplaceAll(strings.TrimSpace(param), " ", "")
+				if param == `rel="next"` || param == "rel=next" {
+					return strings.Trim(strings.TrimSpace(sections[0]), "<>")
+				}
+			}
+		}
 	}
+
+	return ""
 }
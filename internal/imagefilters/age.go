@@ -36,11 +36,16 @@ func ageFilter(repos []containerregistry.Repository, keepYoungerThan string) {
 		for imageIndex := range repos[repoIndex].Images {
 			parsedImage := repos[repoIndex].Images[imageIndex]
 
-			if parsedImage.KeptData.Reason != keepreasons.None {
+			if parsedImage.KeptData.Reason.IsKept() {
 				// image already kept for some other reason
 				continue
 			}
 
+			if parsedImage.TimeUploadedMs == "" {
+				// the age of the image is unknown, e.g. an image index without any dated platform manifest, so it is left to the other rules
+				continue
+			}
+
 			uploadedMs, err := strconv.ParseInt(parsedImage.TimeUploadedMs, 10, 64)
 
 			if err != nil {
@@ -52,7 +57,67 @@ func ageFilter(repos []containerregistry.Repository, keepYoungerThan string) {
 
 			if ageMs < youngerDurationMs {
 				// image young enough, needs to be kept
-				repos[repoIndex].Images[imageIndex].KeptData.Reason = keepreasons.Young
+				repos[repoIndex].Images[imageIndex].KeptData = keepreasons.KeptData{Reason: keepreasons.Young}
+			}
+		}
+	}
+}
+
+// expirationFilter leaves alone the images that the registry is going to delete on its own once they expire, e.g. quay tags with an expiration
+func expirationFilter(repos []containerregistry.Repository) {
+	nowMs := getMsTime()
+
+	for repoIndex := range repos {
+		for imageIndex := range repos[repoIndex].Images {
+			parsedImage := repos[repoIndex].Images[imageIndex]
+
+			if parsedImage.KeptData.Reason.IsKept() || parsedImage.TimeExpiresMs == "" {
+				continue
+			}
+
+			expiresMs, err := strconv.ParseInt(parsedImage.TimeExpiresMs, 10, 64)
+
+			if err != nil {
+				log.Errorf("Image %v contains invalid time expires field: %v", parsedImage.Digest, parsedImage.TimeExpiresMs)
+				continue
+			}
+
+			if nowMs < expiresMs {
+				repos[repoIndex].Images[imageIndex].KeptData = keepreasons.KeptData{
+					Reason:   keepreasons.Expiring,
+					Metadata: time.UnixMilli(expiresMs).UTC().Format(time.RFC3339),
+				}
+			}
+		}
+	}
+}
+
+// pullFilter keeps the images that were pulled within the given duration; images that have never been pulled are left to the other rules
+func pullFilter(repos []containerregistry.Repository, keepPulledWithin string) {
+	if keepPulledWithin == "" {
+		return
+	}
+
+	nowMs := getMsTime()
+	pulledWithinMs := getStringDurationInMs(keepPulledWithin)
+
+	for repoIndex := range repos {
+		for imageIndex := range repos[repoIndex].Images {
+			parsedImage := repos[repoIndex].Images[imageIndex]
+
+			if parsedImage.KeptData.Reason.IsKept() || parsedImage.TimeLastPulledMs == "" {
+				continue
+			}
+
+			lastPulledMs, err := strconv.ParseInt(parsedImage.TimeLastPulledMs, 10, 64)
+
+			if err != nil {
+				log.Errorf("Image %v contains invalid time last pulled field: %v", parsedImage.Digest, parsedImage.TimeLastPulledMs)
+				continue
+			}
+
+			if nowMs-lastPulledMs < pulledWithinMs {
+				repos[repoIndex].Images[imageIndex].KeptData = keepreasons.KeptData{Reason: keepreasons.RecentlyPulled}
 			}
 		}
 	}
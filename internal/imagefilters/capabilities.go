@@ -0,0 +1,91 @@
+package imagefilters
+
+import (
+	"errors"
+
+	"github.com/hytromo/faulty-crane/internal/configuration"
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+// CheckCapabilities returns an error for the first policy of the options that the registry cannot honour, so that it is refused when planning instead of doing nothing when applying
+func CheckCapabilities(capabilities containerregistry.Capabilities, options configuration.ApplyPlanCommonSubcommandOptions) error {
+	cleanup := options.DeleteRepositories
+
+	if (cleanup.Empty || cleanup.InactiveFor != "") && !capabilities.DeleteRepository {
+		return errors.New("the registry cannot delete repositories, please remove the repository cleanup from its options")
+	}
+
+	if usesPullTimes(options.Keep) && !capabilities.LastPulled {
+		return errors.New("the registry does not track when the images were pulled, please remove the rule about pulled images from its options")
+	}
+
+	if options.Enrich && !capabilities.FetchManifests {
+		return errors.New("the registry cannot fetch the manifests of the images, please disable the enrichment in its options")
+	}
+
+	return nil
+}
+
+// usesPullTimes returns if the keep rules, including the ones of the path rules, need the time the images were last pulled
+func usesPullTimes(keep configuration.KeepImages) bool {
+	if keep.PulledWithin != "" {
+		return true
+	}
+
+	for _, path := range keep.Paths {
+		if usesPullTimes(path.Keep) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// KeepUndeletable keeps the untagged images that are planned to be deleted when the registry can only delete images through their tags, as deleting them would silently do nothing
+func KeepUndeletable(repos []containerregistry.Repository, capabilities containerregistry.Capabilities) {
+	if capabilities.DeleteByDigest {
+		return
+	}
+
+	for repoIndex := range repos {
+		for imageIndex, image := range repos[repoIndex].Images {
+			if image.KeptData.Reason.IsKept() || len(image.Tag) > 0 {
+				continue
+			}
+
+			repos[repoIndex].Images[imageIndex].KeptData = keepreasons.KeptData{Reason: keepreasons.Undeletable}
+		}
+	}
+}
+
+// KeepUnlinkedManifests keeps the untagged images of the repositories with a kept image index when the registry does not link the platform manifests to their indexes, as they could be the platform manifests of the kept index
+func KeepUnlinkedManifests(repos []containerregistry.Repository, capabilities containerregistry.Capabilities) {
+	if capabilities.ManifestLists || !capabilities.DeleteByDigest {
+		// either the platform manifests follow their indexes or the untagged images are not deleted anyway
+		return
+	}
+
+	for repoIndex := range repos {
+		hasKeptIndex := false
+
+		for _, image := range repos[repoIndex].Images {
+			if image.IsIndex() && image.KeptData.Reason.IsKept() {
+				hasKeptIndex = true
+				break
+			}
+		}
+
+		if !hasKeptIndex {
+			continue
+		}
+
+		for imageIndex, image := range repos[repoIndex].Images {
+			if image.KeptData.Reason.IsKept() || len(image.Tag) > 0 || image.IsIndex() {
+				continue
+			}
+
+			repos[repoIndex].Images[imageIndex].KeptData = keepreasons.KeptData{Reason: keepreasons.UnlinkedManifest}
+		}
+	}
+}
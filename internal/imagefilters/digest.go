@@ -10,23 +10,22 @@ func digestFilter(repos []containerregistry.Repository, digestsToKeep []string)
 		return
 	}
 
-	// let's create a map for the digests so we don't do O(n) every time we are searching to see if a digest is whitelisted
-	digestsToKeepMap := make(map[string]bool)
-	for _, digest := range digestsToKeep {
-		digestsToKeepMap[digest] = true
-	}
+	// the exact digests go in a map so we don't do O(n) every time we are searching to see if a digest is whitelisted, the patterns are compiled once
+	digestsToKeepList := newKeepList(digestsToKeep)
 
 	for repoIndex := range repos {
 		for imageIndex, parsedImage := range repos[repoIndex].Images {
-			if parsedImage.KeptData.Reason != keepreasons.None {
+			if parsedImage.KeptData.Reason.IsKept() {
 				// image already kept for some other reason
 				continue
 			}
 
 			for _, digest := range parsedImage.Digest {
-				_, exists := digestsToKeepMap[digest]
+				// the metadata show which pattern the digest was kept for
+				pattern, exists := digestsToKeepList.match(digest)
 				if exists {
 					repos[repoIndex].Images[imageIndex].KeptData.Reason = keepreasons.WhitelistedDigest
+					repos[repoIndex].Images[imageIndex].KeptData.Metadata = pattern
 					break
 				}
 			}
@@ -1,21 +1,69 @@
 package imagefilters
 
 import (
+	"fmt"
+
 	"github.com/hytromo/faulty-crane/internal/configuration"
 	"github.com/hytromo/faulty-crane/internal/containerregistry"
 )
 
 // Parse takes all the container images and the filters dictated by the user and applies the filters to the images
 func Parse(repos []containerregistry.Repository, keepImages configuration.KeepImages) []containerregistry.Repository {
+	return ParseWithScan(repos, keepImages, NewKubernetesScan())
+}
+
+// ParseWithScan is like Parse, but reuses the kubernetes scan of other registries of the same run
+func ParseWithScan(repos []containerregistry.Repository, keepImages configuration.KeepImages, scan *KubernetesScan) []containerregistry.Repository {
 	parsedRepos := make([]containerregistry.Repository, len(repos))
 	copy(parsedRepos, repos)
 
-	repoFilter(parsedRepos, keepImages.Image.Repositories)
-	ageFilter(parsedRepos, keepImages.YoungerThan)
-	tagFilter(parsedRepos, keepImages.Image.Tags)
-	digestFilter(parsedRepos, keepImages.Image.Digests)
-	k8sFilter(parsedRepos, keepImages.UsedIn.KubernetesClusters)
-	numberFilter(parsedRepos, keepImages.AtLeast)
+	// the repositories under the same path rule share the same keep rules, so they are filtered together
+	groupKeepImages := map[string]configuration.KeepImages{}
+	groupRepoIndexes := map[string][]int{}
+	groupKeys := []string{}
+
+	for repoIndex, repo := range parsedRepos {
+		repoKeepImages := keepImages.ForPath(repo)
+		// the count is formatted instead of its pointer, so that equal rules share a key
+		keyKeepImages := repoKeepImages
+		keyKeepImages.AtLeast = nil
+		key := fmt.Sprintf("%+v %v", keyKeepImages, repoKeepImages.AtLeastCount())
+
+		if _, exists := groupKeepImages[key]; !exists {
+			groupKeepImages[key] = repoKeepImages
+			groupKeys = append(groupKeys, key)
+		}
+
+		groupRepoIndexes[key] = append(groupRepoIndexes[key], repoIndex)
+	}
+
+	for _, key := range groupKeys {
+		groupRepos := make([]containerregistry.Repository, len(groupRepoIndexes[key]))
+
+		for i, repoIndex := range groupRepoIndexes[key] {
+			groupRepos[i] = parsedRepos[repoIndex]
+		}
+
+		applyFilters(groupRepos, groupKeepImages[key], scan)
+
+		for i, repoIndex := range groupRepoIndexes[key] {
+			parsedRepos[repoIndex] = groupRepos[i]
+		}
+	}
 
 	return parsedRepos
 }
+
+func applyFilters(repos []containerregistry.Repository, keepImages configuration.KeepImages, scan *KubernetesScan) {
+	repoFilter(repos, keepImages.Image.Repositories)
+	ageFilter(repos, keepImages.YoungerThan)
+	expirationFilter(repos)
+	pullFilter(repos, keepImages.PulledWithin)
+	tagFilter(repos, keepImages.Image.Tags)
+	digestFilter(repos, keepImages.Image.Digests)
+	k8sFilter(repos, keepImages.UsedIn.KubernetesClusters, scan)
+	numberFilter(repos, keepImages.AtLeastCount())
+	// last, so that the platform manifests and the referrers follow the final decision of the images that they belong to
+	indexFilter(repos)
+	referrerFilter(repos)
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"log"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -13,6 +14,10 @@ import (
 	"github.com/hytromo/faulty-crane/internal/keepreasons"
 )
 
+func atLeast(count int) *int {
+	return &count
+}
+
 func TestParse(t *testing.T) {
 	testReposPath := "../../test/repos.json"
 	configBytes, err := ioutil.ReadFile(testReposPath)
@@ -42,7 +47,7 @@ func TestParse(t *testing.T) {
 
 	parsedRepos := Parse(repos, configuration.KeepImages{
 		YoungerThan: "2d",
-		AtLeast:     1,
+		AtLeast:     atLeast(1),
 		UsedIn: configuration.UsedIn{
 			KubernetesClusters: []configuration.KubernetesCluster{},
 		},
@@ -140,7 +145,7 @@ func TestParse2(t *testing.T) {
 
 	parsedRepos := Parse(repos, configuration.KeepImages{
 		YoungerThan: "2d",
-		AtLeast:     0,
+		AtLeast:     atLeast(0),
 		UsedIn: configuration.UsedIn{
 			KubernetesClusters: []configuration.KubernetesCluster{},
 		},
@@ -237,7 +242,7 @@ func TestParse3(t *testing.T) {
 
 	parsedRepos := Parse(repos, configuration.KeepImages{
 		YoungerThan: "2d",
-		AtLeast:     10,
+		AtLeast:     atLeast(10),
 		UsedIn: configuration.UsedIn{
 			KubernetesClusters: []configuration.KubernetesCluster{},
 		},
@@ -269,3 +274,532 @@ func TestParse3(t *testing.T) {
 		t.Errorf("Exactly 0 images should be deleted, not %v", deletedCount)
 	}
 }
+
+func TestAgeFilterExpiration(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+	oldMs := strconv.FormatInt(nowMs-10*24*3600*1000, 10)
+	recentMs := strconv.FormatInt(nowMs, 10)
+	expiresMs := strconv.FormatInt(nowMs+3600*1000, 10)
+
+	repos := []containerregistry.Repository{
+		{
+			Link: "hytromo/expiring",
+			Images: []containerregistry.ContainerImage{
+				{Digest: []string{"sha256:old-expires-later"}, TimeUploadedMs: oldMs, TimeExpiresMs: expiresMs},
+				{Digest: []string{"sha256:recent-expires-later"}, TimeUploadedMs: recentMs, TimeExpiresMs: expiresMs},
+				{Digest: []string{"sha256:recent"}, TimeUploadedMs: recentMs},
+				{Digest: []string{"sha256:old"}, TimeUploadedMs: oldMs},
+			},
+		},
+	}
+
+	applyFilters(repos, configuration.KeepImages{YoungerThan: "2d", AtLeast: atLeast(3)}, nil)
+
+	expectedReasons := map[string]keepreasons.KeptReason{
+		"sha256:old-expires-later":    keepreasons.Expiring,
+		"sha256:recent-expires-later": keepreasons.Young,
+		"sha256:recent":               keepreasons.Young,
+		// the image that expires does not count towards the images to keep at least
+		"sha256:old": keepreasons.OneOfFew,
+	}
+
+	for _, image := range repos[0].Images {
+		if image.KeptData.Reason != expectedReasons[image.Digest[0]] {
+			t.Errorf("Image %v should be kept for reason %v, not %v", image.Digest[0], expectedReasons[image.Digest[0]], image.KeptData.Reason)
+		}
+	}
+}
+
+func TestUnknownAge(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+
+	repos := []containerregistry.Repository{
+		{
+			Link: "hytromo/index",
+			Images: []containerregistry.ContainerImage{
+				{Digest: []string{"sha256:undated"}},
+				{Digest: []string{"sha256:old"}, TimeUploadedMs: strconv.FormatInt(nowMs-10*24*3600*1000, 10)},
+			},
+		},
+	}
+
+	ageFilter(repos, "2d")
+
+	if repos[0].Images[0].KeptData.Reason != keepreasons.None {
+		t.Error("An image of unknown age should be left to the other rules")
+	}
+
+	numberFilter(repos, 1)
+
+	for _, image := range repos[0].Images {
+		if (image.KeptData.Reason == keepreasons.OneOfFew) != (image.Digest[0] == "sha256:old") {
+			t.Errorf("Images of unknown age should count as the oldest ones, got %v for %v", image.KeptData.Reason, image.Digest[0])
+		}
+	}
+}
+
+func TestOrphanedReferrerIsNotKept(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+	orphaned := keepreasons.KeptData{Reason: keepreasons.OrphanedReferrer, Metadata: "sha256:gone"}
+
+	repos := []containerregistry.Repository{
+		{
+			Link: "hytromo/orphans",
+			Images: []containerregistry.ContainerImage{
+				{Digest: []string{"sha256:tagged"}, Tag: []string{"release"}, TimeUploadedMs: strconv.FormatInt(nowMs-10*24*3600*1000, 10), KeptData: orphaned},
+				{Digest: []string{"sha256:recent"}, TimeUploadedMs: strconv.FormatInt(nowMs, 10), KeptData: orphaned},
+				{Digest: []string{"sha256:old"}, TimeUploadedMs: strconv.FormatInt(nowMs-20*24*3600*1000, 10), KeptData: orphaned},
+			},
+		},
+	}
+
+	ageFilter(repos, "2d")
+	tagFilter(repos, []string{"release"})
+	numberFilter(repos, 3)
+
+	expectedKeptData := map[string]keepreasons.KeptData{
+		"sha256:tagged": {Reason: keepreasons.WhitelistedTag},
+		"sha256:recent": {Reason: keepreasons.Young},
+		// the orphaned referrers do not count as already kept
+		"sha256:old": {Reason: keepreasons.OneOfFew},
+	}
+
+	for _, image := range repos[0].Images {
+		if image.KeptData != expectedKeptData[image.Digest[0]] {
+			t.Errorf("Image %v should be kept with %+v, not %+v", image.Digest[0], expectedKeptData[image.Digest[0]], image.KeptData)
+		}
+	}
+}
+
+func TestPathKeepImages(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+
+	imagesOf := func(count int) []containerregistry.ContainerImage {
+		images := []containerregistry.ContainerImage{}
+
+		for i := 0; i < count; i++ {
+			images = append(images, containerregistry.ContainerImage{
+				Digest:         []string{"sha256:" + strconv.Itoa(i)},
+				TimeUploadedMs: strconv.FormatInt(nowMs-int64(i+10)*24*3600*1000, 10),
+			})
+		}
+
+		return images
+	}
+
+	repos := []containerregistry.Repository{
+		{Link: "gcr.io/project/team-a/service", Host: "gcr.io", Images: imagesOf(5)},
+		{Link: "gcr.io/project/team-a/critical/db", Host: "gcr.io", Images: imagesOf(5)},
+		{Link: "gcr.io/project/team-b/service", Host: "gcr.io", Images: imagesOf(5)},
+		{Link: "gcr.io/project/team-c/service", Host: "gcr.io", Images: imagesOf(5)},
+	}
+
+	parsedRepos := Parse(repos, configuration.KeepImages{
+		AtLeast: atLeast(1),
+		Image: configuration.Image{
+			Repositories: []string{"project/team-c/**"},
+		},
+		Paths: []configuration.PathKeepImages{
+			{Path: "project/team-a/**", Keep: configuration.KeepImages{AtLeast: atLeast(3)}},
+			{Path: "project/team-a/critical/**", Keep: configuration.KeepImages{Image: configuration.Image{Repositories: []string{"project/team-a/critical/**"}}}},
+		},
+	})
+
+	keptCounts := map[string]int{}
+
+	for _, repo := range parsedRepos {
+		for _, image := range repo.Images {
+			if image.KeptData.Reason != keepreasons.None {
+				keptCounts[repo.Link]++
+			}
+		}
+	}
+
+	expectedKeptCounts := map[string]int{
+		// the rule of the team overrides the general rule
+		"gcr.io/project/team-a/service": 3,
+		// the most specific rule wins
+		"gcr.io/project/team-a/critical/db": 5,
+		"gcr.io/project/team-b/service":     1,
+		// whitelisted path prefix
+		"gcr.io/project/team-c/service": 5,
+	}
+
+	for link, expectedKeptCount := range expectedKeptCounts {
+		if keptCounts[link] != expectedKeptCount {
+			t.Errorf("Repo %v should keep %v images, not %v", link, expectedKeptCount, keptCounts[link])
+		}
+	}
+
+	if parsedRepos[3].Images[0].KeptData.Metadata != "project/team-c/**" {
+		t.Errorf("The whitelisted prefix should be recorded, not %v", parsedRepos[3].Images[0].KeptData.Metadata)
+	}
+}
+
+func TestIndexFilter(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+	oldMs := strconv.FormatInt(nowMs-10*24*3600*1000, 10)
+	recentMs := strconv.FormatInt(nowMs, 10)
+
+	repos := []containerregistry.Repository{
+		{
+			Link: "hytromo/multi-arch",
+			Images: []containerregistry.ContainerImage{
+				{Digest: []string{"sha256:kept-index"}, Tag: []string{"stable"}, TimeUploadedMs: oldMs, Children: []string{"sha256:kept-amd64"}},
+				{Digest: []string{"sha256:kept-amd64"}, TimeUploadedMs: oldMs, Parents: []string{"sha256:kept-index"}},
+				{Digest: []string{"sha256:old-index"}, TimeUploadedMs: oldMs, Children: []string{"sha256:old-amd64", "sha256:pinned-arm64"}},
+				{Digest: []string{"sha256:old-amd64"}, TimeUploadedMs: recentMs, Parents: []string{"sha256:old-index"}},
+				{Digest: []string{"sha256:pinned-arm64"}, TimeUploadedMs: oldMs, Parents: []string{"sha256:old-index"}},
+			},
+		},
+	}
+
+	parsedRepos := Parse(repos, configuration.KeepImages{
+		YoungerThan: "2d",
+		AtLeast:     atLeast(1),
+		Image: configuration.Image{
+			Tags:    []string{"stable"},
+			Digests: []string{"sha256:pinned-arm64"},
+		},
+	})
+
+	expectedReasons := map[string]keepreasons.KeptReason{
+		"sha256:kept-index": keepreasons.WhitelistedTag,
+		// the platform manifest of a kept index is kept even though it is old
+		"sha256:kept-amd64": keepreasons.PartOfKeptIndex,
+		// the index does not count towards keeping at least one image, as the tagged index is already kept
+		"sha256:old-index": keepreasons.None,
+		// the platform manifest of a deleted index is deleted along with it, even though it is young
+		"sha256:old-amd64": keepreasons.None,
+		// explicitly kept manifests keep their own reason
+		"sha256:pinned-arm64": keepreasons.WhitelistedDigest,
+	}
+
+	for _, image := range parsedRepos[0].Images {
+		if image.KeptData.Reason != expectedReasons[image.Digest[0]] {
+			t.Errorf("Image %v should have keep reason %v, not %v", image.Digest[0], expectedReasons[image.Digest[0]], image.KeptData.Reason)
+		}
+	}
+}
+
+func TestReferrerFilter(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+	oldMs := strconv.FormatInt(nowMs-10*24*3600*1000, 10)
+	recentMs := strconv.FormatInt(nowMs, 10)
+
+	keptDigest := "sha256:" + strings.Repeat("a", 64)
+	deletedDigest := "sha256:" + strings.Repeat("b", 64)
+	goneDigest := "sha256:" + strings.Repeat("c", 64)
+
+	repos := []containerregistry.Repository{
+		{
+			Link: "hytromo/signed",
+			Images: []containerregistry.ContainerImage{
+				{Digest: []string{keptDigest}, Tag: []string{"stable"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:kept-sig"}, Tag: []string{"sha256-" + strings.Repeat("a", 64) + ".sig"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:kept-sbom"}, Subject: keptDigest, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:kept-sbom-sig"}, Subject: "sha256:kept-sbom", TimeUploadedMs: oldMs},
+				{Digest: []string{deletedDigest}, Tag: []string{"old"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:deleted-sig"}, Tag: []string{"sha256-" + strings.Repeat("b", 64) + ".sig"}, TimeUploadedMs: recentMs},
+				{Digest: []string{"sha256:orphan-sig"}, Tag: []string{"sha256-" + strings.Repeat("c", 64) + ".sig"}, TimeUploadedMs: recentMs},
+			},
+		},
+	}
+
+	parsedRepos := Parse(repos, configuration.KeepImages{
+		YoungerThan: "2d",
+		AtLeast:     atLeast(1),
+		Image: configuration.Image{
+			Tags: []string{"stable"},
+		},
+	})
+
+	expectedKeptData := map[string]keepreasons.KeptData{
+		keptDigest:             {Reason: keepreasons.WhitelistedTag},
+		"sha256:kept-sig":      {Reason: keepreasons.ReferrerOfKept, Metadata: keptDigest},
+		"sha256:kept-sbom":     {Reason: keepreasons.ReferrerOfKept, Metadata: keptDigest},
+		"sha256:kept-sbom-sig": {Reason: keepreasons.ReferrerOfKept, Metadata: "sha256:kept-sbom"},
+		deletedDigest:          {},
+		// the referrer goes away along with its subject, even though it is young
+		"sha256:deleted-sig": {},
+		"sha256:orphan-sig":  {Reason: keepreasons.OrphanedReferrer, Metadata: goneDigest},
+	}
+
+	for _, image := range parsedRepos[0].Images {
+		if image.KeptData != expectedKeptData[image.Digest[0]] {
+			t.Errorf("Image %v should have keep data %+v, not %+v", image.Digest[0], expectedKeptData[image.Digest[0]], image.KeptData)
+		}
+	}
+
+	if keepreasons.OrphanedReferrer.IsKept() || !keepreasons.ReferrerOfKept.IsKept() {
+		t.Error("Orphaned referrers should be deleted")
+	}
+}
+
+func TestPlanRepositoryDeletions(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+	oldMs := strconv.FormatInt(nowMs-400*24*3600*1000, 10)
+	recentMs := strconv.FormatInt(nowMs, 10)
+
+	repos := []containerregistry.Repository{
+		{
+			Link:   "hytromo/empty",
+			Images: []containerregistry.ContainerImage{},
+		},
+		{
+			// the registry knows better than the upload times of the images
+			Link:              "hytromo/inactive",
+			TimeLastUpdatedMs: oldMs,
+			Images:            []containerregistry.ContainerImage{{Digest: []string{"sha256:inactive"}, Tag: []string{"latest"}, TimeUploadedMs: recentMs}},
+		},
+		{
+			Link:   "hytromo/whitelisted",
+			Images: []containerregistry.ContainerImage{{Digest: []string{"sha256:whitelisted"}, Tag: []string{"stable"}, TimeUploadedMs: oldMs}},
+		},
+		{
+			Link:   "hytromo/active",
+			Images: []containerregistry.ContainerImage{{Digest: []string{"sha256:active"}, Tag: []string{"latest"}, TimeUploadedMs: recentMs}},
+		},
+	}
+
+	parsedRepos := Parse(repos, configuration.KeepImages{
+		AtLeast: atLeast(1),
+		Image: configuration.Image{
+			Tags: []string{"stable"},
+		},
+	})
+
+	PlanRepositoryDeletions(parsedRepos, configuration.RepositoryCleanup{
+		Empty:       true,
+		InactiveFor: "1y",
+	})
+
+	expectedReasons := map[string]containerregistry.RepositoryDeletionReason{
+		"hytromo/empty":       containerregistry.RepositoryEmpty,
+		"hytromo/inactive":    containerregistry.RepositoryInactive,
+		"hytromo/whitelisted": "",
+		"hytromo/active":      "",
+	}
+
+	for _, repo := range parsedRepos {
+		if repo.DeletionReason != expectedReasons[repo.Link] {
+			t.Errorf("Repository %v should have deletion reason %q, not %q", repo.Link, expectedReasons[repo.Link], repo.DeletionReason)
+		}
+	}
+
+	// the image kept only to keep at least one goes away along with its inactive repository
+	if parsedRepos[1].Images[0].KeptData.Reason.IsKept() {
+		t.Errorf("The images of an inactive repository should be deleted, not kept with %+v", parsedRepos[1].Images[0].KeptData)
+	}
+
+	if !parsedRepos[3].Images[0].KeptData.Reason.IsKept() {
+		t.Error("The images of an active repository should still be kept")
+	}
+}
+
+func TestCheckCapabilities(t *testing.T) {
+	options := configuration.ApplyPlanCommonSubcommandOptions{
+		DeleteRepositories: configuration.RepositoryCleanup{Empty: true},
+	}
+
+	if CheckCapabilities(containerregistry.Capabilities{DeleteByDigest: true}, options) == nil {
+		t.Error("Deleting repositories should be refused by registries that cannot do it")
+	}
+
+	if err := CheckCapabilities(containerregistry.Capabilities{DeleteTagsOnly: true, DeleteRepository: true}, options); err != nil {
+		t.Errorf("Deleting repositories should be allowed, got %v", err)
+	}
+
+	if CheckCapabilities(containerregistry.Capabilities{DeleteByDigest: true}, configuration.ApplyPlanCommonSubcommandOptions{DeleteRepositories: configuration.RepositoryCleanup{InactiveFor: "1y"}}) == nil {
+		t.Error("Deleting inactive repositories should be refused by registries that cannot delete repositories")
+	}
+
+	if CheckCapabilities(containerregistry.Capabilities{DeleteByDigest: true}, configuration.ApplyPlanCommonSubcommandOptions{Enrich: true}) == nil {
+		t.Error("The enrichment should be refused by registries that cannot fetch manifests")
+	}
+
+	if err := CheckCapabilities(containerregistry.Capabilities{DeleteByDigest: true, FetchManifests: true}, configuration.ApplyPlanCommonSubcommandOptions{Enrich: true}); err != nil {
+		t.Errorf("The enrichment should be allowed, got %v", err)
+	}
+
+	pulledWithinPath := configuration.ApplyPlanCommonSubcommandOptions{
+		Keep: configuration.KeepImages{Paths: []configuration.PathKeepImages{{Path: "team/**", Keep: configuration.KeepImages{PulledWithin: "30d"}}}},
+	}
+
+	if CheckCapabilities(containerregistry.Capabilities{DeleteByDigest: true}, pulledWithinPath) == nil {
+		t.Error("Keeping pulled images should be refused by registries that do not track pulls")
+	}
+
+	if err := CheckCapabilities(containerregistry.Capabilities{DeleteByDigest: true, LastPulled: true}, pulledWithinPath); err != nil {
+		t.Errorf("Keeping pulled images should be allowed, got %v", err)
+	}
+
+	if err := CheckCapabilities(containerregistry.Capabilities{DeleteTagsOnly: true}, configuration.ApplyPlanCommonSubcommandOptions{}); err != nil {
+		t.Errorf("Options without repository cleanup or enrichment should be allowed, got %v", err)
+	}
+}
+
+func TestKeepUndeletable(t *testing.T) {
+	reposOf := func() []containerregistry.Repository {
+		return []containerregistry.Repository{
+			{
+				Link: "hytromo/tags-only",
+				Images: []containerregistry.ContainerImage{
+					{Digest: []string{"sha256:tagged"}, Tag: []string{"old"}},
+					{Digest: []string{"sha256:untagged"}},
+					{Digest: []string{"sha256:kept"}, KeptData: keepreasons.KeptData{Reason: keepreasons.PartOfKeptIndex, Metadata: "sha256:index"}},
+					{Digest: []string{"sha256:orphan"}, KeptData: keepreasons.KeptData{Reason: keepreasons.OrphanedReferrer, Metadata: "sha256:gone"}},
+				},
+			},
+		}
+	}
+
+	repos := reposOf()
+	KeepUndeletable(repos, containerregistry.Capabilities{DeleteTagsOnly: true})
+
+	expectedReasons := map[string]keepreasons.KeptReason{
+		"sha256:tagged":   keepreasons.None,
+		"sha256:untagged": keepreasons.Undeletable,
+		"sha256:kept":     keepreasons.PartOfKeptIndex,
+		"sha256:orphan":   keepreasons.Undeletable,
+	}
+
+	for _, image := range repos[0].Images {
+		if image.KeptData.Reason != expectedReasons[image.Digest[0]] {
+			t.Errorf("Image %v should be kept for reason %v, not %v", image.Digest[0], expectedReasons[image.Digest[0]], image.KeptData.Reason)
+		}
+	}
+
+	repos = reposOf()
+	KeepUndeletable(repos, containerregistry.Capabilities{DeleteByDigest: true})
+
+	if repos[0].Images[1].KeptData.Reason != keepreasons.None {
+		t.Error("Untagged images should be deleted by registries that delete by digest")
+	}
+}
+
+func TestKeepUnlinkedManifests(t *testing.T) {
+	reposOf := func() []containerregistry.Repository {
+		return []containerregistry.Repository{
+			{
+				Link: "hytromo/multi-arch",
+				Images: []containerregistry.ContainerImage{
+					{Digest: []string{"sha256:index"}, Tag: []string{"v1"}, MediaType: "application/vnd.oci.image.index.v1+json", KeptData: keepreasons.KeptData{Reason: keepreasons.WhitelistedTag}},
+					{Digest: []string{"sha256:old-index"}, MediaType: "application/vnd.oci.image.index.v1+json"},
+					{Digest: []string{"sha256:untagged"}},
+					{Digest: []string{"sha256:tagged"}, Tag: []string{"old"}},
+				},
+			},
+			{
+				Link: "hytromo/single-arch",
+				Images: []containerregistry.ContainerImage{
+					{Digest: []string{"sha256:single"}, Tag: []string{"latest"}, KeptData: keepreasons.KeptData{Reason: keepreasons.OneOfFew}},
+					{Digest: []string{"sha256:dangling"}},
+				},
+			},
+		}
+	}
+
+	repos := reposOf()
+	KeepUnlinkedManifests(repos, containerregistry.Capabilities{DeleteByDigest: true})
+
+	expectedReasons := map[string]keepreasons.KeptReason{
+		"sha256:index":     keepreasons.WhitelistedTag,
+		"sha256:old-index": keepreasons.None,
+		"sha256:untagged":  keepreasons.UnlinkedManifest,
+		"sha256:tagged":    keepreasons.None,
+		"sha256:single":    keepreasons.OneOfFew,
+		"sha256:dangling":  keepreasons.None,
+	}
+
+	for _, repo := range repos {
+		for _, image := range repo.Images {
+			if image.KeptData.Reason != expectedReasons[image.Digest[0]] {
+				t.Errorf("Image %v should be kept for reason %v, not %v", image.Digest[0], expectedReasons[image.Digest[0]], image.KeptData.Reason)
+			}
+		}
+	}
+
+	repos = reposOf()
+	KeepUnlinkedManifests(repos, containerregistry.Capabilities{DeleteByDigest: true, ManifestLists: true})
+
+	if repos[0].Images[2].KeptData.Reason != keepreasons.None {
+		t.Error("Untagged images should be left to the other rules by registries that link the platform manifests")
+	}
+}
+
+func TestPullFilter(t *testing.T) {
+	nowMs := time.Now().UnixMilli()
+	repos := []containerregistry.Repository{
+		{
+			Link: "hytromo/pulled",
+			Images: []containerregistry.ContainerImage{
+				{Digest: []string{"sha256:recent"}, TimeLastPulledMs: strconv.FormatInt(nowMs-24*3600*1000, 10)},
+				{Digest: []string{"sha256:stale"}, TimeLastPulledMs: strconv.FormatInt(nowMs-60*24*3600*1000, 10)},
+				{Digest: []string{"sha256:never"}},
+			},
+		},
+	}
+
+	pullFilter(repos, "30d")
+
+	expectedReasons := map[string]keepreasons.KeptReason{
+		"sha256:recent": keepreasons.RecentlyPulled,
+		"sha256:stale":  keepreasons.None,
+		"sha256:never":  keepreasons.None,
+	}
+
+	for _, image := range repos[0].Images {
+		if image.KeptData.Reason != expectedReasons[image.Digest[0]] {
+			t.Errorf("Image %v should be kept for reason %v, not %v", image.Digest[0], expectedReasons[image.Digest[0]], image.KeptData.Reason)
+		}
+	}
+}
+
+func TestKeepPatterns(t *testing.T) {
+	oldMs := strconv.FormatInt(time.Now().UnixMilli()-10*24*3600*1000, 10)
+
+	repos := []containerregistry.Repository{
+		{
+			Link: "hytromo/app",
+			Images: []containerregistry.ContainerImage{
+				{Digest: []string{"sha256:semver"}, Tag: []string{"v1.2.3"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:release"}, Tag: []string{"release-2026.10"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:prod"}, Tag: []string{"prod-eu"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:exact"}, Tag: []string{"stable"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:pinned-1"}, Tag: []string{"feature"}, TimeUploadedMs: oldMs},
+				{Digest: []string{"sha256:other"}, Tag: []string{"v1.2"}, TimeUploadedMs: oldMs},
+			},
+		},
+		{
+			Link:   "hytromo/tools-ci",
+			Images: []containerregistry.ContainerImage{{Digest: []string{"sha256:tool"}, Tag: []string{"latest"}, TimeUploadedMs: oldMs}},
+		},
+	}
+
+	parsedRepos := Parse(repos, configuration.KeepImages{
+		Image: configuration.Image{
+			Tags:         []string{`re:v\d+\.\d+\.\d+`, "glob:release-*", "prod-*", "stable"},
+			Digests:      []string{"sha256:pinned-?"},
+			Repositories: []string{"hytromo/tools-*"},
+		},
+	})
+
+	expectedKeptData := map[string]keepreasons.KeptData{
+		"sha256:semver":   {Reason: keepreasons.WhitelistedTag, Metadata: `re:v\d+\.\d+\.\d+`},
+		"sha256:release":  {Reason: keepreasons.WhitelistedTag, Metadata: "glob:release-*"},
+		"sha256:prod":     {Reason: keepreasons.WhitelistedTag, Metadata: "prod-*"},
+		"sha256:exact":    {Reason: keepreasons.WhitelistedTag},
+		"sha256:pinned-1": {Reason: keepreasons.WhitelistedDigest, Metadata: "sha256:pinned-?"},
+		"sha256:other":    {},
+		"sha256:tool":     {Reason: keepreasons.WhitelistedRepository, Metadata: "hytromo/tools-*"},
+	}
+
+	for _, repo := range parsedRepos {
+		for _, image := range repo.Images {
+			if image.KeptData != expectedKeptData[image.Digest[0]] {
+				t.Errorf("Image %v should have keep data %+v, not %+v", image.Digest[0], expectedKeptData[image.Digest[0]], image.KeptData)
+			}
+		}
+	}
+}
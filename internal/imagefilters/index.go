@@ -0,0 +1,44 @@
+package imagefilters
+
+import (
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+// inheritsParentDecision returns if the keep reason of a platform manifest comes from looking at it as a standalone image, in which case the decision of its image index is the one that counts
+func inheritsParentDecision(reason keepreasons.KeptReason) bool {
+	return !reason.IsKept() || reason == keepreasons.Young || reason == keepreasons.OneOfFew
+}
+
+// indexFilter makes the untagged manifests of multi-arch images follow the decision of their image indexes: they are kept when any of their indexes is kept and deleted along with them otherwise
+func indexFilter(repos []containerregistry.Repository) {
+	for repoIndex := range repos {
+		images := repos[repoIndex].Images
+		imageOfDigest := map[string]containerregistry.ContainerImage{}
+
+		for _, image := range images {
+			for _, digest := range image.Digest {
+				imageOfDigest[digest] = image
+			}
+		}
+
+		for imageIndex, image := range images {
+			if len(image.Tag) > 0 || len(image.Parents) == 0 || !inheritsParentDecision(image.KeptData.Reason) {
+				continue
+			}
+
+			images[imageIndex].KeptData = keepreasons.KeptData{}
+
+			for _, parentDigest := range image.Parents {
+				if imageOfDigest[parentDigest].KeptData.Reason.IsKept() {
+					images[imageIndex].KeptData = keepreasons.KeptData{
+						Reason:   keepreasons.PartOfKeptIndex,
+						Metadata: parentDigest,
+					}
+
+					break
+				}
+			}
+		}
+	}
+}
@@ -1,23 +1,56 @@
 package imagefilters
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/hytromo/faulty-crane/internal/configuration"
 	"github.com/hytromo/faulty-crane/internal/containerregistry"
 	"github.com/hytromo/faulty-crane/internal/k8s"
 	"github.com/hytromo/faulty-crane/internal/keepreasons"
 )
 
-func k8sFilter(repos []containerregistry.Repository, clusters []configuration.KubernetesCluster) {
+// KubernetesScan keeps the images that are used in the kubernetes clusters, so that the registries of a run share a single scan of each set of clusters
+type KubernetesScan struct {
+	mutex      sync.Mutex
+	usedImages map[string]map[string]*k8s.ClusterWithAPI
+}
+
+// NewKubernetesScan creates an empty scan; the clusters are read the first time that they are needed
+func NewKubernetesScan() *KubernetesScan {
+	return &KubernetesScan{
+		usedImages: map[string]map[string]*k8s.ClusterWithAPI{},
+	}
+}
+
+// UsedImages returns the images that are used in the clusters, reading the clusters only once
+func (scan *KubernetesScan) UsedImages(clusters []configuration.KubernetesCluster) map[string]*k8s.ClusterWithAPI {
+	scan.mutex.Lock()
+	defer scan.mutex.Unlock()
+
+	key := fmt.Sprintf("%+v", clusters)
+
+	if usedImages, exists := scan.usedImages[key]; exists {
+		return usedImages
+	}
+
+	usedImages := k8s.NewK8s(clusters).GetUsedImages()
+	scan.usedImages[key] = usedImages
+
+	return usedImages
+}
+
+func k8sFilter(repos []containerregistry.Repository, clusters []configuration.KubernetesCluster, scan *KubernetesScan) {
 	if len(clusters) == 0 {
 		return
 	}
 
-	usedImages := k8s.NewK8s(clusters).GetUsedImages()
+	usedImages := scan.UsedImages(clusters)
 
 	for repoIndex := range repos {
 	imageLoop:
 		for imageIndex, parsedImage := range repos[repoIndex].Images {
-			if parsedImage.KeptData.Reason != keepreasons.None {
+			if parsedImage.KeptData.Reason.IsKept() {
 				// image already kept for some other reason
 				continue
 			}
@@ -0,0 +1,55 @@
+package imagefilters
+
+import (
+	"strings"
+
+	"github.com/hytromo/faulty-crane/internal/patterns"
+	log "github.com/sirupsen/logrus"
+)
+
+// IsKeepPattern returns if an entry of a keep list is a pattern instead of an exact tag, digest or repository; the names themselves cannot contain wildcards
+func IsKeepPattern(entry string) bool {
+	return strings.HasPrefix(entry, "re:") || strings.HasPrefix(entry, "glob:") || strings.ContainsAny(entry, "*?")
+}
+
+// keepList is a keep list whose exact entries are looked up in a map and whose patterns are compiled once
+type keepList struct {
+	exact    map[string]bool
+	patterns patterns.List
+}
+
+func newKeepList(entries []string) keepList {
+	list := keepList{exact: map[string]bool{}}
+	patternEntries := []string{}
+
+	for _, entry := range entries {
+		if IsKeepPattern(entry) {
+			patternEntries = append(patternEntries, entry)
+		} else {
+			list.exact[entry] = true
+		}
+	}
+
+	compiledPatterns, err := patterns.CompileList(patternEntries)
+
+	if err != nil {
+		log.Fatalf("Could not compile the keep rules: %v. Please check your configuration.", err)
+	}
+
+	list.patterns = compiledPatterns
+
+	return list
+}
+
+// match returns if the name is in the keep list, along with the pattern that it matched; the pattern is empty for exact entries
+func (list keepList) match(name string) (string, bool) {
+	if list.exact[name] {
+		return "", true
+	}
+
+	if pattern, matches := list.patterns.Match(name); matches {
+		return pattern.Source, true
+	}
+
+	return "", false
+}
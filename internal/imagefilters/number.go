@@ -9,15 +9,38 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// uploadedMsOf returns the upload time of an image; images of unknown age, e.g. image indexes without any dated platform manifest, count as the oldest ones
+func uploadedMsOf(image containerregistry.ContainerImage) int64 {
+	if image.TimeUploadedMs == "" {
+		return 0
+	}
+
+	uploadedMs, err := strconv.ParseInt(image.TimeUploadedMs, 10, 64)
+
+	if err != nil {
+		log.Fatalf("Image %v contains invalid time uploaded field: %v", image.Digest, image.TimeUploadedMs)
+	}
+
+	return uploadedMs
+}
+
 func numberFilter(repos []containerregistry.Repository, _keepAtLeast int) {
 	if _keepAtLeast == 0 {
 		return
 	}
 
 	for repoIndex, repo := range repos {
+		// the platform manifests of multi-arch images follow their image index, so only standalone images are counted; the images that expire are going away anyway, so they do not count either
 		alreadyKeptInRepoCount := 0
+		repoImagesCount := 0
 		for _, parsedImage := range repo.Images {
-			if parsedImage.KeptData.Reason != keepreasons.None {
+			if !parsedImage.IsStandalone() || parsedImage.KeptData.Reason == keepreasons.Expiring {
+				continue
+			}
+
+			repoImagesCount++
+
+			if parsedImage.KeptData.Reason.IsKept() {
 				// image already kept for some other reason
 				alreadyKeptInRepoCount += 1
 				continue
@@ -25,7 +48,6 @@ func numberFilter(repos []containerregistry.Repository, _keepAtLeast int) {
 		}
 
 		keepAtLeastCount := _keepAtLeast
-		repoImagesCount := len(repo.Images)
 		if keepAtLeastCount > repoImagesCount {
 			keepAtLeastCount = repoImagesCount // we cannot keep more than the repo images count
 		}
@@ -39,27 +61,13 @@ func numberFilter(repos []containerregistry.Repository, _keepAtLeast int) {
 
 		// largest age (= more recent) first
 		sort.SliceStable(repo.Images, func(i, j int) bool {
-			imageI := repo.Images[i]
-			imageJ := repo.Images[j]
-			uploadedMsI, err := strconv.ParseInt(imageI.TimeUploadedMs, 10, 64)
-
-			if err != nil {
-				log.Fatalf("Image %v contains invalid time uploaded field: %v", imageI.Digest, imageI.TimeUploadedMs)
-			}
-
-			uploadedMsJ, err := strconv.ParseInt(imageJ.TimeUploadedMs, 10, 64)
-
-			if err != nil {
-				log.Fatalf("Image %v contains invalid time uploaded field: %v", imageJ.Digest, imageJ.TimeUploadedMs)
-			}
-
-			return uploadedMsI > uploadedMsJ
+			return uploadedMsOf(repo.Images[i]) > uploadedMsOf(repo.Images[j])
 		})
 
 		markedAsKeptNumber := 0
 		for imageIndex, image := range repo.Images {
-			if image.KeptData.Reason == keepreasons.None {
-				repos[repoIndex].Images[imageIndex].KeptData.Reason = keepreasons.OneOfFew
+			if !image.KeptData.Reason.IsKept() && image.IsStandalone() {
+				repos[repoIndex].Images[imageIndex].KeptData = keepreasons.KeptData{Reason: keepreasons.OneOfFew}
 				markedAsKeptNumber++
 				if markedAsKeptNumber >= needToKeepAdditionalToReachAtLeastCount {
 					break // move on to next repo
@@ -0,0 +1,66 @@
+package imagefilters
+
+import (
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+// referrerDecider decides the keep reason of the referrers of a repository, following chains of referrers, e.g. the signature of an sbom of an image
+type referrerDecider struct {
+	images        []containerregistry.ContainerImage
+	imageOfDigest map[string]int
+	decided       map[int]bool
+}
+
+// decide sets the keep reason of a referrer based on its subject, and returns if the image is kept
+func (decider *referrerDecider) decide(imageIndex int) bool {
+	image := decider.images[imageIndex]
+	subject := image.SubjectDigest()
+
+	if subject == "" || decider.decided[imageIndex] || image.KeptData.Reason == keepreasons.Protected {
+		return image.KeptData.Reason.IsKept()
+	}
+
+	// marking it before following the subject protects us from cycles
+	decider.decided[imageIndex] = true
+
+	subjectIndex, subjectExists := decider.imageOfDigest[subject]
+
+	if !subjectExists {
+		decider.images[imageIndex].KeptData = keepreasons.KeptData{
+			Reason:   keepreasons.OrphanedReferrer,
+			Metadata: subject,
+		}
+	} else if decider.decide(subjectIndex) {
+		decider.images[imageIndex].KeptData = keepreasons.KeptData{
+			Reason:   keepreasons.ReferrerOfKept,
+			Metadata: subject,
+		}
+	} else {
+		// the referrer goes away along with its subject
+		decider.images[imageIndex].KeptData = keepreasons.KeptData{}
+	}
+
+	return decider.images[imageIndex].KeptData.Reason.IsKept()
+}
+
+// referrerFilter keeps the signatures, attestations and other referrers exactly when the image that they refer to is kept; the referrers of images that do not exist anymore are marked as orphaned
+func referrerFilter(repos []containerregistry.Repository) {
+	for repoIndex := range repos {
+		decider := referrerDecider{
+			images:        repos[repoIndex].Images,
+			imageOfDigest: map[string]int{},
+			decided:       map[int]bool{},
+		}
+
+		for imageIndex, image := range decider.images {
+			for _, digest := range image.Digest {
+				decider.imageOfDigest[digest] = imageIndex
+			}
+		}
+
+		for imageIndex := range decider.images {
+			decider.decide(imageIndex)
+		}
+	}
+}
@@ -1,31 +1,62 @@
 package imagefilters
 
 import (
+	"strings"
+
 	"github.com/hytromo/faulty-crane/internal/containerregistry"
 	"github.com/hytromo/faulty-crane/internal/keepreasons"
 )
 
+// whitelistedPrefixOf returns the first path prefix, e.g. project/team-a/**, that the repository lies under
+func whitelistedPrefixOf(repo containerregistry.Repository, prefixes []string) (string, bool) {
+	for _, prefix := range prefixes {
+		if repo.HasPathPrefix(prefix) {
+			return prefix, true
+		}
+	}
+
+	return "", false
+}
+
 func repoFilter(repos []containerregistry.Repository, reposToKeep []string) {
 	if len(reposToKeep) == 0 {
 		return
 	}
 
-	// let's create a map for the repos so we don't do O(n) every time we are searching to see if a repo is whitelisted
-	reposToKeepMap := make(map[string]bool)
+	// the exact repos go in a map so we don't do O(n) every time we are searching to see if a repo is whitelisted, the patterns are compiled once
+	reposToKeepEntries := []string{}
+	prefixesToKeep := []string{}
 	for _, repo := range reposToKeep {
-		reposToKeepMap[repo] = true
+		if strings.HasSuffix(repo, "/**") && !strings.HasPrefix(repo, "re:") && !strings.HasPrefix(repo, "glob:") {
+			prefixesToKeep = append(prefixesToKeep, repo)
+		} else {
+			reposToKeepEntries = append(reposToKeepEntries, repo)
+		}
 	}
+	reposToKeepList := newKeepList(reposToKeepEntries)
 
 	for repoIndex := range repos {
-		if _, exists := reposToKeepMap[repos[repoIndex].Link]; exists {
+		// the metadata show which pattern or prefix the repository was kept for
+		metadata, exists := reposToKeepList.match(repos[repoIndex].Link)
+
+		if !exists && repos[repoIndex].Path() != repos[repoIndex].Link {
+			metadata, exists = reposToKeepList.match(repos[repoIndex].Path())
+		}
+
+		if !exists {
+			metadata, exists = whitelistedPrefixOf(repos[repoIndex], prefixesToKeep)
+		}
+
+		if exists {
 			for imageIndex := range repos[repoIndex].Images {
 				parsedImage := repos[repoIndex].Images[imageIndex]
-				if parsedImage.KeptData.Reason != keepreasons.None {
+				if parsedImage.KeptData.Reason.IsKept() {
 					// image already kept for some other reason
 					continue
 				}
 
 				repos[repoIndex].Images[imageIndex].KeptData.Reason = keepreasons.WhitelistedRepository
+				repos[repoIndex].Images[imageIndex].KeptData.Metadata = metadata
 			}
 		}
 
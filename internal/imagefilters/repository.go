@@ -0,0 +1,71 @@
+package imagefilters
+
+import (
+	"github.com/hytromo/faulty-crane/internal/configuration"
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+// keptOnlyToKeepAFew returns if an image is kept only because its repository should keep at least a number of images, or because it belongs to such an image
+func keptOnlyToKeepAFew(reason keepreasons.KeptReason) bool {
+	return reason == keepreasons.OneOfFew || reason == keepreasons.PartOfKeptIndex || reason == keepreasons.ReferrerOfKept
+}
+
+// isInactive returns if a repository has not been updated for longer than the inactive duration and nothing in it is kept for a reason other than keeping a few images
+func isInactive(repo containerregistry.Repository, nowMs int64, inactiveDurationMs int64) bool {
+	lastActivityMs := repo.LastActivityMs()
+
+	if lastActivityMs == 0 || nowMs-lastActivityMs < inactiveDurationMs {
+		return false
+	}
+
+	for _, image := range repo.Images {
+		if image.KeptData.Reason.IsKept() && !keptOnlyToKeepAFew(image.KeptData.Reason) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// PlanRepositoryDeletions marks the repositories that are deleted as a whole after their images; it needs the images to be already filtered
+func PlanRepositoryDeletions(repos []containerregistry.Repository, cleanup configuration.RepositoryCleanup) {
+	nowMs := getMsTime()
+	var inactiveDurationMs int64 = 0
+
+	if cleanup.InactiveFor != "" {
+		inactiveDurationMs = getStringDurationInMs(cleanup.InactiveFor)
+	}
+
+	for repoIndex, repo := range repos {
+		empty := true
+
+		for _, image := range repo.Images {
+			if image.KeptData.Reason.IsKept() {
+				empty = false
+				break
+			}
+		}
+
+		if empty {
+			if cleanup.Empty {
+				repos[repoIndex].DeletionReason = containerregistry.RepositoryEmpty
+			}
+
+			continue
+		}
+
+		if cleanup.InactiveFor == "" || !isInactive(repo, nowMs, inactiveDurationMs) {
+			continue
+		}
+
+		repos[repoIndex].DeletionReason = containerregistry.RepositoryInactive
+
+		// the images that were kept only to keep a few of them go away along with the repository
+		for imageIndex := range repo.Images {
+			if keptOnlyToKeepAFew(repo.Images[imageIndex].KeptData.Reason) {
+				repos[repoIndex].Images[imageIndex].KeptData = keepreasons.KeptData{}
+			}
+		}
+	}
+}
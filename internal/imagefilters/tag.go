@@ -9,26 +9,24 @@ func tagFilter(repos []containerregistry.Repository, tagsToKeep []string) {
 	if len(tagsToKeep) == 0 {
 		return
 	}
-	// let's create a map for the tags so we don't do O(n) every time we are searching to see if a tag is whitelisted
-	tagsToKeepMap := make(map[string]bool)
-
-	for _, tag := range tagsToKeep {
-		tagsToKeepMap[tag] = true
-	}
+	// the exact tags go in a map so we don't do O(n) every time we are searching to see if a tag is whitelisted, the patterns are compiled once
+	tagsToKeepList := newKeepList(tagsToKeep)
 
 	for repoIndex := range repos {
 		for imageIndex := range repos[repoIndex].Images {
 			parsedImage := repos[repoIndex].Images[imageIndex]
 
-			if parsedImage.KeptData.Reason != keepreasons.None {
+			if parsedImage.KeptData.Reason.IsKept() {
 				// image already kept for some other reason
 				continue
 			}
 
 			for _, tag := range parsedImage.Tag {
-				_, exists := tagsToKeepMap[tag]
+				// the metadata show which pattern the tag was kept for
+				pattern, exists := tagsToKeepList.match(tag)
 				if exists {
 					repos[repoIndex].Images[imageIndex].KeptData.Reason = keepreasons.WhitelistedTag
+					repos[repoIndex].Images[imageIndex].KeptData.Metadata = pattern
 					break
 				}
 			}
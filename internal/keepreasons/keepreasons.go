@@ -18,8 +18,29 @@ const (
 	WhitelistedRepository
 	// OneOfFew kept reason means that the repository needs to keep a minimum number of images, that's why the image was kept
 	OneOfFew
+	// Protected kept reason means that the registry itself does not allow the image to be deleted, e.g. because of an immutability rule
+	Protected
+	// PartOfKeptIndex kept reason means that the image is one of the manifests of a multi-arch image index that is kept, e.g. the manifest of a single platform
+	PartOfKeptIndex
+	// ReferrerOfKept kept reason means that the image is a signature, an attestation or another artifact of an image that is kept
+	ReferrerOfKept
+	// OrphanedReferrer is not a reason to keep the image: the image is a signature, an attestation or another artifact of an image that does not exist anymore, so it WILL be deleted
+	OrphanedReferrer
+	// Expiring kept reason means that the registry itself is going to delete the image once it expires, e.g. on quay tag expiration, so there is no need to delete it
+	Expiring
+	// Undeletable kept reason means that the registry can only delete images through their tags and the image has none, e.g. a platform manifest on quay, so it is left to the garbage collection of the registry
+	Undeletable
+	// RecentlyPulled kept reason means that the image was pulled recently and thus is still in use
+	RecentlyPulled
+	// UnlinkedManifest kept reason means that the image is untagged in a repository with a kept image index, while the registry does not tell the platform manifests of the indexes apart, so it could be one of them
+	UnlinkedManifest
 )
 
+// IsKept returns if the reason keeps the image from being deleted
+func (reason KeptReason) IsKept() bool {
+	return reason != None && reason != OrphanedReferrer
+}
+
 // KeptData contains all the data needed to figure out why an image was kept from being deleted
 type KeptData struct {
 	Reason KeptReason
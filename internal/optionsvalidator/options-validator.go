@@ -2,24 +2,203 @@ package optionsvalidator
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/hytromo/faulty-crane/internal/configuration"
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/imagefilters"
+	"github.com/hytromo/faulty-crane/internal/patterns"
+	"maze.io/x/duration"
 )
 
 // Validate ensures that the application options are valid and returns an error otherwise
 func Validate(options configuration.AppOptions) error {
+	err := validateOptionSet(options)
+
+	if err != nil {
+		return err
+	}
+
+	names := map[string]bool{}
+
+	for _, target := range options.ApplyPlanCommon.Targets {
+		// the name is what the plan uses to find the registry of each repository
+		if target.Name == "" || names[target.Name] {
+			return errors.New("please give each registry target a unique name")
+		}
+
+		names[target.Name] = true
+
+		targetOptions := options.ForTarget(target)
+
+		if !configuration.HasRegistry(&targetOptions) {
+			return fmt.Errorf("please configure a registry for the target %v", target.Name)
+		}
+
+		if err = validateOptionSet(targetOptions); err != nil {
+			return fmt.Errorf("target %v: %v", target.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// validateOptionSet ensures that the options of a single registry, either the general ones or the ones of a target, are valid
+func validateOptionSet(options configuration.AppOptions) error {
+	err := validateRegistry(options)
+
+	if err == nil {
+		err = validateRepositoryScope(options.ApplyPlanCommon.Repositories)
+	}
+
+	if err == nil {
+		err = validatePathKeepImages(options.ApplyPlanCommon.Keep)
+	}
+
+	if err == nil {
+		err = validateKeepPatterns(options.ApplyPlanCommon.Keep)
+	}
+
+	if err == nil {
+		err = validateKeepDurations(options.ApplyPlanCommon.Keep)
+	}
+
+	if err == nil {
+		err = validateRepositoryCleanup(options.ApplyPlanCommon.DeleteRepositories)
+	}
+
+	return err
+}
+
+// validateRepositoryScope ensures that all the repository patterns can be compiled
+func validateRepositoryScope(scope configuration.RepositoryScope) error {
+	_, err := patterns.CompileList(append(append([]string{}, scope.Include...), scope.Exclude...))
+
+	return err
+}
+
+// validateRepositoryCleanup ensures that the inactivity duration can be parsed
+func validateRepositoryCleanup(cleanup configuration.RepositoryCleanup) error {
+	if cleanup.InactiveFor == "" {
+		return nil
+	}
+
+	if _, err := duration.ParseDuration(cleanup.InactiveFor); err != nil {
+		return fmt.Errorf("please specify a valid duration for deleting inactive repositories, e.g. '1y', not '%v'", cleanup.InactiveFor)
+	}
+
+	return nil
+}
+
+// validateKeepDurations ensures that the pull durations, including the ones of the path rules, can be parsed
+func validateKeepDurations(keep configuration.KeepImages) error {
+	if keep.PulledWithin != "" {
+		if _, err := duration.ParseDuration(keep.PulledWithin); err != nil {
+			return fmt.Errorf("please specify a valid duration for keeping pulled images, e.g. '30d', not '%v'", keep.PulledWithin)
+		}
+	}
+
+	for _, path := range keep.Paths {
+		if err := validateKeepDurations(path.Keep); err != nil {
+			return fmt.Errorf("path %v: %v", path.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// validateKeepPatterns ensures that the patterns of the image keep lists, including the ones of the path rules, can be compiled
+func validateKeepPatterns(keep configuration.KeepImages) error {
+	entries := append(append(append([]string{}, keep.Image.Tags...), keep.Image.Digests...), keep.Image.Repositories...)
+	keepPatterns := []string{}
+
+	for _, entry := range entries {
+		if imagefilters.IsKeepPattern(entry) {
+			keepPatterns = append(keepPatterns, entry)
+		}
+	}
+
+	if _, err := patterns.CompileList(keepPatterns); err != nil {
+		return err
+	}
+
+	for _, path := range keep.Paths {
+		if err := validateKeepPatterns(path.Keep); err != nil {
+			return fmt.Errorf("path %v: %v", path.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// validatePathKeepImages ensures that each path rule has its own path prefix
+func validatePathKeepImages(keep configuration.KeepImages) error {
+	paths := map[string]bool{}
+
+	for _, path := range keep.Paths {
+		cleanPath := containerregistry.CleanPathPrefix(path.Path)
+
+		if cleanPath == "" || paths[cleanPath] {
+			return errors.New("please give each path of the keep rules a unique, non-empty path prefix")
+		}
+
+		paths[cleanPath] = true
+	}
+
+	return nil
+}
+
+// validateRegistry ensures that the options of the configured registry are valid
+func validateRegistry(options configuration.AppOptions) error {
 	if options.Configure.SubcommandEnabled {
 		if options.Configure.Config == "" {
 			return errors.New("please specify a configuration file to save your answers to")
 		}
 	} else if options.Apply.SubcommandEnabled {
 		if configuration.IsGCR(&options) {
-			if options.ApplyPlanCommon.GoogleContainerRegistry.Host == "" || options.ApplyPlanCommon.GoogleContainerRegistry.Token == "" {
-				return errors.New("please specify a valid container registry and access key for GCR")
+			if (options.ApplyPlanCommon.GoogleContainerRegistry.Host == "" && options.ApplyPlanCommon.GoogleContainerRegistry.Project == "") || (options.ApplyPlanCommon.GoogleContainerRegistry.Token == "" && !options.ApplyPlanCommon.GoogleContainerRegistry.TokenSource.HasTokenSource()) {
+				return errors.New("please specify a valid container registry or project and an access key or a token source for GCR")
+			}
+		} else if configuration.IsArtifactRegistry(&options) {
+			if options.ApplyPlanCommon.ArtifactRegistry.Project == "" || options.ApplyPlanCommon.ArtifactRegistry.Location == "" || options.ApplyPlanCommon.ArtifactRegistry.Token == "" {
+				return errors.New("please specify a valid project, location and access token for Artifact Registry")
+			}
+		} else if configuration.IsECR(&options) {
+			if options.ApplyPlanCommon.ElasticContainerRegistry.Region == "" {
+				return errors.New("please specify a valid region for ECR")
+			}
+		} else if configuration.IsACR(&options) {
+			if options.ApplyPlanCommon.AzureContainerRegistry.Host == "" || options.ApplyPlanCommon.AzureContainerRegistry.Username == "" || options.ApplyPlanCommon.AzureContainerRegistry.Password == "" {
+				return errors.New("please specify a valid host, username and password for ACR")
 			}
 		} else if configuration.IsDockerhub(&options) {
-			if options.ApplyPlanCommon.DockerhubContainerRegistry.Namespace == "" || options.ApplyPlanCommon.DockerhubContainerRegistry.Password == "" || options.ApplyPlanCommon.DockerhubContainerRegistry.Username == "" {
-				return errors.New("please specify a valid namespace, username and password for Dockerhub")
+			if len(options.ApplyPlanCommon.DockerhubContainerRegistry.Namespace) == 0 || options.ApplyPlanCommon.DockerhubContainerRegistry.Password == "" || options.ApplyPlanCommon.DockerhubContainerRegistry.Username == "" {
+				return errors.New("please specify at least one namespace, a username and a password or access token for Dockerhub")
+			}
+		} else if configuration.IsGHCR(&options) {
+			if options.ApplyPlanCommon.GitHubContainerRegistry.Owner == "" || options.ApplyPlanCommon.GitHubContainerRegistry.Token == "" {
+				return errors.New("please specify a valid owner and access token for GHCR")
+			}
+		} else if configuration.IsGitLab(&options) {
+			if options.ApplyPlanCommon.GitLabContainerRegistry.Host == "" || options.ApplyPlanCommon.GitLabContainerRegistry.Group == "" || options.ApplyPlanCommon.GitLabContainerRegistry.Token == "" {
+				return errors.New("please specify a valid host, group and access token for GitLab")
+			}
+		} else if configuration.IsQuay(&options) {
+			if options.ApplyPlanCommon.QuayContainerRegistry.Organization == "" || options.ApplyPlanCommon.QuayContainerRegistry.Token == "" {
+				return errors.New("please specify a valid organization and access token for Quay")
+			}
+		} else if configuration.IsHarbor(&options) {
+			if options.ApplyPlanCommon.HarborContainerRegistry.Host == "" || options.ApplyPlanCommon.HarborContainerRegistry.Username == "" || options.ApplyPlanCommon.HarborContainerRegistry.Password == "" {
+				return errors.New("please specify a valid host, username and password for Harbor")
+			}
+		} else if configuration.IsOCI(&options) {
+			if options.ApplyPlanCommon.OCIContainerRegistry.Host == "" {
+				return errors.New("please specify a valid host for the OCI registry")
+			}
+		} else if configuration.IsDriver(&options) {
+			if strings.ContainsAny(options.ApplyPlanCommon.DriverRegistry.Name, `/\`) {
+				return errors.New("please specify the driver by its name, e.g. artifactstore, or by its path")
 			}
 		}
 	}
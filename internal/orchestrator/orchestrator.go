@@ -1,14 +1,26 @@
 package orchestrator
 
 import (
+	"errors"
 	"math"
 
 	"github.com/cheggaaa/pb/v3"
 	"github.com/hytromo/faulty-crane/internal/configuration"
 	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/acr"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/artifactregistry"
 	"github.com/hytromo/faulty-crane/internal/containerregistry/dockerhub"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/driver"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/ecr"
 	"github.com/hytromo/faulty-crane/internal/containerregistry/gcr"
-	"github.com/hytromo/faulty-crane/internal/keepreasons"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/ghcr"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/gitlab"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/harbor"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/oci"
+	"github.com/hytromo/faulty-crane/internal/containerregistry/quay"
+	"github.com/hytromo/faulty-crane/internal/enrichment"
+	"github.com/hytromo/faulty-crane/internal/patterns"
+	"github.com/hytromo/faulty-crane/internal/tokensource"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -16,6 +28,8 @@ import (
 type Orchestrator struct {
 	crClient cr.Client
 	options  *configuration.AppOptions
+	// enricher is set when the client can fetch manifests; after a repository is parsed, its images are enriched when the enrichment is enabled and only get their layers otherwise, for the reclaimable sizes
+	enricher *enrichment.Enricher
 }
 
 // NewOrchestrator creates a new orchestrator instance
@@ -24,33 +38,117 @@ func NewOrchestrator(options *configuration.AppOptions) Orchestrator {
 
 	if configuration.IsGCR(options) {
 		crClient = gcr.NewGCRClient(gcr.NewGCRClientParams{
-			Hostname: options.ApplyPlanCommon.GoogleContainerRegistry.Host,
-			Token:    options.ApplyPlanCommon.GoogleContainerRegistry.Token,
+			Hostname:    options.ApplyPlanCommon.GoogleContainerRegistry.Host,
+			Project:     options.ApplyPlanCommon.GoogleContainerRegistry.Project,
+			TokenSource: googleTokenSourceOf(options.ApplyPlanCommon.GoogleContainerRegistry),
+		})
+	} else if configuration.IsArtifactRegistry(options) {
+		crClient = artifactregistry.NewArtifactRegistryClient(artifactregistry.NewArtifactRegistryClientParams{
+			Project:  options.ApplyPlanCommon.ArtifactRegistry.Project,
+			Location: options.ApplyPlanCommon.ArtifactRegistry.Location,
+			Endpoint: options.ApplyPlanCommon.ArtifactRegistry.Endpoint,
+		})
+	} else if configuration.IsECR(options) {
+		crClient = ecr.NewECRClient(ecr.NewECRClientParams{
+			Region:     options.ApplyPlanCommon.ElasticContainerRegistry.Region,
+			RegistryID: options.ApplyPlanCommon.ElasticContainerRegistry.RegistryID,
+			Profile:    options.ApplyPlanCommon.ElasticContainerRegistry.Profile,
+			Endpoint:   options.ApplyPlanCommon.ElasticContainerRegistry.Endpoint,
+		})
+	} else if configuration.IsACR(options) {
+		crClient = acr.NewACRClient(acr.NewACRClientParams{
+			Host:     options.ApplyPlanCommon.AzureContainerRegistry.Host,
+			TenantID: options.ApplyPlanCommon.AzureContainerRegistry.TenantID,
 		})
 	} else if configuration.IsDockerhub(options) {
 		crClient = dockerhub.NewHubClient(dockerhub.NewHubClientParams{
-			Namespace: options.ApplyPlanCommon.DockerhubContainerRegistry.Namespace,
+			Namespaces:      options.ApplyPlanCommon.DockerhubContainerRegistry.Namespace,
+			DeleteManifests: options.ApplyPlanCommon.DockerhubContainerRegistry.DeleteManifests,
+		})
+	} else if configuration.IsGHCR(options) {
+		crClient = ghcr.NewGHCRClient(ghcr.NewGHCRClientParams{
+			Owner:    options.ApplyPlanCommon.GitHubContainerRegistry.Owner,
+			Endpoint: options.ApplyPlanCommon.GitHubContainerRegistry.Endpoint,
+		})
+	} else if configuration.IsGitLab(options) {
+		crClient = gitlab.NewGitLabClient(gitlab.NewGitLabClientParams{
+			Host:       options.ApplyPlanCommon.GitLabContainerRegistry.Host,
+			Group:      options.ApplyPlanCommon.GitLabContainerRegistry.Group,
+			BulkDelete: options.ApplyPlanCommon.GitLabContainerRegistry.BulkDelete,
+		})
+	} else if configuration.IsQuay(options) {
+		crClient = quay.NewQuayClient(quay.NewQuayClientParams{
+			Host:         options.ApplyPlanCommon.QuayContainerRegistry.Host,
+			Organization: options.ApplyPlanCommon.QuayContainerRegistry.Organization,
+		})
+	} else if configuration.IsHarbor(options) {
+		crClient = harbor.NewHarborClient(harbor.NewHarborClientParams{
+			Host:    options.ApplyPlanCommon.HarborContainerRegistry.Host,
+			Project: options.ApplyPlanCommon.HarborContainerRegistry.Project,
+		})
+	} else if configuration.IsOCI(options) {
+		crClient = oci.NewOCIClient(oci.NewOCIClientParams{
+			Host: options.ApplyPlanCommon.OCIContainerRegistry.Host,
+		})
+	} else if configuration.IsDriver(options) {
+		crClient = driver.NewDriverClient(driver.NewDriverClientParams{
+			Name:    options.ApplyPlanCommon.DriverRegistry.Name,
+			Path:    options.ApplyPlanCommon.DriverRegistry.Path,
+			Options: options.ApplyPlanCommon.DriverRegistry.Options,
 		})
 	} else {
 		log.Fatal("Please configure a registry to fetch from")
 	}
 
-	return Orchestrator{
+	orchestrator := Orchestrator{
 		options:  options,
 		crClient: crClient,
 	}
+
+	if fetcher, isManifestFetcher := crClient.(cr.ManifestFetcher); isManifestFetcher {
+		orchestrator.enricher = enrichment.NewEnricher(fetcher)
+	}
+
+	return orchestrator
+}
+
+// googleTokenSourceOf returns the configured token source of GCR, falling back to the static token
+func googleTokenSourceOf(config configuration.GoogleContainerRegistry) tokensource.Source {
+	switch {
+	case config.TokenSource.Command != "":
+		return tokensource.ExecSource{Command: config.TokenSource.Command}
+	case config.TokenSource.ServiceAccountKey != "":
+		return tokensource.ServiceAccountSource{
+			KeyFile:       config.TokenSource.ServiceAccountKey,
+			TokenEndpoint: config.TokenSource.TokenEndpoint,
+		}
+	case config.TokenSource.MetadataServer:
+		return tokensource.MetadataSource{}
+	}
+
+	return tokensource.StaticSource{AccessToken: config.Token}
 }
 
 func getNeedingDeletionInRepoCount(repo cr.Repository) int {
 	repoImagesToDelete := 0
 	for _, image := range repo.Images {
-		if image.KeptData.Reason == keepreasons.None {
+		if !image.KeptData.Reason.IsKept() {
 			repoImagesToDelete++
 		}
 	}
 	return repoImagesToDelete
 }
 
+func getRepositoriesToDeleteCount(repos []cr.Repository) int {
+	repositoriesToDelete := 0
+	for _, repo := range repos {
+		if repo.DeletionReason != "" {
+			repositoriesToDelete++
+		}
+	}
+	return repositoriesToDelete
+}
+
 // Init does all the required steps (like logging-in into the container registry, if needed) before starting doing CR api calls
 func (orchestrator *Orchestrator) Init() {
 	username := ""
@@ -60,17 +158,47 @@ func (orchestrator *Orchestrator) Init() {
 	if configuration.IsGCR(orchestrator.options) {
 		log.Info("Configuring GCR...")
 		password = config.GoogleContainerRegistry.Token
+	} else if configuration.IsArtifactRegistry(orchestrator.options) {
+		log.Info("Configuring Artifact Registry...")
+		password = config.ArtifactRegistry.Token
+	} else if configuration.IsECR(orchestrator.options) {
+		log.Info("Configuring ECR...")
+	} else if configuration.IsACR(orchestrator.options) {
+		log.Info("Configuring ACR...")
+		username = config.AzureContainerRegistry.Username
+		password = config.AzureContainerRegistry.Password
 	} else if configuration.IsDockerhub(orchestrator.options) {
 		log.Info("Configuring Dockerhub...")
 		username = config.DockerhubContainerRegistry.Username
 		password = config.DockerhubContainerRegistry.Password
+	} else if configuration.IsGHCR(orchestrator.options) {
+		log.Info("Configuring GHCR...")
+		password = config.GitHubContainerRegistry.Token
+	} else if configuration.IsGitLab(orchestrator.options) {
+		log.Info("Configuring GitLab...")
+		password = config.GitLabContainerRegistry.Token
+	} else if configuration.IsQuay(orchestrator.options) {
+		log.Info("Configuring Quay...")
+		password = config.QuayContainerRegistry.Token
+	} else if configuration.IsHarbor(orchestrator.options) {
+		log.Info("Configuring Harbor...")
+		username = config.HarborContainerRegistry.Username
+		password = config.HarborContainerRegistry.Password
+	} else if configuration.IsOCI(orchestrator.options) {
+		log.Info("Configuring OCI registry...")
+		username = config.OCIContainerRegistry.Username
+		password = config.OCIContainerRegistry.Password
+	} else if configuration.IsDriver(orchestrator.options) {
+		log.Info("Configuring registry driver...")
+		username = config.DriverRegistry.Username
+		password = config.DriverRegistry.Password
 	} else {
 		log.Fatal("Please configure a registry to fetch from")
 	}
 
 	err := orchestrator.crClient.Login(username, password)
 	if err != nil {
-		log.Fatal("Could not login")
+		log.Fatalf("Could not login: %v", err)
 	}
 }
 
@@ -80,6 +208,52 @@ func (orchestrator *Orchestrator) deleteImageFromChan(repoLink string, imagesToD
 	}
 }
 
+// bulkDeleteRepoImages deletes all the images of a repository that do not have a keep reason at once, for clients that support it
+func (orchestrator Orchestrator) bulkDeleteRepoImages(bulkDeleter cr.BulkDeleter, repo cr.Repository, result cr.RepoDeletionResult, pb *pb.ProgressBar) cr.RepoDeletionResult {
+	imagesToDelete := []cr.ContainerImage{}
+
+	for _, deletionPhase := range deletionPhasesOf(repo) {
+		imagesToDelete = append(imagesToDelete, deletionPhase...)
+	}
+
+	if len(imagesToDelete) == 0 {
+		return result
+	}
+
+	deletedCount, scheduledCount, err := bulkDeleter.DeleteImages(repo.Link, imagesToDelete, false)
+
+	if err != nil {
+		log.Errorf("Could not delete the images of %v: %v", repo.Link, err.Error())
+	}
+
+	result.ManagedToDeleteCount = deletedCount
+	result.ScheduledCount = scheduledCount
+	result.FailedCount = len(imagesToDelete) - deletedCount - scheduledCount
+	pb.Add(len(imagesToDelete))
+
+	return result
+}
+
+// deletionPhasesOf groups the images of a repository that need to be deleted into phases: image indexes go first, as the registries refuse to delete manifests that an index still references
+func deletionPhasesOf(repo cr.Repository) [][]cr.ContainerImage {
+	indexes := []cr.ContainerImage{}
+	manifests := []cr.ContainerImage{}
+
+	for _, image := range repo.Images {
+		if image.KeptData.Reason.IsKept() {
+			continue
+		}
+
+		if image.IsIndex() {
+			indexes = append(indexes, image)
+		} else {
+			manifests = append(manifests, image)
+		}
+	}
+
+	return [][]cr.ContainerImage{indexes, manifests}
+}
+
 func (orchestrator Orchestrator) deleteRepoImages(repo cr.Repository, pb *pb.ProgressBar) cr.RepoDeletionResult {
 	result := cr.RepoDeletionResult{
 		ShouldDeleteCount:    0,
@@ -87,31 +261,45 @@ func (orchestrator Orchestrator) deleteRepoImages(repo cr.Repository, pb *pb.Pro
 	}
 
 	result.ShouldDeleteCount = getNeedingDeletionInRepoCount(repo)
-	deletingImagesWorkersNum := int(math.Min(8, float64(result.ShouldDeleteCount)))
-
-	imagesToDeleteChan := make(chan cr.ContainerImage, result.ShouldDeleteCount) // jobs
-	imagesDeletedChan := make(chan error, result.ShouldDeleteCount)              // results
 
-	for i := 1; i <= deletingImagesWorkersNum; i++ {
-		go orchestrator.deleteImageFromChan(repo.Link, imagesToDeleteChan, imagesDeletedChan)
+	if bulkDeleter, isBulkDeleter := orchestrator.crClient.(cr.BulkDeleter); isBulkDeleter {
+		return orchestrator.bulkDeleteRepoImages(bulkDeleter, repo, result, pb)
 	}
 
-	for _, image := range repo.Images {
-		if image.KeptData.Reason == keepreasons.None {
+	// each phase is finished before the next one starts
+	for _, imagesToDelete := range deletionPhasesOf(repo) {
+		if len(imagesToDelete) == 0 {
+			continue
+		}
+
+		deletingImagesWorkersNum := int(math.Min(8, float64(len(imagesToDelete))))
+
+		imagesToDeleteChan := make(chan cr.ContainerImage, len(imagesToDelete)) // jobs
+		imagesDeletedChan := make(chan error, len(imagesToDelete))              // results
+
+		for i := 1; i <= deletingImagesWorkersNum; i++ {
+			go orchestrator.deleteImageFromChan(repo.Link, imagesToDeleteChan, imagesDeletedChan)
+		}
+
+		for _, image := range imagesToDelete {
 			// feed the jobs to the workers
 			imagesToDeleteChan <- image
 		}
-	}
 
-	// while the jobs are being done by the workers, we are counting them
-	for _, image := range repo.Images {
-		if image.KeptData.Reason == keepreasons.None {
+		close(imagesToDeleteChan)
+
+		// while the jobs are being done by the workers, we are counting them
+		for range imagesToDelete {
 			managedToDeleteImage := <-imagesDeletedChan
 
 			pb.Increment()
 
 			if managedToDeleteImage == nil {
 				result.ManagedToDeleteCount++
+			} else if errors.Is(managedToDeleteImage, cr.ErrImageProtected) {
+				result.SkippedCount++
+			} else {
+				result.FailedCount++
 			}
 		}
 	}
@@ -119,12 +307,85 @@ func (orchestrator Orchestrator) deleteRepoImages(repo cr.Repository, pb *pb.Pro
 	return result
 }
 
+// deleteRepository deletes a repository that is planned to be deleted, but only if all the images that it should lose are gone: none of them failed, was skipped or is left for the registry to delete later
+func (orchestrator Orchestrator) deleteRepository(repo cr.Repository, result cr.RepoDeletionResult) cr.RepoDeletionResult {
+	if repo.DeletionReason == "" {
+		return result
+	}
+
+	result.ShouldDeleteRepositoriesCount = 1
+
+	if result.FailedCount > 0 || result.SkippedCount > 0 || result.ScheduledCount > 0 || result.ManagedToDeleteCount != result.ShouldDeleteCount {
+		log.Warnf("Not deleting repository %v, as not all of its images were deleted", repo.Link)
+		return result
+	}
+
+	repositoryDeleter, isRepositoryDeleter := orchestrator.crClient.(cr.RepositoryDeleter)
+
+	if !isRepositoryDeleter {
+		log.Errorf("Cannot delete repository %v, the registry does not support deleting repositories", repo.Link)
+		return result
+	}
+
+	if repositoryDeleter.DeleteRepository(repo.Link, false) == nil {
+		result.ManagedToDeleteRepositoriesCount = 1
+	}
+
+	return result
+}
+
 func (orchestrator *Orchestrator) deleteRepoImagesWorker(repos <-chan cr.Repository, deletionResults chan<- cr.RepoDeletionResult, pb *pb.ProgressBar) {
 	for repo := range repos {
-		deletionResults <- orchestrator.deleteRepoImages(repo, pb)
+		// the repository goes only after its images, so that nothing is deleted that the plan did not account for
+		deletionResults <- orchestrator.deleteRepository(repo, orchestrator.deleteRepoImages(repo, pb))
 	}
 }
 
+// addResults sums up two deletion results
+func addResults(result cr.RepoDeletionResult, otherResult cr.RepoDeletionResult) cr.RepoDeletionResult {
+	result.ShouldDeleteCount += otherResult.ShouldDeleteCount
+	result.ManagedToDeleteCount += otherResult.ManagedToDeleteCount
+	result.SkippedCount += otherResult.SkippedCount
+	result.ScheduledCount += otherResult.ScheduledCount
+	result.FailedCount += otherResult.FailedCount
+	result.ShouldDeleteRepositoriesCount += otherResult.ShouldDeleteRepositoriesCount
+	result.ManagedToDeleteRepositoriesCount += otherResult.ManagedToDeleteRepositoriesCount
+
+	return result
+}
+
+// bulkDeleteReposImages deletes the images of all the repositories at once, for clients whose bulk deletion spans many repositories; the repositories that are planned to be deleted go afterwards
+func (orchestrator *Orchestrator) bulkDeleteReposImages(repositoriesBulkDeleter cr.RepositoriesBulkDeleter, repos []cr.Repository, totalImagesToDelete int) cr.RepoDeletionResult {
+	allResults := cr.RepoDeletionResult{}
+
+	log.Info("Deleting the images of ", len(repos), " repo(s) in bulk")
+
+	bar := pb.Full.Start(totalImagesToDelete)
+
+	deletedCounts, err := repositoriesBulkDeleter.DeleteRepositoriesImages(repos, false)
+
+	if err != nil {
+		log.Errorf("Could not delete all the images: %v", err.Error())
+	}
+
+	bar.Add(totalImagesToDelete)
+
+	for _, repo := range repos {
+		result := cr.RepoDeletionResult{
+			ShouldDeleteCount:    getNeedingDeletionInRepoCount(repo),
+			ManagedToDeleteCount: deletedCounts[repo.Link],
+		}
+
+		result.FailedCount = result.ShouldDeleteCount - result.ManagedToDeleteCount
+
+		allResults = addResults(allResults, orchestrator.deleteRepository(repo, result))
+	}
+
+	bar.Finish()
+
+	return allResults
+}
+
 // DeleteImagesWithNoKeepReason deletes the images that do not have a keep reason
 func (orchestrator *Orchestrator) DeleteImagesWithNoKeepReason(repos []cr.Repository) cr.RepoDeletionResult {
 	allResults := cr.RepoDeletionResult{
@@ -146,10 +407,14 @@ func (orchestrator *Orchestrator) DeleteImagesWithNoKeepReason(repos []cr.Reposi
 		totalImagesToDelete += getNeedingDeletionInRepoCount(repo)
 	}
 
-	if totalImagesToDelete == 0 {
+	if totalImagesToDelete == 0 && getRepositoriesToDeleteCount(repos) == 0 {
 		return allResults
 	}
 
+	if repositoriesBulkDeleter, isRepositoriesBulkDeleter := orchestrator.crClient.(cr.RepositoriesBulkDeleter); isRepositoriesBulkDeleter {
+		return orchestrator.bulkDeleteReposImages(repositoriesBulkDeleter, repos, totalImagesToDelete)
+	}
+
 	log.Info("Deleting the images of ", reposCount, " repo(s), using ", reposDeletingWorkersNum, " routine(s)")
 
 	bar := pb.Full.Start(totalImagesToDelete)
@@ -165,9 +430,7 @@ func (orchestrator *Orchestrator) DeleteImagesWithNoKeepReason(repos []cr.Reposi
 
 	// while the jobs are being done by the workers, we are merging all the results into one
 	for range repos {
-		thisResult := <-deletionResultsChan
-		allResults.ShouldDeleteCount += thisResult.ShouldDeleteCount
-		allResults.ManagedToDeleteCount += thisResult.ManagedToDeleteCount
+		allResults = addResults(allResults, <-deletionResultsChan)
 	}
 
 	bar.Finish()
@@ -175,9 +438,53 @@ func (orchestrator *Orchestrator) DeleteImagesWithNoKeepReason(repos []cr.Reposi
 	return allResults
 }
 
+// Capabilities returns what the registry client can do
+func (orchestrator Orchestrator) Capabilities() cr.Capabilities {
+	return orchestrator.crClient.Capabilities()
+}
+
+// scopeRepos drops the repositories of the catalog that are out of scope, so that their images are never fetched
+func (orchestrator Orchestrator) scopeRepos(repos []string) []string {
+	scope := orchestrator.options.ApplyPlanCommon.Repositories
+
+	if len(scope.Include) == 0 && len(scope.Exclude) == 0 {
+		return repos
+	}
+
+	// the patterns have already been validated
+	include, _ := patterns.CompileList(scope.Include)
+	exclude, _ := patterns.CompileList(scope.Exclude)
+
+	scopedRepos := []string{}
+
+	for _, repo := range repos {
+		if _, isIncluded := include.Match(repo); len(include) > 0 && !isIncluded {
+			continue
+		}
+
+		if _, isExcluded := exclude.Match(repo); isExcluded {
+			continue
+		}
+
+		scopedRepos = append(scopedRepos, repo)
+	}
+
+	log.Infof("%v of the %v repo(s) of the registry are in scope", len(scopedRepos), len(repos))
+
+	return scopedRepos
+}
+
 func (orchestrator Orchestrator) fetchRepoImagesWorker(repositoryLinks <-chan string, parsedRepos chan<- cr.Repository) {
 	for repo := range repositoryLinks {
-		parsedRepos <- orchestrator.crClient.ParseRepo(repo)
+		parsedRepo := orchestrator.crClient.ParseRepo(repo)
+
+		if orchestrator.enricher != nil && orchestrator.options.ApplyPlanCommon.Enrich {
+			parsedRepo = orchestrator.enricher.EnrichRepository(parsedRepo)
+		} else if orchestrator.enricher != nil {
+			parsedRepo = orchestrator.enricher.FetchLayers(parsedRepo)
+		}
+
+		parsedRepos <- parsedRepo
 	}
 }
 
@@ -185,7 +492,7 @@ func (orchestrator Orchestrator) fetchRepoImagesWorker(repositoryLinks <-chan st
 func (orchestrator Orchestrator) GetAllRepos() []cr.Repository {
 	log.Info("Getting all the repos of the registry...")
 
-	repos := orchestrator.crClient.GetAllRepos()
+	repos := orchestrator.scopeRepos(orchestrator.crClient.GetAllRepos())
 	reposCount := len(repos)
 
 	bar := pb.Full.Start(reposCount)
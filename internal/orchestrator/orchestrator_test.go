@@ -0,0 +1,91 @@
+package orchestrator
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/cheggaaa/pb/v3"
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+// fakeClient deletes every image except the ones with a failing digest and records the deleted repositories
+type fakeClient struct {
+	failingDigests      map[string]error
+	mutex               sync.Mutex
+	deletedRepositories []string
+}
+
+func (client *fakeClient) Login(username string, password string) error {
+	return nil
+}
+
+func (client *fakeClient) DeleteImage(imageRepo string, image cr.ContainerImage, silentErrors bool) error {
+	return client.failingDigests[image.Digest[0]]
+}
+
+func (client *fakeClient) GetAllRepos() []string {
+	return nil
+}
+
+func (client *fakeClient) ParseRepo(repositoryLink string) cr.Repository {
+	return cr.Repository{Link: repositoryLink}
+}
+
+func (client *fakeClient) Capabilities() cr.Capabilities {
+	return cr.Capabilities{DeleteByDigest: true, DeleteRepository: true}
+}
+
+func (client *fakeClient) DeleteRepository(repositoryLink string, silentErrors bool) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.deletedRepositories = append(client.deletedRepositories, repositoryLink)
+
+	return nil
+}
+
+func TestRepositoryIsKeptOnFailedDeletions(t *testing.T) {
+	client := &fakeClient{failingDigests: map[string]error{
+		"sha256:failing":   errors.New("request failed many times"),
+		"sha256:protected": cr.ErrImageProtected,
+	}}
+
+	orchestrator := Orchestrator{crClient: client}
+
+	repoOf := func(link string, digests ...string) cr.Repository {
+		repo := cr.Repository{Link: link, DeletionReason: cr.RepositoryEmpty}
+
+		for _, digest := range digests {
+			repo.Images = append(repo.Images, cr.ContainerImage{Digest: []string{digest}})
+		}
+
+		// a kept image is not deleted and does not count as a failure
+		repo.Images = append(repo.Images, cr.ContainerImage{Digest: []string{"sha256:kept"}, KeptData: keepreasons.KeptData{Reason: keepreasons.Young}})
+
+		return repo
+	}
+
+	results := map[string]cr.RepoDeletionResult{}
+
+	for _, repo := range []cr.Repository{
+		repoOf("project/clean", "sha256:a", "sha256:b"),
+		repoOf("project/failing", "sha256:a", "sha256:failing"),
+		repoOf("project/protected", "sha256:a", "sha256:protected"),
+	} {
+		results[repo.Link] = orchestrator.deleteRepository(repo, orchestrator.deleteRepoImages(repo, pb.New(0)))
+	}
+
+	if results["project/failing"].FailedCount != 1 || results["project/failing"].ManagedToDeleteCount != 1 {
+		t.Errorf("Wrong result of the failing repository %+v", results["project/failing"])
+	}
+
+	if results["project/protected"].SkippedCount != 1 || results["project/protected"].FailedCount != 0 {
+		t.Errorf("Wrong result of the protected repository %+v", results["project/protected"])
+	}
+
+	if len(client.deletedRepositories) != 1 || client.deletedRepositories[0] != "project/clean" || results["project/clean"].ManagedToDeleteRepositoriesCount != 1 {
+		t.Errorf("Only the repository with all its images deleted should be deleted, not %v", client.deletedRepositories)
+	}
+}
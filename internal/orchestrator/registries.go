@@ -0,0 +1,152 @@
+package orchestrator
+
+import (
+	"github.com/hytromo/faulty-crane/internal/configuration"
+	cr "github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/imagefilters"
+	log "github.com/sirupsen/logrus"
+)
+
+// RegistryRun is one of the registries of a run along with the options that apply to it
+type RegistryRun struct {
+	// Name is the name of the registry target, empty for the registry of the top-level options
+	Name    string
+	Options configuration.AppOptions
+}
+
+// RegistryRunsOf returns the registries that a run covers: the registry of the top-level options, if one is configured, and all the registry targets
+func RegistryRunsOf(options *configuration.AppOptions) []RegistryRun {
+	runs := []RegistryRun{}
+
+	if configuration.HasRegistry(options) {
+		runs = append(runs, RegistryRun{Options: *options})
+	}
+
+	for _, target := range options.ApplyPlanCommon.Targets {
+		runs = append(runs, RegistryRun{
+			Name:    target.Name,
+			Options: options.ForTarget(target),
+		})
+	}
+
+	if len(runs) == 0 {
+		log.Fatal("Please configure a registry to fetch from")
+	}
+
+	return runs
+}
+
+// PlanAllRegistries parses the repositories of all the registries of the run and applies the keep rules of each registry to them; the kubernetes clusters are read only once
+func PlanAllRegistries(options *configuration.AppOptions) []cr.Repository {
+	scan := imagefilters.NewKubernetesScan()
+	parsedRepos := []cr.Repository{}
+
+	for _, run := range RegistryRunsOf(options) {
+		if run.Name != "" {
+			log.Infof("Planning registry %v...", run.Name)
+		}
+
+		orchestrator := NewOrchestrator(&run.Options)
+		orchestrator.Init()
+
+		if err := imagefilters.CheckCapabilities(orchestrator.Capabilities(), run.Options.ApplyPlanCommon); err != nil {
+			if run.Name != "" {
+				log.Fatalf("Registry %v: %v", run.Name, err)
+			}
+
+			log.Fatalf("Cannot plan: %v", err)
+		}
+
+		if orchestrator.Capabilities().DeleteTagsOnly {
+			// e.g. quay, whose untagged manifests are only removed by its own garbage collection
+			tagsOnlyNote := "deletes tags only: the untagged manifests, e.g. the platform manifests of multi-arch images or the manifests of deleted tags, are left to its garbage collection"
+
+			if run.Name != "" {
+				log.Warnf("Registry %v %v", run.Name, tagsOnlyNote)
+			} else {
+				log.Warnf("The registry %v", tagsOnlyNote)
+			}
+		}
+
+		repos := orchestrator.GetAllRepos()
+
+		for i := range repos {
+			repos[i].Registry = run.Name
+		}
+
+		repos = imagefilters.ParseWithScan(repos, run.Options.ApplyPlanCommon.Keep, scan)
+		imagefilters.KeepUndeletable(repos, orchestrator.Capabilities())
+		imagefilters.KeepUnlinkedManifests(repos, orchestrator.Capabilities())
+
+		if cleanup := run.Options.ApplyPlanCommon.DeleteRepositories; cleanup.Empty || cleanup.InactiveFor != "" {
+			imagefilters.PlanRepositoryDeletions(repos, cleanup)
+		}
+
+		parsedRepos = append(parsedRepos, repos...)
+	}
+
+	return parsedRepos
+}
+
+// DeleteAllRegistries deletes the images that do not have a keep reason from the registries that the repositories belong to
+func DeleteAllRegistries(options *configuration.AppOptions, repos []cr.Repository) cr.RepoDeletionResult {
+	allResults := cr.RepoDeletionResult{}
+	reposOfRegistry := map[string][]cr.Repository{}
+
+	for _, repo := range repos {
+		reposOfRegistry[repo.Registry] = append(reposOfRegistry[repo.Registry], repo)
+	}
+
+	runs := RegistryRunsOf(options)
+	configuredRegistries := map[string]bool{}
+
+	for _, run := range runs {
+		configuredRegistries[run.Name] = true
+	}
+
+	// nothing is deleted unless all the registries of the plan are configured
+	for registry := range reposOfRegistry {
+		if configuredRegistries[registry] {
+			continue
+		}
+
+		if registry == "" {
+			log.Fatal("The plan contains repositories of a single-registry run, but no top-level registry is configured")
+		}
+
+		log.Fatalf("The plan contains repositories of the registry target %v, which is not configured", registry)
+	}
+
+	for _, run := range runs {
+		registryRepos := reposOfRegistry[run.Name]
+
+		if getNeedingDeletionCount(registryRepos) == 0 && getRepositoriesToDeleteCount(registryRepos) == 0 {
+			continue
+		}
+
+		if run.Name != "" {
+			log.Infof("Cleaning registry %v...", run.Name)
+		}
+
+		orchestrator := NewOrchestrator(&run.Options)
+		orchestrator.Init()
+
+		// the plan may come from a run with other options, so the images that the registry cannot delete are kept once more
+		imagefilters.KeepUndeletable(registryRepos, orchestrator.Capabilities())
+		imagefilters.KeepUnlinkedManifests(registryRepos, orchestrator.Capabilities())
+
+		allResults = addResults(allResults, orchestrator.DeleteImagesWithNoKeepReason(registryRepos))
+	}
+
+	return allResults
+}
+
+func getNeedingDeletionCount(repos []cr.Repository) int {
+	count := 0
+
+	for _, repo := range repos {
+		count += getNeedingDeletionInRepoCount(repo)
+	}
+
+	return count
+}
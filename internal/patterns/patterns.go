@@ -0,0 +1,108 @@
+package patterns
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const regexPrefix = "re:"
+const globPrefix = "glob:"
+
+// Pattern matches names such as repository links or tags; it is either a glob, where * matches within a path segment, ** matches across segments and ? matches a single character, or a regular expression prefixed with re:
+type Pattern struct {
+	// Source is the pattern as it was given
+	Source string
+	regex  *regexp.Regexp
+}
+
+// globToRegex converts a glob to an anchored regular expression
+func globToRegex(glob string) string {
+	regex := strings.Builder{}
+	regex.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+
+				// **/ also matches no segments at all, e.g. team/**/app matches team/app
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					regex.WriteString("(?:.*/)?")
+				} else {
+					regex.WriteString(".*")
+				}
+			} else {
+				regex.WriteString("[^/]*")
+			}
+		case '?':
+			regex.WriteString("[^/]")
+		default:
+			regex.WriteString(regexp.QuoteMeta(string(glob[i])))
+		}
+	}
+
+	regex.WriteString("$")
+
+	return regex.String()
+}
+
+// Compile parses a pattern; patterns without a prefix, or with the glob: prefix, are globs and patterns with the re: prefix are regular expressions that need to match the whole name
+func Compile(pattern string) (Pattern, error) {
+	var expression string
+
+	switch {
+	case strings.HasPrefix(pattern, regexPrefix):
+		expression = "^(?:" + strings.TrimPrefix(pattern, regexPrefix) + ")$"
+	case strings.HasPrefix(pattern, globPrefix):
+		expression = globToRegex(strings.TrimPrefix(pattern, globPrefix))
+	default:
+		expression = globToRegex(pattern)
+	}
+
+	regex, err := regexp.Compile(expression)
+
+	if err != nil {
+		return Pattern{}, fmt.Errorf("invalid pattern '%v': %v", pattern, err)
+	}
+
+	return Pattern{Source: pattern, regex: regex}, nil
+}
+
+// Match returns if the name matches the pattern
+func (pattern Pattern) Match(name string) bool {
+	return pattern.regex != nil && pattern.regex.MatchString(name)
+}
+
+// List is a list of patterns that matches a name when any of its patterns does
+type List []Pattern
+
+// CompileList parses a list of patterns
+func CompileList(patterns []string) (List, error) {
+	list := List{}
+
+	for _, pattern := range patterns {
+		compiled, err := Compile(pattern)
+
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, compiled)
+	}
+
+	return list, nil
+}
+
+// Match returns the first pattern of the list that matches the name
+func (list List) Match(name string) (Pattern, bool) {
+	for _, pattern := range list {
+		if pattern.Match(name) {
+			return pattern, true
+		}
+	}
+
+	return Pattern{}, false
+}
@@ -0,0 +1,65 @@
+package patterns
+
+import (
+	"testing"
+)
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		matches bool
+	}{
+		{"team/app", "team/app", true},
+		{"team/app", "team/app2", false},
+		{"team/*", "team/app", true},
+		{"team/*", "team/app/worker", false},
+		{"team/**", "team/app/worker", true},
+		{"team/**/worker", "team/worker", true},
+		{"team/**/worker", "team/app/v2/worker", true},
+		{"**/cache", "team/app/cache", true},
+		{"app-?", "app-1", true},
+		{"app-?", "app-10", false},
+		{"app.v1", "appxv1", false},
+		{"glob:team/*", "team/app", true},
+		{"re:team/(app|worker)", "team/worker", true},
+		{"re:team/(app|worker)", "team/worker-2", false},
+		{"re:.*-cache", "team/build-cache", true},
+	}
+
+	for _, c := range cases {
+		pattern, err := Compile(c.pattern)
+
+		if err != nil {
+			t.Fatalf("Pattern %v should compile: %v", c.pattern, err)
+		}
+
+		if pattern.Match(c.name) != c.matches {
+			t.Errorf("Pattern %v matching %v should be %v", c.pattern, c.name, c.matches)
+		}
+	}
+
+	if _, err := Compile("re:team/(app"); err == nil {
+		t.Error("An invalid regular expression should not compile")
+	}
+}
+
+func TestList(t *testing.T) {
+	list, err := CompileList([]string{"team/*", "re:.*-cache"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pattern, matches := list.Match("build-cache"); !matches || pattern.Source != "re:.*-cache" {
+		t.Error("The matching pattern should be returned")
+	}
+
+	if _, matches := list.Match("other/app"); matches {
+		t.Error("No pattern should match")
+	}
+
+	if _, err = CompileList([]string{"ok", "re:("}); err == nil {
+		t.Error("A list with an invalid pattern should not compile")
+	}
+}
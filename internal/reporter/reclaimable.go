@@ -0,0 +1,72 @@
+package reporter
+
+import (
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+)
+
+// reclaimableSizes returns the bytes that deleting the planned images really frees, per repository index and overall: the layers that are referenced only by deleted images, counted once, as a registry keeps a layer as long as any image of it references it; it also returns how many images have unknown layers, e.g. on registries that cannot fetch manifests, in which case the sizes are unknown, as a kept image may hold on to any layer
+func reclaimableSizes(repos []containerregistry.Repository) ([]int64, int64, int) {
+	keptLayersOfRegistry := map[string]map[string]bool{}
+	unknownLayersCount := 0
+
+	for _, repo := range repos {
+		if keptLayersOfRegistry[repo.Registry] == nil {
+			keptLayersOfRegistry[repo.Registry] = map[string]bool{}
+		}
+
+		for _, image := range repo.Images {
+			if len(image.Layers) == 0 && !image.IsIndex() {
+				// image indexes have no layers of their own
+				unknownLayersCount++
+			}
+
+			if !image.KeptData.Reason.IsKept() {
+				continue
+			}
+
+			for _, layer := range image.Layers {
+				keptLayersOfRegistry[repo.Registry][layer.Digest] = true
+			}
+		}
+	}
+
+	repoSizes := make([]int64, len(repos))
+	var totalSize int64 = 0
+
+	if unknownLayersCount > 0 {
+		return repoSizes, totalSize, unknownLayersCount
+	}
+
+	// a layer that deleted images of many repositories share is freed once
+	countedLayersOfRegistry := map[string]map[string]bool{}
+
+	for repoIndex, repo := range repos {
+		if countedLayersOfRegistry[repo.Registry] == nil {
+			countedLayersOfRegistry[repo.Registry] = map[string]bool{}
+		}
+
+		countedLayersOfRepo := map[string]bool{}
+
+		for _, image := range repo.Images {
+			if image.KeptData.Reason.IsKept() {
+				continue
+			}
+
+			for _, layer := range image.Layers {
+				if layer.Digest == "" || keptLayersOfRegistry[repo.Registry][layer.Digest] || countedLayersOfRepo[layer.Digest] {
+					continue
+				}
+
+				countedLayersOfRepo[layer.Digest] = true
+				repoSizes[repoIndex] += layer.Size
+
+				if !countedLayersOfRegistry[repo.Registry][layer.Digest] {
+					countedLayersOfRegistry[repo.Registry][layer.Digest] = true
+					totalSize += layer.Size
+				}
+			}
+		}
+	}
+
+	return repoSizes, totalSize, 0
+}
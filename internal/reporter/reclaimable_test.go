@@ -0,0 +1,58 @@
+package reporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/keepreasons"
+)
+
+func TestReclaimableSizes(t *testing.T) {
+	kept := keepreasons.KeptData{Reason: keepreasons.Young}
+	base := containerregistry.Layer{Digest: "sha256:base", Size: 100}
+	app := containerregistry.Layer{Digest: "sha256:app", Size: 20}
+	oldApp := containerregistry.Layer{Digest: "sha256:old-app", Size: 30}
+	tool := containerregistry.Layer{Digest: "sha256:tool", Size: 5}
+
+	repos := []containerregistry.Repository{
+		{
+			Link: "app",
+			Images: []containerregistry.ContainerImage{
+				{Layers: []containerregistry.Layer{base, app}, KeptData: kept},
+				{Layers: []containerregistry.Layer{base, oldApp}},
+				// a layer that two deleted images of the repository share is freed once
+				{Layers: []containerregistry.Layer{base, oldApp, tool}},
+				// image indexes have no layers of their own
+				{MediaType: "application/vnd.oci.image.index.v1+json"},
+			},
+		},
+		{
+			Link:   "worker",
+			Images: []containerregistry.ContainerImage{{Layers: []containerregistry.Layer{base, oldApp}}},
+		},
+		{
+			// the layers of another registry are not shared
+			Registry: "mirror",
+			Link:     "app",
+			Images:   []containerregistry.ContainerImage{{Layers: []containerregistry.Layer{base}}},
+		},
+	}
+
+	repoSizes, totalSize, unknownLayersCount := reclaimableSizes(repos)
+
+	if unknownLayersCount != 0 {
+		t.Fatalf("The images carry layers, but %v of them are unknown", unknownLayersCount)
+	}
+
+	if !reflect.DeepEqual(repoSizes, []int64{35, 30, 100}) || totalSize != 135 {
+		t.Errorf("Wrong reclaimable sizes %v and %v", repoSizes, totalSize)
+	}
+
+	// a kept image whose layers are unknown may hold on to any of the layers of the deleted ones
+	repos[0].Images[0].Layers = nil
+
+	if _, _, unknownLayersCount = reclaimableSizes(repos); unknownLayersCount != 1 {
+		t.Errorf("A kept image without layers should make the reclaimable sizes unknown, got %v unknown", unknownLayersCount)
+	}
+}
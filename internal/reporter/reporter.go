@@ -17,22 +17,80 @@ import (
 	tablewriter "github.com/olekukonko/tablewriter"
 )
 
-// ReportRepositoriesStatus prints out in a nice way the status of the repositories, e.g. what needs to be deleted and for what reason
+// printRegistryHeader prints the name of a registry target, so that the repositories of a multi-registry run are grouped per registry
+func printRegistryHeader(registry string) {
+	if registry == "" {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println(color.Bold(color.Cyan(fmt.Sprintf("Registry %v", registry))))
+	fmt.Println()
+}
+
+// unknownSizeCountOf returns how many images come from registries that do not report image sizes, e.g. GHCR
+func unknownSizeCountOf(repos []containerregistry.Repository) int {
+	unknownSizeCount := 0
+
+	for _, repo := range repos {
+		for _, image := range repo.Images {
+			if image.ImageSizeBytes == "" {
+				unknownSizeCount++
+			}
+		}
+	}
+
+	return unknownSizeCount
+}
+
+// undeletableCountOf returns the number of the images that are kept only because their registry cannot delete untagged images
+func undeletableCountOf(repos []containerregistry.Repository) int {
+	count := 0
+
+	for _, repo := range repos {
+		for _, image := range repo.Images {
+			if image.KeptData.Reason == keepreasons.Undeletable {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+// ReportRepositoriesStatus prints out in a nice way the status of the repositories, e.g. what needs to be deleted and for what reason; the repositories of a multi-registry run are grouped per registry
 func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalyticalPlan bool) {
 	sort.SliceStable(repos, func(i int, j int) bool {
+		if repos[i].Registry != repos[j].Registry {
+			return repos[i].Registry < repos[j].Registry
+		}
+
 		return repos[i].Link < repos[j].Link
 	})
 
+	currentRegistry := ""
+	reclaimableRepoSizes, reclaimableTotalSize, unknownLayersCount := reclaimableSizes(repos)
+
 	keepCount := 0
 	deleteCount := 0
 	var deleteTotalSizeBytes int64 = 0
 	var keepTotalSizeBytes int64 = 0
 
 	if showAnalyticalPlan {
-		headers := []string{"Kept", "Tags", "Digest", "Size", "Cluster", "Uploaded"}
+		headers := []string{"Kept", "Tags", "Digest", "Size", "Cluster", "Uploaded", "Pulled"}
 		headersCount := len(headers)
-		for _, parsedRepo := range repos {
-			fmt.Println(">", parsedRepo.Link)
+		for i, parsedRepo := range repos {
+			if i == 0 || parsedRepo.Registry != currentRegistry {
+				currentRegistry = parsedRepo.Registry
+				printRegistryHeader(currentRegistry)
+			}
+
+			if unknownLayersCount == 0 {
+				fmt.Println(">", parsedRepo.Link, fmt.Sprintf("(%v reclaimable)", stringutil.HumanFriendlySize(reclaimableRepoSizes[i])))
+			} else {
+				fmt.Println(">", parsedRepo.Link)
+			}
+
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetHeader(headers)
 			for _, parsedImage := range parsedRepo.Images {
@@ -47,7 +105,19 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 					imageSizeBytes = 0 // we will not crash the app for this reason
 				}
 
-				if keptReason == keepreasons.None {
+				if keptReason == keepreasons.OrphanedReferrer {
+					// needs to be deleted, as the image that it refers to does not exist anymore
+					tableValues[0] = "✗ ORPHAN"
+					tableColors[0] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgMagentaColor}
+					deleteCount++
+					deleteTotalSizeBytes = deleteTotalSizeBytes + imageSizeBytes
+				} else if keptReason == keepreasons.Expiring {
+					// not deleted by us, the registry deletes it on its own once it expires
+					tableValues[0] = "✔ EXPIRES"
+					tableColors[0] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgYellowColor}
+					keepCount++
+					keepTotalSizeBytes = keepTotalSizeBytes + imageSizeBytes
+				} else if !keptReason.IsKept() {
 					// needs to be deleted
 					tableValues[0] = "✗ NO"
 					tableColors[0] = tablewriter.Colors{tablewriter.Bold, tablewriter.FgRedColor}
@@ -61,7 +131,7 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 				}
 
 				tableValues[1] = stringutil.KeepAtMost(strings.Join(image.Tag, ","), 50)
-				if keptReason == keepreasons.WhitelistedTag {
+				if keptReason == keepreasons.WhitelistedTag || keptReason == keepreasons.Protected {
 					tableColors[1] = tablewriter.Colors{tablewriter.FgGreenColor}
 				} else {
 					tableColors[1] = tablewriter.Colors{}
@@ -74,7 +144,7 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 				}
 
 				tableValues[2] = strings.Join(digestsClean, ",")
-				if keptReason == keepreasons.WhitelistedDigest {
+				if keptReason == keepreasons.WhitelistedDigest || keptReason == keepreasons.PartOfKeptIndex || keptReason == keepreasons.ReferrerOfKept || keptReason == keepreasons.Undeletable || keptReason == keepreasons.UnlinkedManifest {
 					tableColors[2] = tablewriter.Colors{tablewriter.FgGreenColor}
 				} else {
 					tableColors[2] = tablewriter.Colors{}
@@ -82,11 +152,10 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 
 				tableColors[3] = tablewriter.Colors{}
 
-				tableValues[3] = stringutil.HumanFriendlySize(imageSizeBytes)
-
-				uploadedMs, err := strconv.ParseInt(image.TimeUploadedMs, 10, 64)
-				if err != nil {
-					log.Fatalf("Invalid uploaded timestamp %v", image.TimeUploadedMs)
+				// empty when the registry does not report the size of the image
+				tableValues[3] = "-"
+				if image.ImageSizeBytes != "" {
+					tableValues[3] = stringutil.HumanFriendlySize(imageSizeBytes)
 				}
 
 				tableValues[4] = "-"
@@ -97,13 +166,32 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 					tableColors[4] = tablewriter.Colors{}
 				}
 
-				tableValues[5] = time.Unix(uploadedMs/1000, 0).Format(time.RFC822)
+				// empty when the age of the image is unknown, e.g. for an image index without any dated platform manifest
+				tableValues[5] = "-"
+				if image.TimeUploadedMs != "" {
+					uploadedMs, err := strconv.ParseInt(image.TimeUploadedMs, 10, 64)
+					if err != nil {
+						log.Fatalf("Invalid uploaded timestamp %v", image.TimeUploadedMs)
+					}
+
+					tableValues[5] = time.Unix(uploadedMs/1000, 0).Format(time.RFC822)
+				}
 				if keptReason == keepreasons.Young {
 					tableColors[5] = tablewriter.Colors{tablewriter.FgGreenColor}
 				} else {
 					tableColors[5] = tablewriter.Colors{}
 				}
 
+				// empty when the image has never been pulled or when the registry does not track pulls
+				tableValues[6] = "-"
+				tableColors[6] = tablewriter.Colors{}
+				if lastPulledMs, err := strconv.ParseInt(image.TimeLastPulledMs, 10, 64); err == nil {
+					tableValues[6] = time.Unix(lastPulledMs/1000, 0).Format(time.RFC822)
+				}
+				if keptReason == keepreasons.RecentlyPulled {
+					tableColors[6] = tablewriter.Colors{tablewriter.FgGreenColor}
+				}
+
 				table.Rich(tableValues, tableColors)
 			}
 			table.Render()
@@ -111,11 +199,26 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 		}
 	} else {
 		headers := []string{"repo", "deleted", "deleted size", "most recent to be deleted"}
+		if unknownLayersCount == 0 {
+			headers = append(headers, "reclaimable")
+		}
 		headersCount := len(headers)
 		table := tablewriter.NewWriter(os.Stdout)
 		table.SetHeader(headers)
-		table.SetHeader(headers)
-		for _, parsedRepo := range repos {
+		for i, parsedRepo := range repos {
+			if i > 0 && parsedRepo.Registry != currentRegistry {
+				// each registry gets its own table
+				fmt.Println()
+				table.Render()
+				table = tablewriter.NewWriter(os.Stdout)
+				table.SetHeader(headers)
+			}
+
+			if i == 0 || parsedRepo.Registry != currentRegistry {
+				currentRegistry = parsedRepo.Registry
+				printRegistryHeader(currentRegistry)
+			}
+
 			tableValues := make([]string, headersCount)
 			tableColors := make([]tablewriter.Colors, headersCount)
 
@@ -135,7 +238,7 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 					imageSizeBytes = 0 // we will not crash the app for this reason
 				}
 
-				if keptReason == keepreasons.None {
+				if !keptReason.IsKept() {
 					// needs to be deleted
 					deletedImagesCountInRepo++
 					deleteTotalSizeInRepoBytes = deleteTotalSizeInRepoBytes + imageSizeBytes
@@ -180,12 +283,18 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 				tableValues[3] = timeago.Of(time.Unix(latestUploadedTimeStampToBeDeleted/1000, 0).UTC())
 			}
 
+			if unknownLayersCount == 0 {
+				tableValues[4] = stringutil.HumanFriendlySize(reclaimableRepoSizes[i])
+			}
+
 			table.Rich(tableValues, tableColors)
 		}
 		fmt.Println()
 		table.Render()
 	}
 
+	repositoriesToDeleteCount := reportRepositoryDeletions(repos)
+
 	totalBytes := deleteTotalSizeBytes + keepTotalSizeBytes
 	totalImages := deleteCount + keepCount
 
@@ -202,11 +311,33 @@ func ReportRepositoriesStatus(repos []containerregistry.Repository, showAnalytic
 		),
 	)
 
+	if unknownLayersCount == 0 {
+		fmt.Println(
+			stringutil.HumanFriendlySize(reclaimableTotalSize),
+			"will be reclaimed",
+			color.Red("/ the layers that only deleted images reference, counted once"),
+		)
+	} else {
+		fmt.Println(unknownLayersCount, "image(s) have unknown layers, so the size that will be reclaimed is unknown")
+	}
+
 	fmt.Println(
 		keepCount,
 		"image(s) will be kept",
 		color.Green(fmt.Sprintf("/ %v", stringutil.HumanFriendlySize(keepTotalSizeBytes))),
 	)
 
+	if unknownSizeCount := unknownSizeCountOf(repos); unknownSizeCount > 0 {
+		fmt.Println(unknownSizeCount, "image(s) have an unknown size and are not part of the sizes above")
+	}
+
+	if undeletableCount := undeletableCountOf(repos); undeletableCount > 0 {
+		fmt.Println(undeletableCount, "untagged image(s) cannot be deleted by their registry, which deletes tags only, and are left to its garbage collection")
+	}
+
+	if repositoriesToDeleteCount > 0 {
+		fmt.Println(repositoriesToDeleteCount, "repository(ies) will be deleted after their images")
+	}
+
 	fmt.Println()
 }
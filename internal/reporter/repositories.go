@@ -0,0 +1,65 @@
+package reporter
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	timeago "github.com/caarlos0/timea.go"
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	color "github.com/logrusorgru/aurora"
+	tablewriter "github.com/olekukonko/tablewriter"
+)
+
+// reportRepositoryDeletions prints the repositories that are deleted as a whole after their images, and returns how many they are
+func reportRepositoryDeletions(repos []containerregistry.Repository) int {
+	multiRegistry := false
+	repositoriesToDelete := []containerregistry.Repository{}
+
+	for _, repo := range repos {
+		multiRegistry = multiRegistry || repo.Registry != ""
+
+		if repo.DeletionReason != "" {
+			repositoriesToDelete = append(repositoriesToDelete, repo)
+		}
+	}
+
+	if len(repositoriesToDelete) == 0 {
+		return 0
+	}
+
+	headers := []string{"repo", "reason", "last activity"}
+
+	if multiRegistry {
+		headers = append([]string{"registry"}, headers...)
+	}
+
+	fmt.Println()
+	fmt.Println(color.Bold("Repositories to delete"))
+	fmt.Println()
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader(headers)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+	for _, repo := range repositoriesToDelete {
+		lastActivity := "-"
+
+		if lastActivityMs := repo.LastActivityMs(); lastActivityMs > 0 {
+			lastActivity = timeago.Of(time.Unix(lastActivityMs/1000, 0).UTC())
+		}
+
+		tableValues := []string{repo.Link, string(repo.DeletionReason), lastActivity}
+
+		if multiRegistry {
+			tableValues = append([]string{repo.Registry}, tableValues...)
+		}
+
+		table.Append(tableValues)
+	}
+
+	table.Render()
+	fmt.Println()
+
+	return len(repositoriesToDelete)
+}
@@ -0,0 +1,103 @@
+package reporter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hytromo/faulty-crane/internal/containerregistry"
+	"github.com/hytromo/faulty-crane/internal/utils/stringutil"
+	tablewriter "github.com/olekukonko/tablewriter"
+)
+
+// pathTotals are the rolled up totals of all the repositories under a level of the repository tree
+type pathTotals struct {
+	imagesCount          int
+	deleteCount          int
+	totalSizeBytes       int64
+	deleteTotalSizeBytes int64
+}
+
+// rollUpPaths sums up the images and their sizes of the repositories into every level of the path tree that they lie under
+func rollUpPaths(repos []containerregistry.Repository) map[string]*pathTotals {
+	totals := map[string]*pathTotals{}
+
+	for _, repo := range repos {
+		for _, level := range containerregistry.PathLevels(repo.Path()) {
+			if totals[level] == nil {
+				totals[level] = &pathTotals{}
+			}
+
+			for _, image := range repo.Images {
+				imageSizeBytes, err := strconv.ParseInt(image.ImageSizeBytes, 10, 64)
+				if err != nil {
+					imageSizeBytes = 0 // we will not crash the app for this reason
+				}
+
+				totals[level].imagesCount++
+				totals[level].totalSizeBytes += imageSizeBytes
+
+				if !image.KeptData.Reason.IsKept() {
+					totals[level].deleteCount++
+					totals[level].deleteTotalSizeBytes += imageSizeBytes
+				}
+			}
+		}
+	}
+
+	return totals
+}
+
+// ReportRepositoryTree prints the deletion counts and sizes rolled up to each level of the repository paths, e.g. project, project/team and project/team/service; the repositories of a multi-registry run get one tree per registry
+func ReportRepositoryTree(repos []containerregistry.Repository) {
+	reposOfRegistry := map[string][]containerregistry.Repository{}
+	registries := []string{}
+
+	for _, repo := range repos {
+		if _, exists := reposOfRegistry[repo.Registry]; !exists {
+			registries = append(registries, repo.Registry)
+		}
+
+		reposOfRegistry[repo.Registry] = append(reposOfRegistry[repo.Registry], repo)
+	}
+
+	sort.Strings(registries)
+
+	for _, registry := range registries {
+		printRegistryHeader(registry)
+
+		totals := rollUpPaths(reposOfRegistry[registry])
+
+		paths := []string{}
+		for path := range totals {
+			paths = append(paths, path)
+		}
+
+		// sorting the paths by their segments puts each level right above the levels under it
+		sort.Slice(paths, func(i int, j int) bool {
+			return strings.ReplaceAll(paths[i], "/", "\x00") < strings.ReplaceAll(paths[j], "/", "\x00")
+		})
+
+		table := tablewriter.NewWriter(os.Stdout)
+		table.SetHeader([]string{"path", "deleted", "deleted size"})
+		table.SetAutoWrapText(false)
+		table.SetAlignment(tablewriter.ALIGN_LEFT)
+
+		for _, path := range paths {
+			pathTotal := totals[path]
+			depth := strings.Count(path, "/")
+			name := path[strings.LastIndex(path, "/")+1:]
+
+			table.Append([]string{
+				strings.Repeat("  ", depth) + name,
+				fmt.Sprintf("%v/%v", pathTotal.deleteCount, pathTotal.imagesCount),
+				fmt.Sprintf("%v/%v", stringutil.HumanFriendlySize(pathTotal.deleteTotalSizeBytes), stringutil.HumanFriendlySize(pathTotal.totalSizeBytes)),
+			})
+		}
+
+		table.Render()
+		fmt.Println()
+	}
+}
@@ -0,0 +1,72 @@
+package tokensource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// execTokenLifetime is how long a plain text token of a command is used before the command is run again, as its expiration is unknown
+var execTokenLifetime = 10 * time.Minute
+
+// ExecSource runs a command that prints an access token, e.g. `gcloud auth print-access-token`; the command can also print a json object with an access_token and its expires_in seconds
+type ExecSource struct {
+	Command string
+}
+
+// execOutputDTO is the json output of a token command
+type execOutputDTO struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Token runs the command through the shell
+func (source ExecSource) Token() (Token, error) {
+	var cmd *exec.Cmd
+
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", source.Command)
+	} else {
+		cmd = exec.Command("sh", "-c", source.Command)
+	}
+
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+
+	if err != nil {
+		return Token{}, fmt.Errorf("token command '%v' failed: %v %v", source.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	output := strings.TrimSpace(stdout.String())
+
+	if strings.HasPrefix(output, "{") {
+		outputResp := execOutputDTO{}
+		err = json.Unmarshal([]byte(output), &outputResp)
+
+		if err != nil || outputResp.AccessToken == "" {
+			return Token{}, fmt.Errorf("invalid output of token command '%v'", source.Command)
+		}
+
+		token := Token{AccessToken: outputResp.AccessToken, Expiry: time.Now().Add(execTokenLifetime)}
+
+		if outputResp.ExpiresIn > 0 {
+			token.Expiry = time.Now().Add(time.Duration(outputResp.ExpiresIn) * time.Second)
+		}
+
+		return token, nil
+	}
+
+	if output == "" {
+		return Token{}, fmt.Errorf("token command '%v' printed no token", source.Command)
+	}
+
+	return Token{AccessToken: output, Expiry: time.Now().Add(execTokenLifetime)}, nil
+}
@@ -0,0 +1,43 @@
+package tokensource
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// defaultMetadataHost is the host of the gce metadata server, which can be overridden with GCE_METADATA_HOST like in the google sdks
+var defaultMetadataHost = "metadata.google.internal"
+
+// MetadataSource gets the access tokens of the default service account of the gce instance, gke node or workload identity that faulty-crane runs on
+type MetadataSource struct{}
+
+// Token asks the metadata server for an access token
+func (source MetadataSource) Token() (Token, error) {
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = defaultMetadataHost
+	}
+
+	endpoint := host
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		endpoint = "http://" + endpoint
+	}
+
+	req, err := http.NewRequest("GET", endpoint+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	token, err := requestToken(req)
+
+	if err != nil {
+		return Token{}, fmt.Errorf("could not get an access token from the metadata server: %v", err)
+	}
+
+	return token, nil
+}
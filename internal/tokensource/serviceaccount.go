@@ -0,0 +1,188 @@
+package tokensource
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultTokenEndpoint is the google oauth2 token endpoint
+var DefaultTokenEndpoint = "https://oauth2.googleapis.com/token"
+
+// cloudPlatformScope is the scope of the access tokens, which covers the registries
+var cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// ServiceAccountSource exchanges a signed jwt of a service account json key for an access token
+type ServiceAccountSource struct {
+	// KeyFile is the path of the json key of the service account
+	KeyFile string
+	// TokenEndpoint overrides the token endpoint of the key; empty means the token_uri of the key or the google endpoint
+	TokenEndpoint string
+}
+
+// serviceAccountKeyDTO is the json key of a service account
+type serviceAccountKeyDTO struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// tokenDTO is the response of the oauth2 token endpoints, including the one of the metadata server
+type tokenDTO struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+func parsePrivateKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+
+	if block == nil {
+		return nil, errors.New("the private key is not pem encoded")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if rsaKey, isRSA := key.(*rsa.PrivateKey); isRSA {
+			return rsaKey, nil
+		}
+
+		return nil, errors.New("the private key is not an rsa key")
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+func base64URLJSON(value interface{}) string {
+	jsonBytes, _ := json.Marshal(value)
+
+	return base64.RawURLEncoding.EncodeToString(jsonBytes)
+}
+
+// signedJWT builds the RS256 jwt assertion of the jwt-bearer grant
+func signedJWT(key serviceAccountKeyDTO, audience string, now time.Time) (string, error) {
+	privateKey, err := parsePrivateKey(key.PrivateKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if key.PrivateKeyID != "" {
+		header["kid"] = key.PrivateKeyID
+	}
+
+	unsigned := base64URLJSON(header) + "." + base64URLJSON(map[string]interface{}{
+		"iss":   key.ClientEmail,
+		"scope": cloudPlatformScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+
+	hashed := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, hashed[:])
+
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// requestToken does a token request and converts the response to a token
+func requestToken(req *http.Request) (Token, error) {
+	now := time.Now()
+	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Token{}, fmt.Errorf("%v: %v", resp.Status, string(bodyBytes))
+	}
+
+	tokenResp := tokenDTO{}
+	err = json.Unmarshal(bodyBytes, &tokenResp)
+
+	if err != nil || tokenResp.AccessToken == "" {
+		return Token{}, fmt.Errorf("invalid token response: %v", string(bodyBytes))
+	}
+
+	return Token{
+		AccessToken: tokenResp.AccessToken,
+		Expiry:      now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// Token signs a jwt with the key of the service account and exchanges it for an access token
+func (source ServiceAccountSource) Token() (Token, error) {
+	keyBytes, err := ioutil.ReadFile(source.KeyFile)
+
+	if err != nil {
+		return Token{}, fmt.Errorf("could not read the service account key: %v", err)
+	}
+
+	key := serviceAccountKeyDTO{}
+	err = json.Unmarshal(keyBytes, &key)
+
+	if err != nil || key.Type != "service_account" {
+		return Token{}, fmt.Errorf("%v is not a service account json key", source.KeyFile)
+	}
+
+	endpoint := source.TokenEndpoint
+	if endpoint == "" {
+		endpoint = key.TokenURI
+	}
+	if endpoint == "" {
+		endpoint = DefaultTokenEndpoint
+	}
+
+	assertion, err := signedJWT(key, endpoint, time.Now())
+
+	if err != nil {
+		return Token{}, fmt.Errorf("could not sign the jwt of %v: %v", key.ClientEmail, err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return Token{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	token, err := requestToken(req)
+
+	if err != nil {
+		return Token{}, fmt.Errorf("could not get an access token for %v: %v", key.ClientEmail, err)
+	}
+
+	return token, nil
+}
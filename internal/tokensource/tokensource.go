@@ -0,0 +1,82 @@
+package tokensource
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Token is an access token along with the time it expires
+type Token struct {
+	AccessToken string
+	// Expiry is the zero time for tokens whose expiration is unknown and that are never refreshed
+	Expiry time.Time
+}
+
+// Source gives out new access tokens
+type Source interface {
+	Token() (Token, error)
+}
+
+// expiryWindow is how long before their expiration tokens are refreshed
+var expiryWindow = 5 * time.Minute
+
+// StaticSource always gives out the same token, e.g. the output of `gcloud auth print-access-token` that was given as an option
+type StaticSource struct {
+	AccessToken string
+}
+
+// Token returns the static token
+func (source StaticSource) Token() (Token, error) {
+	if source.AccessToken == "" {
+		return Token{}, errors.New("no access token was given")
+	}
+
+	return Token{AccessToken: source.AccessToken}, nil
+}
+
+// CachedSource keeps the token of a source until shortly before it expires, so that it can be used on every request of a long-running plan or apply
+type CachedSource struct {
+	mutex   sync.Mutex
+	source  Source
+	current Token
+}
+
+// AccessToken returns a valid access token, getting a new one from the source if the current one is about to expire
+func (cached *CachedSource) AccessToken() (string, error) {
+	cached.mutex.Lock()
+	defer cached.mutex.Unlock()
+
+	if cached.current.AccessToken != "" && (cached.current.Expiry.IsZero() || time.Until(cached.current.Expiry) > expiryWindow) {
+		return cached.current.AccessToken, nil
+	}
+
+	token, err := cached.source.Token()
+
+	if err != nil {
+		return "", err
+	}
+
+	if !token.Expiry.IsZero() {
+		log.Debugf("Got a new access token that expires at %v", token.Expiry.Format(time.RFC3339))
+	}
+
+	cached.current = token
+
+	return token.AccessToken, nil
+}
+
+// Invalidate drops the current token, e.g. after the server has rejected it, so that the next call of AccessToken gets a new one
+func (cached *CachedSource) Invalidate() {
+	cached.mutex.Lock()
+	defer cached.mutex.Unlock()
+
+	cached.current = Token{}
+}
+
+// NewCachedSource wraps a source so that its tokens are reused until they are about to expire
+func NewCachedSource(source Source) *CachedSource {
+	return &CachedSource{source: source}
+}
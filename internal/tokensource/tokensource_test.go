@@ -0,0 +1,159 @@
+package tokensource
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingSource gives out numbered tokens that expire after the given lifetime
+type countingSource struct {
+	count    int
+	lifetime time.Duration
+}
+
+func (source *countingSource) Token() (Token, error) {
+	source.count++
+
+	return Token{AccessToken: strings.Repeat("t", source.count), Expiry: time.Now().Add(source.lifetime)}, nil
+}
+
+func TestCachedSource(t *testing.T) {
+	source := &countingSource{lifetime: time.Hour}
+	cached := NewCachedSource(source)
+
+	for i := 0; i < 3; i++ {
+		if token, _ := cached.AccessToken(); token != "t" {
+			t.Errorf("The first token should be reused, got %v", token)
+		}
+	}
+
+	cached.Invalidate()
+
+	if token, _ := cached.AccessToken(); token != "tt" {
+		t.Errorf("An invalidated token should be replaced, got %v", token)
+	}
+
+	// tokens that expire within the expiry window are refreshed on every use
+	source = &countingSource{lifetime: time.Minute}
+	cached = NewCachedSource(source)
+	_, _ = cached.AccessToken()
+	_, _ = cached.AccessToken()
+
+	if source.count != 2 {
+		t.Errorf("Tokens that are about to expire should be refreshed, got %v refreshes", source.count)
+	}
+
+	if _, err := NewCachedSource(StaticSource{}).AccessToken(); err == nil {
+		t.Error("An empty static token should be an error")
+	}
+}
+
+func TestExecSource(t *testing.T) {
+	token, err := ExecSource{Command: "echo ' plain-token '"}.Token()
+
+	if err != nil || token.AccessToken != "plain-token" || time.Until(token.Expiry) > execTokenLifetime {
+		t.Errorf("Wrong plain text token %+v (%v)", token, err)
+	}
+
+	token, err = ExecSource{Command: `echo '{"access_token": "json-token", "expires_in": 3599}'`}.Token()
+
+	if err != nil || token.AccessToken != "json-token" || time.Until(token.Expiry) < 59*time.Minute {
+		t.Errorf("Wrong json token %+v (%v)", token, err)
+	}
+
+	if _, err = (ExecSource{Command: "exit 1"}).Token(); err == nil {
+		t.Error("A failing command should be an error")
+	}
+}
+
+func TestServiceAccountSource(t *testing.T) {
+	privateKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+
+		if r.PostForm.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		hashed := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+		if rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, hashed[:], signature) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		claims := map[string]interface{}{}
+		_ = json.Unmarshal(claimsJSON, &claims)
+
+		if claims["iss"] != "cleaner@project.iam.gserviceaccount.com" || claims["aud"] != "http://"+r.Host+"/token" || claims["scope"] != cloudPlatformScope {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(tokenDTO{AccessToken: "sa-token", ExpiresIn: 3600, TokenType: "Bearer"})
+	}))
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	keyJSON, _ := json.Marshal(serviceAccountKeyDTO{
+		Type:        "service_account",
+		ClientEmail: "cleaner@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})),
+		TokenURI:    "https://oauth2.googleapis.com/token",
+	})
+
+	if err := ioutil.WriteFile(keyFile, keyJSON, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := ServiceAccountSource{KeyFile: keyFile, TokenEndpoint: server.URL + "/token"}.Token()
+
+	if err != nil || token.AccessToken != "sa-token" || time.Until(token.Expiry) < 59*time.Minute {
+		t.Errorf("Wrong service account token %+v (%v)", token, err)
+	}
+}
+
+func TestMetadataSource(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" || r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(tokenDTO{AccessToken: "metadata-token", ExpiresIn: 1800})
+	}))
+	defer server.Close()
+
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(server.URL, "http://"))
+
+	token, err := MetadataSource{}.Token()
+
+	if err != nil || token.AccessToken != "metadata-token" {
+		t.Errorf("Wrong metadata token %+v (%v)", token, err)
+	}
+
+	server.Close()
+
+	if _, err = (MetadataSource{}).Token(); err == nil {
+		t.Error("An unreachable metadata server should be an error")
+	}
+}